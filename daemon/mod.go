@@ -1,4 +1,4 @@
 package daemon
 
 // Version is the version to use for the daemon images.
-const Version = "daemon-v0.3.1"
+const Version = "daemon-v0.4.0"
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+var errNoApplication = errors.New("application process not found")
+
+// cgroupReader reads the resource usage of a container from the cgroup
+// hierarchy mounted in the container, which is either the unified hierarchy
+// of cgroup v2 or the controllers of cgroup v1.
+type cgroupReader struct {
+	path string
+	v2   bool
+}
+
+func newCgroupReader(path string) cgroupReader {
+	_, err := os.Stat(filepath.Join(path, "cgroup.controllers"))
+
+	return cgroupReader{
+		path: path,
+		v2:   err == nil,
+	}
+}
+
+// read fills the statistics with the usage of the CPU and the memory, and
+// with the block I/O and the number of processes when they are available.
+func (r cgroupReader) read(stats *types.StatsJSON) error {
+	if r.v2 {
+		return r.readV2(stats)
+	}
+
+	return r.readV1(stats)
+}
+
+func (r cgroupReader) readV2(stats *types.StatsJSON) error {
+	cpu, err := readKeyValues(filepath.Join(r.path, "cpu.stat"))
+	if err != nil {
+		return fmt.Errorf("couldn't read the cpu: %w", err)
+	}
+
+	stats.CPUStats.CPUUsage.TotalUsage = cpu["usage_usec"] * uint64(time.Microsecond)
+
+	stats.MemoryStats.Usage, err = readUint(filepath.Join(r.path, "memory.current"))
+	if err != nil {
+		return fmt.Errorf("couldn't read the memory: %w", err)
+	}
+
+	stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(r.path, "memory.stat"))
+	if err != nil {
+		return fmt.Errorf("couldn't read the memory: %w", err)
+	}
+
+	// The controllers below might not be enabled for the container.
+	stats.BlkioStats.IoServiceBytesRecursive = readIOStat(filepath.Join(r.path, "io.stat"))
+	stats.PidsStats.Current, _ = readUint(filepath.Join(r.path, "pids.current"))
+
+	return nil
+}
+
+func (r cgroupReader) readV1(stats *types.StatsJSON) error {
+	var err error
+
+	for _, ctrl := range []string{"cpuacct", "cpu,cpuacct", "cpuacct,cpu"} {
+		stats.CPUStats.CPUUsage.TotalUsage, err = readUint(filepath.Join(r.path, ctrl, "cpuacct.usage"))
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("couldn't read the cpu: %w", err)
+	}
+
+	stats.MemoryStats.Usage, err = readUint(filepath.Join(r.path, "memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return fmt.Errorf("couldn't read the memory: %w", err)
+	}
+
+	stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(r.path, "memory", "memory.stat"))
+	if err != nil {
+		return fmt.Errorf("couldn't read the memory: %w", err)
+	}
+
+	// The controllers below might not be mounted in the container.
+	stats.BlkioStats.IoServiceBytesRecursive = readBlkio(filepath.Join(r.path, "blkio", "blkio.throttle.io_service_bytes"))
+	stats.PidsStats.Current, _ = readUint(filepath.Join(r.path, "pids", "pids.current"))
+
+	return nil
+}
+
+// cgroupMonitor is a monitor that reads the cgroup of the application instead
+// of asking the container runtime, so that it works with any of them. The
+// process namespace must be shared inside the pod so that the monitor can
+// find the application and reach its cgroup hierarchy.
+type cgroupMonitor struct {
+	wg       sync.WaitGroup
+	interval time.Duration
+	c        chan *sample
+	closing  chan struct{}
+	procPath string
+	counters *netCounters
+	reader   cgroupReader
+	start    time.Time
+	previous types.CPUStats
+}
+
+func newCgroupMonitor(interval time.Duration) *cgroupMonitor {
+	return &cgroupMonitor{
+		interval: interval,
+		c:        make(chan *sample),
+		closing:  make(chan struct{}),
+		procPath: "/proc",
+		counters: newNetCounters(),
+	}
+}
+
+func (m *cgroupMonitor) Start() error {
+	m.start = time.Now()
+
+	err := m.resolve()
+	if err != nil {
+		return err
+	}
+
+	err = m.counters.enableAccounting()
+	if err != nil {
+		fmt.Printf("Couldn't enable the accounting of the connections: %v\n", err)
+	}
+
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.closing:
+				return
+			case <-ticker.C:
+				s, err := m.read()
+				if err != nil {
+					fmt.Printf("Monitor error: %+v\n", err)
+
+					// The application might have been restarted with another
+					// process, so it is looked up again at each interval
+					// until it is found. The sample is skipped as the usage
+					// of the new process starts over.
+					err = m.resolve()
+					if err != nil {
+						fmt.Printf("Couldn't find the application again: %v\n", err)
+					}
+
+					continue
+				}
+
+				err = m.counters.gather(s)
+				if err != nil {
+					fmt.Printf("Error when reading network stats: %v\n", err)
+				}
+
+				select {
+				case m.c <- s:
+				case <-m.closing:
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// resolve finds the process of the application and reads its cgroup once so
+// that the usage of the CPU of the next sample is computed from that point.
+func (m *cgroupMonitor) resolve() error {
+	pid, err := findApplication(m.procPath)
+	if err != nil {
+		return err
+	}
+
+	m.reader = newCgroupReader(filepath.Join(m.procPath, pid, "root", "sys", "fs", "cgroup"))
+	m.previous = types.CPUStats{}
+
+	// A first reading gives the reference for the usage of the CPU of the
+	// first sample.
+	_, err = m.read()
+
+	return err
+}
+
+// read returns a sample with the usage of the cgroup. The elapsed time is
+// used as the system usage of a single CPU so that the percentage is
+// computed the same way as with Docker.
+func (m *cgroupMonitor) read() (*sample, error) {
+	data := &types.StatsJSON{}
+
+	err := m.reader.read(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data.CPUStats.SystemUsage = uint64(time.Since(m.start))
+	data.CPUStats.CPUUsage.PercpuUsage = []uint64{data.CPUStats.CPUUsage.TotalUsage}
+	data.PreCPUStats = m.previous
+	m.previous = data.CPUStats
+
+	return &sample{StatsJSON: data}, nil
+}
+
+func (m *cgroupMonitor) Stop() error {
+	close(m.closing)
+	m.wg.Wait()
+
+	return nil
+}
+
+func (m *cgroupMonitor) Stream() <-chan *sample {
+	return m.c
+}
+
+// findApplication returns the PID of the process of the application, which
+// is the first process of the pod that is neither in the cgroup of the monitor
+// nor the pause process of the pod.
+func findApplication(procPath string) (string, error) {
+	self, err := ioutil.ReadFile(filepath.Join(procPath, "self", "cgroup"))
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the cgroup of the monitor: %w", err)
+	}
+
+	entries, err := ioutil.ReadDir(procPath)
+	if err != nil {
+		return "", fmt.Errorf("couldn't list the processes: %w", err)
+	}
+
+	pids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	sort.Ints(pids)
+
+	for _, pid := range pids {
+		dir := filepath.Join(procPath, strconv.Itoa(pid))
+
+		cgroup, err := ioutil.ReadFile(filepath.Join(dir, "cgroup"))
+		if err != nil || bytes.Equal(cgroup, self) {
+			continue
+		}
+
+		cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(cmdline) == 0 || bytes.HasPrefix(cmdline, []byte("/pause")) {
+			continue
+		}
+
+		return strconv.Itoa(pid), nil
+	}
+
+	return "", errNoApplication
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readKeyValues reads a file where each line is a key followed by a value.
+// Invalid lines are ignored.
+func readKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	values := make(map[string]uint64)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err == nil {
+			values[fields[0]] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// readIOStat reads the bytes read and written for each device of cgroup v2
+// where a line is the device followed by the counters like rbytes=1.
+func readIOStat(path string) []types.BlkioStatEntry {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	entries := []types.BlkioStatEntry{}
+	for _, line := range strings.Split(string(content), "\n") {
+		for _, field := range strings.Fields(line) {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			value, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch parts[0] {
+			case "rbytes":
+				entries = append(entries, types.BlkioStatEntry{Op: "read", Value: value})
+			case "wbytes":
+				entries = append(entries, types.BlkioStatEntry{Op: "write", Value: value})
+			}
+		}
+	}
+
+	return entries
+}
+
+// readBlkio reads the bytes of cgroup v1 where a line is the device followed
+// by the operation and the value.
+func readBlkio(path string) []types.BlkioStatEntry {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	entries := []types.BlkioStatEntry{}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err == nil {
+			entries = append(entries, types.BlkioStatEntry{Op: fields[1], Value: value})
+		}
+	}
+
+	return entries
+}
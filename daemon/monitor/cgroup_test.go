@@ -0,0 +1,245 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupReader_ReadV2(t *testing.T) {
+	dir, clean := makeTestCgroupV2(t)
+	defer clean()
+
+	reader := newCgroupReader(dir)
+	require.True(t, reader.v2)
+
+	stats := &types.StatsJSON{}
+	require.NoError(t, reader.read(stats))
+	require.Equal(t, uint64(2000000), stats.CPUStats.CPUUsage.TotalUsage)
+	require.Equal(t, uint64(4096), stats.MemoryStats.Usage)
+	require.Equal(t, uint64(100), stats.MemoryStats.Stats["file"])
+	require.Equal(t, uint64(200), stats.MemoryStats.Stats["anon"])
+	require.Equal(t, uint64(3), stats.PidsStats.Current)
+	require.Equal(t, []types.BlkioStatEntry{
+		{Op: "read", Value: 10},
+		{Op: "write", Value: 20},
+		{Op: "read", Value: 1},
+		{Op: "write", Value: 2},
+	}, stats.BlkioStats.IoServiceBytesRecursive)
+
+	values := sampleValues(&sample{StatsJSON: stats})
+	require.Equal(t, uint64(11), values["block_read"])
+	require.Equal(t, uint64(22), values["block_write"])
+
+	// Optional controllers.
+	require.NoError(t, os.Remove(filepath.Join(dir, "io.stat")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "pids.current")))
+	stats = &types.StatsJSON{}
+	require.NoError(t, reader.read(stats))
+	require.Empty(t, stats.BlkioStats.IoServiceBytesRecursive)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "memory.stat")))
+	require.Error(t, reader.read(stats))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "memory.current")))
+	require.Error(t, reader.read(stats))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "cpu.stat")))
+	err := reader.read(stats)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the cpu: ")
+}
+
+func TestCgroupReader_ReadV1(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "monitor-cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, filepath.Join(dir, "cpu,cpuacct", "cpuacct.usage"), "123456\n")
+	writeTestFile(t, filepath.Join(dir, "memory", "memory.usage_in_bytes"), "8192\n")
+	writeTestFile(t, filepath.Join(dir, "memory", "memory.stat"), "cache 10\nrss 20\ninvalid\n")
+	writeTestFile(t, filepath.Join(dir, "blkio", "blkio.throttle.io_service_bytes"),
+		"8:0 Read 5\n8:0 Write 6\n8:0 Total 11\nTotal 11\n")
+	writeTestFile(t, filepath.Join(dir, "pids", "pids.current"), "4\n")
+
+	reader := newCgroupReader(dir)
+	require.False(t, reader.v2)
+
+	stats := &types.StatsJSON{}
+	require.NoError(t, reader.read(stats))
+	require.Equal(t, uint64(123456), stats.CPUStats.CPUUsage.TotalUsage)
+	require.Equal(t, uint64(8192), stats.MemoryStats.Usage)
+	require.Equal(t, uint64(4), stats.PidsStats.Current)
+
+	values := sampleValues(&sample{StatsJSON: stats})
+	require.Equal(t, uint64(10), values["memory_cache"])
+	require.Equal(t, uint64(20), values["memory_rss"])
+	require.Equal(t, uint64(5), values["block_read"])
+	require.Equal(t, uint64(6), values["block_write"])
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "memory", "memory.stat")))
+	require.Error(t, reader.read(stats))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "memory", "memory.usage_in_bytes")))
+	require.Error(t, reader.read(stats))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "cpu,cpuacct")))
+	err = reader.read(stats)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the cpu: ")
+}
+
+func TestCgroupMonitor_Start(t *testing.T) {
+	proc, clean := makeTestProc(t)
+	defer clean()
+
+	cgroup, cleanCgroup := makeTestCgroupV2(t)
+	defer cleanCgroup()
+
+	writeTestFile(t, filepath.Join(proc, "self", "cgroup"), "0::/monitor\n")
+	writeTestFile(t, filepath.Join(proc, "1", "cgroup"), "0::/pause\n")
+	writeTestFile(t, filepath.Join(proc, "1", "cmdline"), "/pause\x00")
+	writeTestFile(t, filepath.Join(proc, "7", "cgroup"), "0::/app\n")
+	writeTestFile(t, filepath.Join(proc, "7", "cmdline"), "app\x00-v\x00")
+	writeTestFile(t, filepath.Join(proc, "12", "cgroup"), "0::/monitor\n")
+	writeTestFile(t, filepath.Join(proc, "12", "cmdline"), "./monitor\x00")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "7", "root", "sys", "fs"), 0755))
+	require.NoError(t, os.Symlink(cgroup, filepath.Join(proc, "7", "root", "sys", "fs", "cgroup")))
+
+	monitor := newCgroupMonitor(time.Millisecond)
+	monitor.procPath = proc
+	monitor.counters.procPath = proc
+	monitor.counters.localAddrs = testLocalAddrs
+
+	require.NoError(t, monitor.Start())
+
+	writeTestFile(t, filepath.Join(cgroup, "cpu.stat"), "usage_usec 1000000\n")
+
+	var s *sample
+	for s == nil || s.CPUStats.CPUUsage.TotalUsage == uint64(2*time.Millisecond) {
+		s = <-monitor.Stream()
+	}
+
+	require.NoError(t, monitor.Stop())
+
+	require.Equal(t, uint64(4096), s.MemoryStats.Usage)
+	require.Equal(t, uint64(1234), s.Networks[DockerNetworkInterface].RxBytes)
+	require.Equal(t, uint64(42), s.Retransmissions)
+	require.Equal(t, 1, len(s.CPUStats.CPUUsage.PercpuUsage))
+	require.True(t, s.CPUStats.SystemUsage > s.PreCPUStats.SystemUsage)
+}
+
+func TestCgroupMonitor_Restart(t *testing.T) {
+	proc, clean := makeTestProc(t)
+	defer clean()
+
+	cgroup, cleanCgroup := makeTestCgroupV2(t)
+	defer cleanCgroup()
+
+	writeTestFile(t, filepath.Join(proc, "self", "cgroup"), "0::/monitor\n")
+	writeTestFile(t, filepath.Join(proc, "7", "cgroup"), "0::/app\n")
+	writeTestFile(t, filepath.Join(proc, "7", "cmdline"), "app\x00")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "7", "root", "sys", "fs"), 0755))
+	require.NoError(t, os.Symlink(cgroup, filepath.Join(proc, "7", "root", "sys", "fs", "cgroup")))
+
+	monitor := newCgroupMonitor(time.Millisecond)
+	monitor.procPath = proc
+	monitor.counters.procPath = proc
+	monitor.counters.localAddrs = testLocalAddrs
+
+	require.NoError(t, monitor.Start())
+	defer monitor.Stop()
+
+	s := <-monitor.Stream()
+	require.Equal(t, uint64(4096), s.MemoryStats.Usage)
+
+	restarted, cleanRestarted := makeTestCgroupV2(t)
+	defer cleanRestarted()
+
+	writeTestFile(t, filepath.Join(restarted, "memory.current"), "8192\n")
+
+	// The application is restarted with another process.
+	require.NoError(t, os.RemoveAll(filepath.Join(proc, "7")))
+	writeTestFile(t, filepath.Join(proc, "9", "cgroup"), "0::/app\n")
+	writeTestFile(t, filepath.Join(proc, "9", "cmdline"), "app\x00")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "9", "root", "sys", "fs"), 0755))
+	require.NoError(t, os.Symlink(restarted, filepath.Join(proc, "9", "root", "sys", "fs", "cgroup")))
+
+	for s.MemoryStats.Usage != 8192 {
+		s = <-monitor.Stream()
+	}
+
+	// The usage of the CPU is computed from the new process.
+	require.Equal(t, s.CPUStats.CPUUsage.TotalUsage, s.PreCPUStats.CPUUsage.TotalUsage)
+}
+
+func TestCgroupMonitor_StartFailures(t *testing.T) {
+	proc, clean := makeTestProc(t)
+	defer clean()
+
+	monitor := newCgroupMonitor(time.Millisecond)
+	monitor.procPath = proc
+
+	err := monitor.Start()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the cgroup of the monitor: ")
+
+	writeTestFile(t, filepath.Join(proc, "self", "cgroup"), "0::/monitor\n")
+	require.Equal(t, errNoApplication, monitor.Start())
+
+	writeTestFile(t, filepath.Join(proc, "7", "cgroup"), "0::/app\n")
+	writeTestFile(t, filepath.Join(proc, "7", "cmdline"), "app\x00")
+
+	// The cgroup hierarchy of the application is not reachable.
+	err = monitor.Start()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the cpu: ")
+
+	_, err = findApplication("/non/existing/proc")
+	require.Error(t, err)
+}
+
+func TestCgroupMonitor_ReadError(t *testing.T) {
+	cgroup, clean := makeTestCgroupV2(t)
+	defer clean()
+
+	monitor := newCgroupMonitor(time.Millisecond)
+	monitor.reader = newCgroupReader(cgroup)
+	monitor.start = time.Now()
+
+	first, err := monitor.read()
+	require.NoError(t, err)
+
+	second, err := monitor.read()
+	require.NoError(t, err)
+	require.Equal(t, first.CPUStats, second.PreCPUStats)
+
+	require.NoError(t, os.Remove(filepath.Join(cgroup, "cpu.stat")))
+	_, err = monitor.read()
+	require.Error(t, err)
+}
+
+func makeTestCgroupV2(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "monitor-cgroup")
+	require.NoError(t, err)
+
+	writeTestFile(t, filepath.Join(dir, "cgroup.controllers"), "cpu io memory pids\n")
+	writeTestFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 2000\nuser_usec 1000\n")
+	writeTestFile(t, filepath.Join(dir, "memory.current"), "4096\n")
+	writeTestFile(t, filepath.Join(dir, "memory.stat"), "anon 200\nfile 100\n")
+	writeTestFile(t, filepath.Join(dir, "io.stat"),
+		"8:0 rbytes=10 wbytes=20 rios=1 wios=2\n253:0 rbytes=1 wbytes=2 dbytes=abc invalid\n")
+	writeTestFile(t, filepath.Join(dir, "pids.current"), "3\n")
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
-	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/docker/docker/api/types"
+	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/metrics/collect"
 )
 
 const (
@@ -20,19 +25,64 @@ const (
 	DockerSocketPath = "/var/run/docker.sock"
 	// DockerNetworkInterface is the name of the network interface to measure.
 	DockerNetworkInterface = "eth0"
+	// ScrapeTimeout is the maximum amount of time to scrape the application.
+	ScrapeTimeout = 500 * time.Millisecond
+
+	// CollectorDocker is the name of the collector that gets the statistics
+	// of the container from the Docker socket.
+	CollectorDocker = "docker"
+	// CollectorCgroup is the name of the collector that reads the cgroup of
+	// the application which works with any container runtime.
+	CollectorCgroup = "cgroup"
+	// CollectorNetwork is the name of the collector that only reads the
+	// counters of the network namespace, next to a strategy that gathers the
+	// usage of the resources by itself.
+	CollectorNetwork = "network"
 )
 
-var monitorFactory = func(name string) monitor {
-	return newMonitor(name)
+var monitorFactory = func(collector, name string, interval time.Duration) (monitor, error) {
+	switch collector {
+	case CollectorDocker:
+		return newMonitor(name, interval), nil
+	case CollectorCgroup:
+		return newCgroupMonitor(interval), nil
+	case CollectorNetwork:
+		return newNetworkMonitor(interval), nil
+	default:
+		return nil, fmt.Errorf("unknown collector '%s'", collector)
+	}
+}
+
+var scrapeClient = &http.Client{Timeout: ScrapeTimeout}
+
+// stringList is a flag that can be repeated to build a list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func main() {
 	flagset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	name := flagset.String("container", "", "container name prefix")
 	output := flagset.String("output", MeasureFileName, "output file")
+	scrape := flagset.String("scrape", "", "url of the Prometheus endpoint of the application")
+	series := &stringList{}
+	flagset.Var(series, "series", "series of the Prometheus endpoint to store (repeatable)")
+	interval := flagset.Duration("interval", DefaultInterval, "amount of time between two samples")
+	sets := flagset.String("metrics", "", "comma-separated extra metric sets among blkio, pids and memory")
+	collector := flagset.String("collector", CollectorDocker, "collector of the statistics, either docker, cgroup or network")
 
 	flagset.Parse(os.Args[1:])
 
+	columns, err := metrics.MonitorColumns(splitList(*sets))
+	checkErr(err)
+
 	sigc := make(chan os.Signal, 1)
 	// Docker stop sends a SIGTERM signal to gracefully stop the containers.
 	signal.Notify(sigc, syscall.SIGTERM)
@@ -42,7 +92,12 @@ func main() {
 
 	writer := bufio.NewWriter(f)
 
-	monitor := monitorFactory(*name)
+	_, err = writer.WriteString(metrics.FormatHeader(columns))
+	checkErr(err)
+
+	monitor, err := monitorFactory(*collector, *name, *interval)
+	checkErr(err)
+
 	err = monitor.Start()
 	checkErr(err)
 
@@ -61,18 +116,16 @@ func main() {
 				return
 			}
 
-			netstat := stats.Networks[DockerNetworkInterface]
-			ts := time.Now().Unix()
-			rx := netstat.RxBytes
-			tx := netstat.TxBytes
-			previousCPU := stats.PreCPUStats.CPUUsage.TotalUsage
-			previousSystem := stats.PreCPUStats.SystemUsage
-			cpu := calculateCPUPercent(previousCPU, previousSystem, stats)
-			mem := stats.MemoryStats.Usage
-
-			// Each line has the following values:
-			// TIMESTAMP || RX BYTES || TX BYTES || CPU || MEMORY
-			_, err = writer.WriteString(fmt.Sprintf("%d,%d,%d,%d,%d\n", ts, rx, tx, cpu, mem))
+			if *scrape != "" {
+				stats.Application, err = metrics.Scrape(scrapeClient, *scrape, *series)
+				if err != nil {
+					fmt.Printf("Error when scraping the application: %v\n", err)
+				}
+			}
+
+			ts := time.Now().UnixNano() / int64(time.Millisecond)
+
+			_, err = writer.WriteString(formatSample(ts, columns, stats))
 			checkErr(err)
 			err = writer.Flush()
 			checkErr(err)
@@ -80,20 +133,66 @@ func main() {
 	}
 }
 
-// https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L175-L188
-func calculateCPUPercent(previousCPU, previousSystem uint64, v *types.StatsJSON) int {
-	var (
-		cpuPercent = 0.0
-		// calculate the change for the cpu usage of the container in between readings
-		cpuDelta = float64(v.CPUStats.CPUUsage.TotalUsage) - float64(previousCPU)
-		// calculate the change for the entire system between readings
-		systemDelta = float64(v.CPUStats.SystemUsage) - float64(previousSystem)
-	)
-
-	if systemDelta > 0.0 && cpuDelta > 0.0 {
-		cpuPercent = (cpuDelta / systemDelta) * float64(len(v.CPUStats.CPUUsage.PercpuUsage)) * 100.0
+// splitList returns the elements of a comma-separated list.
+func splitList(list string) []string {
+	if list == "" {
+		return nil
 	}
-	return int(math.Ceil(cpuPercent))
+
+	return strings.Split(list, ",")
+}
+
+// formatSample returns the line written for the sample. The line starts with
+// the timestamp in milliseconds followed by the values of the columns
+// described in the header of the file, and then by a segment for each peer:
+// ; ADDRESS || RX BYTES || TX BYTES
+// and by a segment for each series scraped from the application:
+// ; ESCAPED KEY = VALUE
+func formatSample(ts int64, columns []string, stats *sample) string {
+	values := sampleValues(stats)
+
+	line := new(strings.Builder)
+	line.WriteString(strconv.FormatInt(ts, 10))
+
+	for _, col := range columns[1:] {
+		fmt.Fprintf(line, ",%d", values[col])
+	}
+
+	addrs := make([]string, 0, len(stats.Peers))
+	for addr := range stats.Peers {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		peer := stats.Peers[addr]
+		fmt.Fprintf(line, ";%s,%d,%d", addr, peer.Rx, peer.Tx)
+	}
+
+	keys := make([]string, 0, len(stats.Application))
+	for key := range stats.Application {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strconv.FormatFloat(stats.Application[key], 'g', -1, 64)
+		fmt.Fprintf(line, ";%s=%s", url.QueryEscape(key), value)
+	}
+
+	line.WriteString("\n")
+
+	return line.String()
+}
+
+// sampleValues returns the value of each column that the sample can fill.
+func sampleValues(stats *sample) map[string]uint64 {
+	values := collect.Values(collect.Stats{StatsJSON: stats.StatsJSON, OnlineCPUs: stats.OnlineCPUs}, DockerNetworkInterface)
+	values[metrics.ColumnRetransmissions] = stats.Retransmissions
+
+	return values
 }
 
 func checkErr(err error) {
@@ -1,29 +1,47 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
 )
 
 func TestMain_MonitorFactory(t *testing.T) {
-	m := monitorFactory("bob")
-	require.NotNil(t, m)
+	m, err := monitorFactory(CollectorDocker, "bob", time.Second)
+	require.NoError(t, err)
+	require.IsType(t, &defaultMonitor{}, m)
+
+	m, err = monitorFactory(CollectorCgroup, "bob", time.Second)
+	require.NoError(t, err)
+	require.IsType(t, &cgroupMonitor{}, m)
+
+	m, err = monitorFactory(CollectorNetwork, "bob", time.Second)
+	require.NoError(t, err)
+	require.IsType(t, &networkMonitor{}, m)
+
+	_, err = monitorFactory("abc", "bob", time.Second)
+	require.EqualError(t, err, "unknown collector 'abc'")
 }
 
 func TestMain_Run(t *testing.T) {
-	c := make(chan *types.StatsJSON)
-	monitorFactory = func(string) monitor {
-		return &testMonitor{c}
+	c := make(chan *sample)
+	monitorFactory = func(string, string, time.Duration) (monitor, error) {
+		return &testMonitor{c}, nil
 	}
 
 	go func() {
-		c <- &types.StatsJSON{}
+		c <- &sample{StatsJSON: &types.StatsJSON{}}
 		close(c)
 	}()
 
@@ -38,9 +56,131 @@ func TestMain_Run(t *testing.T) {
 
 	content, err := ioutil.ReadFile(f.Name())
 	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), metrics.FormatHeader(metrics.DefaultColumns)))
 	require.Contains(t, string(content), ",0,0,0\n")
 }
 
+func TestMain_RunMetrics(t *testing.T) {
+	c := make(chan *sample)
+	monitorFactory = func(collector, name string, interval time.Duration) (monitor, error) {
+		require.Equal(t, CollectorDocker, collector)
+		require.Equal(t, 250*time.Millisecond, interval)
+		return &testMonitor{c}, nil
+	}
+
+	go func() {
+		s := &sample{StatsJSON: &types.StatsJSON{}}
+		s.PidsStats.Current = 7
+		c <- s
+		close(c)
+	}()
+
+	f, err := ioutil.TempFile(os.TempDir(), "monitor")
+	require.NoError(t, err)
+
+	f.Close()
+	defer os.Remove(f.Name())
+
+	os.Args = []string{os.Args[0], "-output", f.Name(), "-interval", "250ms", "-metrics", "pids"}
+	main()
+
+	content, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Contains(t, string(content), "retransmissions,pids\n")
+	require.Contains(t, string(content), ",0,0,0,7\n")
+
+	ns, err := metrics.NewNodeStats(bytes.NewReader(content), time.Unix(0, 0), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []uint64{7}, ns.PIDs)
+	require.Len(t, ns.TimestampsMs, 1)
+}
+
+func TestMain_SampleValues(t *testing.T) {
+	stats := &sample{StatsJSON: &types.StatsJSON{}, OnlineCPUs: 2, Retransmissions: 3}
+	stats.MemoryStats.Stats = map[string]uint64{"file": 10, "anon": 20}
+	stats.CPUStats.CPUUsage.TotalUsage = 50
+	stats.CPUStats.SystemUsage = 100
+
+	values := sampleValues(stats)
+	require.Equal(t, uint64(3), values[metrics.ColumnRetransmissions])
+	require.Equal(t, uint64(10), values[metrics.ColumnMemoryCache])
+	require.Equal(t, uint64(20), values[metrics.ColumnMemoryRSS])
+	require.Equal(t, uint64(100*metrics.CPUScale), values[metrics.ColumnCPU])
+
+	require.Equal(t, []string{"a", "b"}, splitList("a,b"))
+	require.Nil(t, splitList(""))
+}
+
+func TestMain_FormatSample(t *testing.T) {
+	stats := &sample{
+		StatsJSON: &types.StatsJSON{
+			Stats: types.Stats{
+				MemoryStats: types.MemoryStats{Usage: 5},
+			},
+			Networks: map[string]types.NetworkStats{
+				DockerNetworkInterface: {
+					RxBytes:   1,
+					TxBytes:   2,
+					RxPackets: 3,
+					TxPackets: 4,
+					RxErrors:  5,
+					TxErrors:  6,
+					RxDropped: 7,
+					TxDropped: 8,
+				},
+			},
+		},
+		Retransmissions: 9,
+		Peers: map[string]peerTraffic{
+			"10.0.0.3": {Rx: 30, Tx: 300},
+			"10.0.0.2": {Rx: 20, Tx: 200},
+		},
+	}
+
+	line := formatSample(42, metrics.DefaultColumns, stats)
+	require.Equal(t, "42,1,2,0,5,3,4,5,6,7,8,9;10.0.0.2,20,200;10.0.0.3,30,300\n", line)
+
+	stats.Peers = nil
+	stats.Application = map[string]float64{
+		"raft_leaders":             3,
+		`sql_count{type="select"}`: 4.5,
+	}
+
+	line = formatSample(42, metrics.DefaultColumns, stats)
+	require.Equal(t, "42,1,2,0,5,3,4,5,6,7,8,9;raft_leaders=3;sql_count%7Btype%3D%22select%22%7D=4.5\n", line)
+}
+
+func TestMain_RunScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "raft_leaders 3")
+		fmt.Fprintln(w, "other 1")
+	}))
+	defer srv.Close()
+
+	c := make(chan *sample)
+	monitorFactory = func(string, string, time.Duration) (monitor, error) {
+		return &testMonitor{c}, nil
+	}
+
+	go func() {
+		c <- &sample{StatsJSON: &types.StatsJSON{}}
+		close(c)
+	}()
+
+	f, err := ioutil.TempFile(os.TempDir(), "monitor")
+	require.NoError(t, err)
+
+	f.Close()
+	defer os.Remove(f.Name())
+
+	os.Args = []string{os.Args[0], "-output", f.Name(), "-scrape", srv.URL, "-series", "raft_leaders"}
+	main()
+
+	content, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Contains(t, string(content), ",0,0,0;raft_leaders=3\n")
+}
+
 func TestMain_RunFailures(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -52,6 +192,16 @@ func TestMain_RunFailures(t *testing.T) {
 	main()
 }
 
+func TestMain_RunUnknownMetrics(t *testing.T) {
+	defer func() {
+		r := recover()
+		require.Equal(t, "unknown metric set 'abc'", r)
+	}()
+
+	os.Args = []string{os.Args[0], "-metrics", "pids,abc"}
+	main()
+}
+
 func TestMain_StopSignal(t *testing.T) {
 	monitorFactory = makeTestMonitor
 
@@ -82,7 +232,7 @@ func TestMain_StopSignal(t *testing.T) {
 }
 
 type testMonitor struct {
-	c chan *types.StatsJSON
+	c chan *sample
 }
 
 func (m *testMonitor) Start() error {
@@ -93,12 +243,12 @@ func (m *testMonitor) Stop() error {
 	return nil
 }
 
-func (m *testMonitor) Stream() <-chan *types.StatsJSON {
+func (m *testMonitor) Stream() <-chan *sample {
 	return m.c
 }
 
-func makeTestMonitor(name string) monitor {
+func makeTestMonitor(collector, name string, interval time.Duration) (monitor, error) {
 	return &testMonitor{
-		c: make(chan *types.StatsJSON),
-	}
+		c: make(chan *sample),
+	}, nil
 }
@@ -1,53 +1,64 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	dockerapi "github.com/docker/docker/client"
+	"go.dedis.ch/simnet/metrics/collect"
 )
 
-var reRxBytes = regexp.MustCompile("RX bytes:([0-9]+)")
-var reTxBytes = regexp.MustCompile("TX bytes:([0-9]+)")
-var errNoMatch = errors.New("missing substring match")
+const (
+	// DialTimeout is the timeout for the HTTP client to dial.
+	DialTimeout = 10 * time.Second
+	// DefaultInterval is the default amount of time between two samples.
+	DefaultInterval = time.Second
+)
 
-// DialTimeout is the timeout for the HTTP client to dial.
-const DialTimeout = 10 * time.Second
+// sample is the statistics of the container completed with the counters of
+// the network namespace of the pod.
+type sample struct {
+	*types.StatsJSON
+	// OnlineCPUs is the number of CPUs reported by Docker, if any.
+	OnlineCPUs      uint32
+	Retransmissions uint64
+	Peers           map[string]peerTraffic
+	// Application contains the series scraped from the application.
+	Application map[string]float64
+}
 
 type monitor interface {
 	Start() error
 	Stop() error
-	Stream() <-chan *types.StatsJSON
+	Stream() <-chan *sample
 }
 
 type defaultMonitor struct {
 	wg            sync.WaitGroup
-	c             chan *types.StatsJSON
+	interval      time.Duration
+	c             chan *sample
 	closing       chan struct{}
 	closer        io.Closer
 	containerName string
 	clientFactory func(url string) (dockerapi.APIClient, error)
-	netCmd        []string
+	counters      *netCounters
 }
 
-func newMonitor(name string) *defaultMonitor {
+func newMonitor(name string, interval time.Duration) *defaultMonitor {
 	return &defaultMonitor{
-		c:             make(chan *types.StatsJSON),
+		interval:      interval,
+		c:             make(chan *sample),
 		closing:       make(chan struct{}),
 		containerName: name,
 		clientFactory: makeDockerClient,
-		netCmd:        []string{"ifconfig", DockerNetworkInterface},
+		counters:      newNetCounters(),
 	}
 }
 
@@ -74,17 +85,21 @@ func (m *defaultMonitor) Start() error {
 
 	m.closer = reply.Body
 
+	err = m.counters.enableAccounting()
+	if err != nil {
+		fmt.Printf("Couldn't enable the accounting of the connections: %v\n", err)
+	}
+
 	dec := json.NewDecoder(reply.Body)
 
-	chanData := make(chan *types.StatsJSON, 1)
+	chanData := make(chan collect.Stats, 1)
 	chanErr := make(chan error, 1)
 
 	// First Go routine that will wait for responses from the stream and
 	// decode them. It stops by itself as soon as an error occured.
 	go func() {
 		for {
-			data := &types.StatsJSON{}
-			err = dec.Decode(data)
+			data, err := collect.DecodeStats(dec)
 			if err != nil {
 				chanErr <- err
 				return
@@ -97,28 +112,53 @@ func (m *defaultMonitor) Start() error {
 	m.wg.Add(1)
 
 	// Second Go routine will listen to either closing request or data coming
-	// from the stream. It also listen for stream errors and closes if it
-	// happens.
+	// from the stream, and take a sample at each interval from the latest
+	// data. Docker refreshes the statistics about every second so shorter
+	// intervals only get fresher network counters. It also listen for stream
+	// errors and closes if it happens.
 	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		var latest *collect.Stats
+
 		for {
 			select {
 			case <-m.closing:
-				m.wg.Done()
 				return
 			case err := <-chanErr:
 				fmt.Printf("Monitor error: %+v\n", err)
-				m.wg.Done()
 				return
 			case data := <-chanData:
+				latest = &data
+			case <-ticker.C:
+				if latest == nil {
+					// Nothing received from Docker yet.
+					continue
+				}
+
+				// The data is copied as the sample is updated with the
+				// network statistics while the previous one might still be
+				// in use.
+				data := *latest.StatsJSON
+
 				// Network statistics need to be gather in a different way as
 				// Kubernetes uses a different container to gather the *pod*
 				// statistics.
-				err := m.gatherNetworkStats(data)
+				s := &sample{StatsJSON: &data, OnlineCPUs: latest.OnlineCPUs}
+
+				err := m.counters.gather(s)
 				if err != nil {
 					fmt.Printf("Error when reading network stats: %v\n", err)
 				}
 
-				m.c <- data
+				select {
+				case m.c <- s:
+				case <-m.closing:
+					return
+				}
 			}
 		}
 	}()
@@ -126,46 +166,6 @@ func (m *defaultMonitor) Start() error {
 	return nil
 }
 
-func parseUint64(re *regexp.Regexp, out *bytes.Buffer) (uint64, error) {
-	m := re.FindStringSubmatch(out.String())
-
-	if len(m) < 2 {
-		return 0, errNoMatch
-	}
-
-	return strconv.ParseUint(m[1], 10, 64)
-}
-
-func (m *defaultMonitor) gatherNetworkStats(data *types.StatsJSON) error {
-	out := new(bytes.Buffer)
-
-	cmd := exec.Command(m.netCmd[0], m.netCmd[1:]...)
-	cmd.Stdout = out
-
-	err := cmd.Run()
-	if err != nil {
-		return err
-	}
-
-	rx, err := parseUint64(reRxBytes, out)
-	if err != nil {
-		return err
-	}
-
-	tx, err := parseUint64(reTxBytes, out)
-	if err != nil {
-		return err
-	}
-
-	data.Networks = make(map[string]types.NetworkStats)
-	data.Networks[DockerNetworkInterface] = types.NetworkStats{
-		RxBytes: rx,
-		TxBytes: tx,
-	}
-
-	return nil
-}
-
 func (m *defaultMonitor) Stop() error {
 	// First close the loop listening for data to ignore the IO error.
 	close(m.closing)
@@ -182,7 +182,7 @@ func (m *defaultMonitor) Stop() error {
 	return nil
 }
 
-func (m *defaultMonitor) Stream() <-chan *types.StatsJSON {
+func (m *defaultMonitor) Stream() <-chan *sample {
 	return m.c
 }
 
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -19,8 +22,12 @@ func TestMonitor_MakeDockerClient(t *testing.T) {
 }
 
 func TestMonitor_Start(t *testing.T) {
-	monitor := newMonitor("bob")
-	monitor.netCmd = []string{"echo", "RX bytes:1234 TX bytes:4321"}
+	dir, clean := makeTestProc(t)
+	defer clean()
+
+	monitor := newMonitor("bob", time.Millisecond)
+	monitor.counters.procPath = dir
+	monitor.counters.localAddrs = testLocalAddrs
 
 	r, w := io.Pipe()
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
@@ -36,11 +43,13 @@ func TestMonitor_Start(t *testing.T) {
 	require.NoError(t, monitor.Stop())
 	require.Equal(t, uint64(1234), stats.Networks[DockerNetworkInterface].RxBytes)
 	require.Equal(t, uint64(4321), stats.Networks[DockerNetworkInterface].TxBytes)
+	require.Equal(t, uint64(42), stats.Retransmissions)
+	require.Len(t, stats.Peers, 2)
 }
 
-func TestMonitor_StartErrorNetCmd(t *testing.T) {
-	monitor := newMonitor("bob")
-	monitor.netCmd = []string{"false"}
+func TestMonitor_StartErrorNetStats(t *testing.T) {
+	monitor := newMonitor("bob", time.Millisecond)
+	monitor.counters.procPath = "/non/existing/proc"
 
 	r, w := io.Pipe()
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
@@ -57,7 +66,7 @@ func TestMonitor_StartErrorNetCmd(t *testing.T) {
 }
 
 func TestMonitor_StartNoContainer(t *testing.T) {
-	monitor := newMonitor("abc")
+	monitor := newMonitor("abc", time.Millisecond)
 	monitor.clientFactory = makeTestClientFactory
 
 	err := monitor.Start()
@@ -66,7 +75,7 @@ func TestMonitor_StartNoContainer(t *testing.T) {
 }
 
 func TestMonitor_StartErrorFactory(t *testing.T) {
-	monitor := newMonitor("")
+	monitor := newMonitor("", time.Millisecond)
 	e := errors.New("factory error")
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
 		return nil, e
@@ -78,7 +87,7 @@ func TestMonitor_StartErrorFactory(t *testing.T) {
 }
 
 func TestMonitor_StartErrorList(t *testing.T) {
-	monitor := newMonitor("")
+	monitor := newMonitor("", time.Millisecond)
 	e := errors.New("list error")
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
 		return &testDockerClient{errList: e}, nil
@@ -90,7 +99,7 @@ func TestMonitor_StartErrorList(t *testing.T) {
 }
 
 func TestMonitor_StartErrorStats(t *testing.T) {
-	monitor := newMonitor("bob")
+	monitor := newMonitor("bob", time.Millisecond)
 	e := errors.New("stats error")
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
 		return &testDockerClient{errStats: e}, nil
@@ -104,7 +113,7 @@ func TestMonitor_StartErrorStats(t *testing.T) {
 func TestMonitor_StartStreamError(t *testing.T) {
 	reader, writer := io.Pipe()
 
-	monitor := newMonitor("bob")
+	monitor := newMonitor("bob", time.Millisecond)
 	monitor.clientFactory = func(url string) (dockerapi.APIClient, error) {
 		return &testDockerClient{reader: reader}, nil
 	}
@@ -130,21 +139,34 @@ func TestMonitor_StartStreamError(t *testing.T) {
 }
 
 func TestMonitor_GatherNetStatsFailures(t *testing.T) {
-	monitor := newMonitor("bob")
-	monitor.netCmd = []string{"echo", "RX bytes:abc"}
+	dir, clean := makeTestProc(t)
+	defer clean()
+
+	monitor := newMonitor("bob", time.Millisecond)
+	monitor.counters.procPath = dir
+	monitor.counters.localAddrs = testLocalAddrs
+
+	require.NoError(t, monitor.counters.gather(&sample{StatsJSON: &types.StatsJSON{}}))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "net", "nf_conntrack")))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "net", "nf_conntrack"), 0755))
+	err := monitor.counters.gather(&sample{StatsJSON: &types.StatsJSON{}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the connections: ")
 
-	err := monitor.gatherNetworkStats(&types.StatsJSON{})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "net", "snmp"), []byte{}, 0644))
+	err = monitor.counters.gather(&sample{StatsJSON: &types.StatsJSON{}})
 	require.Error(t, err)
 	require.True(t, errors.Is(err, errNoMatch))
 
-	monitor.netCmd = []string{"echo", "RX bytes:123 TX bytes:abc"}
-	err = monitor.gatherNetworkStats(&types.StatsJSON{})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "net", "dev"), []byte{}, 0644))
+	err = monitor.counters.gather(&sample{StatsJSON: &types.StatsJSON{}})
 	require.Error(t, err)
 	require.True(t, errors.Is(err, errNoMatch))
 }
 
 func TestMonitor_CloseError(t *testing.T) {
-	monitor := newMonitor("")
+	monitor := newMonitor("", time.Millisecond)
 	monitor.closer = badCloser{}
 
 	require.Error(t, monitor.Stop())
@@ -2,106 +2,261 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"os/exec"
-	"strings"
+	"net"
+	"sort"
+	"strconv"
 
 	"go.dedis.ch/simnet/network"
 	"golang.org/x/xerrors"
 )
 
-// Executor is responsible for executing the commands necessary to apply the
-// rules to the network device.
-type executor struct {
-	dev string
-	cmd string
-	out io.Writer
+// executor is responsible for applying the rules to the network device. It
+// can also remove the emulation and read the rules currently applied.
+type executor interface {
+	Execute(rules []network.Rule) error
+	Reset() error
+	Show() ([]network.Rule, error)
 }
 
-type command []string
+const (
+	// hashBuckets is the number of buckets of the hash tables that classify
+	// the hosts by the last byte of their address.
+	hashBuckets = 256
+	// hashTableIPv4 and hashTableIPv6 are the handles of the hash tables of
+	// each protocol family.
+	hashTableIPv4 = 2
+	hashTableIPv6 = 3
+)
+
+// protocols maps the names of the protocols supported by the rules to their
+// IP protocol numbers.
+var protocols = map[string]int{
+	network.TCP: 6,
+	network.UDP: 17,
+}
+
+// sortRules checks the protocols and the ports of the rules and returns a
+// copy where the rules restricted to a protocol or a port come first. The
+// filters of a given priority are evaluated in order, which means the specific
+// traffic is matched before the whole traffic of an address.
+func sortRules(rules []network.Rule) ([]network.Rule, error) {
+	for _, rule := range rules {
+		if _, ok := protocols[rule.Protocol]; rule.Protocol != "" && !ok {
+			return nil, xerrors.Errorf("unknown protocol '%s'", rule.Protocol)
+		}
+
+		if rule.Port != 0 && rule.Protocol != network.TCP && rule.Protocol != network.UDP {
+			return nil, xerrors.Errorf("port %d requires tcp or udp", rule.Port)
+		}
+	}
 
-// Execute takes a bunch of rules and apply them to the network device by
-// using the tc command.
-func (e executor) Execute(rules []network.Rule) error {
-	commands := make([]command, 0)
+	sorted := append([]network.Rule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].IsSpecific() && !sorted[j].IsSpecific()
+	})
 
-	// Reset the tree by replacing the root by a HTB queueing discpline and
-	// also create a single class that will allow the flows to be shared
-	// correctly.
-	commands = append(commands, e.makeRoot(), e.makeParent())
+	return sorted, nil
+}
 
-	// Then create a path for each target (single IP or a range).
-	classes := make(map[string]int)
+func hasDownload(rules []network.Rule) bool {
 	for _, rule := range rules {
-		m := rule.MatchAddr()
-		if _, ok := classes[m]; !ok {
-			minor := (len(classes) + 1) * 10
-			classes[m] = minor + 1
-
-			commands = append(
-				commands,
-				// Define the new flow attached to the parent class created
-				// previously.
-				e.makeClass(minor),
-				// Define which IPs can go through this flow.
-				e.makeFilter(m, minor),
-				// Attach the first queuing discpline (delay, loss, etc...).
-				e.makeQDisc(minor, classes[m], rule),
-			)
+		if rule.Download.Value > 0 {
+			return true
 		}
 	}
 
-	// Run the commands in order.
-	for _, args := range commands {
-		err := e.execTc(args)
-		if err != nil {
-			return xerrors.Errorf("%s command failed: %v", e.cmd, err)
+	return false
+}
+
+// mergeDownload sets the download bandwidth of the rule matching the same
+// traffic, or appends a new rule if none exists.
+func mergeDownload(rules []network.Rule, match network.Rule, download network.Bandwidth) []network.Rule {
+	for i, rule := range rules {
+		if rule.IP == match.IP && rule.Protocol == match.Protocol && rule.Port == match.Port {
+			rules[i].Download = download
+			return rules
 		}
 	}
 
-	return nil
+	match.Download = download
+
+	return append(rules, match)
 }
 
-func (e executor) execTc(args []string) error {
-	cmd := exec.Command(e.cmd, args...)
-	cmd.Stderr = e.out
-	cmd.Stdout = e.out
+// trafficClass is a class shared by the rules that emulate the same
+// properties.
+type trafficClass struct {
+	minor int
+	props network.Rule
+}
 
-	fmt.Fprintln(e.out, cmd.String())
+// trafficFilter directs the traffic of a rule to a class.
+type trafficFilter struct {
+	minor int
+	rule  network.Rule
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return xerrors.Errorf("cmd failed: %v", err)
+// hashTable is a u32 hash table that classifies the hosts of a protocol
+// family by the last byte of their address.
+type hashTable struct {
+	handle  uint32
+	ipv6    bool
+	filters []trafficFilter
+}
+
+// groupRules returns the classes and the filters of the rules. The rules that
+// emulate the same properties share a class so that the number of classes
+// and queuing disciplines does not grow with the number of destinations.
+// For the egress traffic, the properties are the delay, the loss and the
+// upload bandwidth. For the ingress traffic, only the rules with a download
+// bandwidth are considered. When several rules match the same traffic, the
+// first one is applied. It returns an error when there are too many classes
+// for the handles.
+func groupRules(rules []network.Rule, egress bool) ([]trafficClass, []trafficFilter, error) {
+	classes := make([]trafficClass, 0)
+	filters := make([]trafficFilter, 0, len(rules))
+	minors := make(map[network.Rule]int)
+	matches := make(map[string]struct{})
+
+	for _, rule := range rules {
+		props := network.Rule{Delay: rule.Delay, Loss: rule.Loss, Upload: rule.Upload}
+		if !egress {
+			if rule.Download.Value == 0 {
+				continue
+			}
+
+			props = network.Rule{Download: rule.Download}
+		}
+
+		key := fmt.Sprintf("%s %s %d", rule.MatchAddr(), rule.Protocol, rule.Port)
+		if _, ok := matches[key]; ok {
+			continue
+		}
+
+		matches[key] = struct{}{}
+
+		minor, ok := minors[props]
+		if !ok {
+			minor = (len(classes) + 1) * 10
+
+			_, err := makeMinor(minor)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			minors[props] = minor
+			classes = append(classes, trafficClass{minor: minor, props: props})
+		}
+
+		filters = append(filters, trafficFilter{minor: minor, rule: rule})
 	}
 
-	return nil
+	return classes, filters, nil
 }
 
-func (e executor) makeRoot() []string {
-	cmd := fmt.Sprintf("qdisc add dev %s root handle 1: htb", e.dev)
-	return strings.Split(cmd, " ")
-}
+// makeMinor returns the minor of a handle written with the decimal digits of
+// the value, as the tc command reads the handles in hexadecimal. Both
+// executors then create the same handles. It returns an error when the digits
+// do not fit in a handle, which the tc command would refuse as well.
+func makeMinor(value int) (uint16, error) {
+	minor, err := strconv.ParseUint(strconv.Itoa(value), 16, 16)
+	if err != nil {
+		return 0, xerrors.Errorf("too many classes for the handle %d", value)
+	}
 
-func (e executor) makeParent() []string {
-	cmd := fmt.Sprintf("class add dev %s parent 1: classid 1:1 htb rate 1000Mbps", e.dev)
-	return strings.Split(cmd, " ")
+	return uint16(minor), nil
 }
 
-func (e executor) makeClass(minor int) []string {
-	cmd := fmt.Sprintf("class add dev %s parent 1:1 classid 1:%d htb rate 1000Mbps", e.dev, minor)
-	return strings.Split(cmd, " ")
+// splitFilters moves the filters of single hosts to a hash table when a
+// protocol family has at least the threshold of them, so that a packet is
+// compared to the hosts of a single bucket instead of every filter. The
+// filters are evaluated in the order of the returned lists: the filters
+// restricted to a protocol or a port, then the hosts, and the subnets last,
+// whether the hosts are in a table or not, so that the precedence does not
+// depend on the threshold. A threshold of zero disables the tables.
+func splitFilters(filters []trafficFilter, threshold int) ([]trafficFilter, []hashTable, []trafficFilter) {
+	counts := make(map[bool]int)
+	for _, filter := range filters {
+		if isHost(filter.rule) {
+			counts[filter.rule.IsIPv6()]++
+		}
+	}
+
+	tables := make([]hashTable, 0, 2)
+	indices := make(map[bool]int)
+	for _, ipv6 := range []bool{false, true} {
+		if threshold > 0 && counts[ipv6] >= threshold {
+			handle := uint32(hashTableIPv4)
+			if ipv6 {
+				handle = hashTableIPv6
+			}
+
+			indices[ipv6] = len(tables)
+			tables = append(tables, hashTable{handle: handle, ipv6: ipv6})
+		}
+	}
+
+	head := make([]trafficFilter, 0)
+	hosts := make([]trafficFilter, 0)
+	subnets := make([]trafficFilter, 0)
+	for _, filter := range filters {
+		idx, ok := indices[filter.rule.IsIPv6()]
+
+		switch {
+		case filter.rule.IsSpecific():
+			head = append(head, filter)
+		case ok && isHost(filter.rule):
+			tables[idx].filters = append(tables[idx].filters, filter)
+		case isHost(filter.rule):
+			hosts = append(hosts, filter)
+		default:
+			subnets = append(subnets, filter)
+		}
+	}
+
+	return head, tables, append(hosts, subnets...)
 }
 
-func (e executor) makeQDisc(minor, major int, rule network.Rule) []string {
-	cmd := fmt.Sprintf("qdisc add dev %s parent 1:%d handle %d: %s", e.dev, minor, major, makeNetEm(rule))
-	return strings.Split(cmd, " ")
+// isHost returns true when the rule matches the whole traffic of a single
+// address.
+func isHost(rule network.Rule) bool {
+	if rule.IsSpecific() {
+		return false
+	}
+
+	_, subnet, err := net.ParseCIDR(rule.MatchAddr())
+	if err != nil {
+		return false
+	}
+
+	ones, bits := subnet.Mask.Size()
+
+	return ones == bits
 }
 
-func makeNetEm(rule network.Rule) string {
-	return strings.Trim(fmt.Sprintf("netem %s %s", rule.Delay, rule.Loss), " ")
+// hashBucket returns the bucket of the hash table where the host of the rule
+// is stored, which is the last byte of its address.
+func hashBucket(rule network.Rule) uint32 {
+	ip, _, err := net.ParseCIDR(rule.MatchAddr())
+	if err != nil {
+		return 0
+	}
+
+	return uint32(ip[len(ip)-1])
 }
 
-func (e executor) makeFilter(ip string, minor int) []string {
-	cmd := fmt.Sprintf("filter add dev %s protocol ip parent 1:0 prio 1 u32 match ip dst %s flowid 1:%d", e.dev, ip, minor)
-	return strings.Split(cmd, " ")
+// hashOffset returns the offset of the last word of the address used as the
+// key of the hash tables. The address is the destination for the egress
+// traffic and the source for the ingress one.
+func hashOffset(ipv6, egress bool) int {
+	switch {
+	case ipv6 && egress:
+		return 36
+	case ipv6:
+		return 20
+	case egress:
+		return 16
+	default:
+		return 12
+	}
 }
@@ -1,9 +1,8 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"io/ioutil"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -11,65 +10,124 @@ import (
 	"go.dedis.ch/simnet/network"
 )
 
-func TestExecutor_Execute(t *testing.T) {
-	out := new(bytes.Buffer)
-	exec := executor{
-		dev: "eth0",
-		cmd: "echo",
-		out: out,
+func TestExecutor_GroupRules(t *testing.T) {
+	rules := []network.Rule{
+		network.NewDelayRule("10.0.0.1", time.Second),
+		network.NewBandwidthRule("10.0.0.2", network.Mbps, network.Mbps),
+		network.NewDelayRule("10.0.0.3", time.Second),
+		network.NewLossRule("10.0.0.3", 0.5),
+		network.NewBandwidthRule("10.0.0.4", 0, network.Mbps),
 	}
 
-	err := exec.Execute(testMakeRules())
+	classes, filters, err := groupRules(rules, true)
 	require.NoError(t, err)
+	require.Len(t, classes, 3)
+	require.Equal(t, network.Delay{Value: time.Second}, classes[0].props.Delay)
+	require.Equal(t, []trafficFilter{
+		{minor: 10, rule: rules[0]},
+		{minor: 20, rule: rules[1]},
+		{minor: 10, rule: rules[2]},
+		{minor: 30, rule: rules[4]},
+	}, filters)
 
-	scanner := bufio.NewScanner(out)
-	for _, cmd := range testExpectedCommands {
-		require.True(t, scanner.Scan()) // ignore the log
-		require.True(t, scanner.Scan())
-		require.Equal(t, cmd, scanner.Text())
+	classes, filters, err = groupRules(rules, false)
+	require.NoError(t, err)
+	require.Equal(t, []trafficClass{
+		{minor: 10, props: network.Rule{Download: network.Bandwidth{Value: network.Mbps}}},
+	}, classes)
+	require.Len(t, filters, 2)
+
+	// Each rule has a distinct delay and thus its own class.
+	rules = make([]network.Rule, 1000)
+	for i := range rules {
+		rules[i] = network.NewDelayRule(fmt.Sprintf("10.0.%d.%d", i/256, i%256), time.Duration(i+1)*time.Millisecond)
 	}
+
+	_, _, err = groupRules(rules[:999], true)
+	require.NoError(t, err)
+
+	_, _, err = groupRules(rules, true)
+	require.EqualError(t, err, "too many classes for the handle 10000")
+}
+
+func TestExecutor_MakeMinor(t *testing.T) {
+	minor, err := makeMinor(10)
+	require.NoError(t, err)
+	require.Equal(t, uint16(0x10), minor)
+
+	minor, err = makeMinor(9991)
+	require.NoError(t, err)
+	require.Equal(t, uint16(0x9991), minor)
+
+	_, err = makeMinor(10000)
+	require.EqualError(t, err, "too many classes for the handle 10000")
 }
 
-func TestExecutor_ExecuteFailure(t *testing.T) {
-	exec := executor{
-		dev: "eth0",
-		cmd: "definitely not a valid command",
-		out: ioutil.Discard,
+func TestExecutor_SplitFilters(t *testing.T) {
+	filters := []trafficFilter{
+		{minor: 10, rule: network.Rule{IP: "10.0.0.1", Protocol: network.TCP}},
+		{minor: 10, rule: network.Rule{IP: "10.0.0.0/8"}},
+		{minor: 10, rule: network.Rule{IP: "10.0.0.1"}},
+		{minor: 20, rule: network.Rule{IP: "fd00::1"}},
+		{minor: 20, rule: network.Rule{IP: "fd00::2"}},
 	}
 
-	err := exec.Execute(testMakeRules())
-	require.Error(t, err)
-	require.Contains(t, err.Error(), " command failed:")
+	head, tables, tail := splitFilters(filters, 2)
+	require.Equal(t, filters[:1], head)
+	require.Equal(t, []hashTable{{handle: hashTableIPv6, ipv6: true, filters: filters[3:]}}, tables)
+	// The host comes before the subnet like in the tables.
+	require.Equal(t, []trafficFilter{filters[2], filters[1]}, tail)
+
+	head, tables, tail = splitFilters(filters, 0)
+	require.Len(t, head, 1)
+	require.Empty(t, tables)
+	require.Len(t, tail, 4)
 }
 
-var testExpectedCommands = []string{
-	// Root
-	"qdisc add dev eth0 root handle 1: htb",
-	"class add dev eth0 parent 1: classid 1:1 htb rate 1000Mbps",
-	// First
-	"class add dev eth0 parent 1:1 classid 1:10 htb rate 1000Mbps",
-	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.1/32 flowid 1:10",
-	"qdisc add dev eth0 parent 1:10 handle 11: netem delay 1000ms",
-	// Second
-	"class add dev eth0 parent 1:1 classid 1:20 htb rate 1000Mbps",
-	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.2/32 flowid 1:20",
-	"qdisc add dev eth0 parent 1:20 handle 21: netem delay 1000ms",
-	// Third
-	"class add dev eth0 parent 1:1 classid 1:30 htb rate 1000Mbps",
-	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.3/32 flowid 1:30",
-	"qdisc add dev eth0 parent 1:30 handle 31: netem  loss 50.00%",
-	// Fourth
-	"class add dev eth0 parent 1:1 classid 1:40 htb rate 1000Mbps",
-	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.4/32 flowid 1:40",
-	"qdisc add dev eth0 parent 1:40 handle 41: netem delay 1000ms",
+func TestExecutor_SplitFiltersPrecedence(t *testing.T) {
+	filters := []trafficFilter{
+		{minor: 10, rule: network.Rule{IP: "10.0.0.0/24"}},
+		{minor: 20, rule: network.Rule{IP: "10.0.0.1"}},
+		{minor: 30, rule: network.Rule{IP: "10.0.0.2"}},
+		{minor: 40, rule: network.Rule{IP: "10.0.0.2", Protocol: network.UDP}},
+	}
+
+	// Below, at and above the threshold of the tables.
+	for _, threshold := range []int{0, 1, 2, 3} {
+		head, tables, tail := splitFilters(filters, threshold)
+
+		require.Equal(t, 10, firstMatch(head, tables, tail, "10.0.0.3"), threshold)
+		require.Equal(t, 20, firstMatch(head, tables, tail, "10.0.0.1"), threshold)
+		require.Equal(t, 40, firstMatch(head, tables, tail, "10.0.0.2"), threshold)
+	}
 }
 
-func testMakeRules() []network.Rule {
-	return []network.Rule{
-		network.NewDelayRule("127.0.0.1", time.Second),
-		network.NewDelayRule("127.0.0.2", time.Second),
-		network.NewDelayRule("127.0.0.2", time.Second),
-		network.NewLossRule("127.0.0.3", 0.5),
-		network.NewDelayRule("127.0.0.4", time.Second),
+// firstMatch returns the minor of the first filter that matches a UDP packet
+// sent to the address in the order of evaluation of the lists.
+func firstMatch(head []trafficFilter, tables []hashTable, tail []trafficFilter, addr string) int {
+	ordered := append([]trafficFilter{}, head...)
+	for _, table := range tables {
+		ordered = append(ordered, table.filters...)
 	}
+
+	ordered = append(ordered, tail...)
+
+	for _, filter := range ordered {
+		_, subnet, err := net.ParseCIDR(filter.rule.MatchAddr())
+		if err == nil && subnet.Contains(net.ParseIP(addr)) {
+			return filter.minor
+		}
+	}
+
+	return 0
+}
+
+func TestExecutor_HashBucket(t *testing.T) {
+	require.Equal(t, uint32(0x2a), hashBucket(network.Rule{IP: "10.0.0.42"}))
+	require.Equal(t, uint32(0xff), hashBucket(network.Rule{IP: "fd00::ff"}))
+	require.Equal(t, uint32(0), hashBucket(network.Rule{IP: "abc"}))
+
+	require.True(t, isHost(network.Rule{IP: "10.0.0.1/32"}))
+	require.False(t, isHost(network.Rule{IP: "10.0.0.0/24"}))
+	require.False(t, isHost(network.Rule{IP: "abc"}))
 }
@@ -10,12 +10,29 @@ import (
 	"golang.org/x/xerrors"
 )
 
+const (
+	backendNetlink = "netlink"
+	backendTc      = "tc"
+)
+
 const (
 	// DefaultNetworkDevice is the name of the network device to apply the
 	// topology emulation.
 	DefaultNetworkDevice = "eth0"
 	// DefaultCommand is the location of the command to use for emulation.
 	DefaultCommand = "tc"
+	// DefaultIPCommand is the location of the command to create the
+	// intermediate device for the ingress traffic.
+	DefaultIPCommand = "ip"
+	// DefaultIFB is the name of the intermediate device used to shape the
+	// ingress traffic.
+	DefaultIFB = "ifb0"
+	// DefaultBackend is the way the rules are applied, either with netlink
+	// or by running the tc command.
+	DefaultBackend = backendNetlink
+	// DefaultHashThreshold is the number of hosts of a protocol family from
+	// which their filters are stored in a hash table instead of a list.
+	DefaultHashThreshold = 32
 	// DefaultInput is an empty string to use the standard input.
 	DefaultInput = ""
 	// DefaultLogFile is the file where to write the logs.
@@ -31,9 +48,15 @@ func checkErr(err error, msg string) {
 func main() {
 	flagset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	dev := flagset.String("dev", DefaultNetworkDevice, "network device to target")
+	backend := flagset.String("backend", DefaultBackend, "netlink or tc")
 	cmd := flagset.String("cmd", DefaultCommand, "tc command location")
+	ipCmd := flagset.String("ip", DefaultIPCommand, "ip command location")
+	ifb := flagset.String("ifb", DefaultIFB, "intermediate device for the ingress traffic")
+	hash := flagset.Int("hash", DefaultHashThreshold, "number of hosts from which the filters are hashed (0 to disable)")
 	input := flagset.String("input", DefaultInput, "input to decode to rules")
 	logpath := flagset.String("log", DefaultLogFile, "file to write the logs")
+	reset := flagset.Bool("reset", false, "remove the emulation instead of applying rules")
+	show := flagset.Bool("show", false, "print the rules currently applied as JSON")
 
 	flagset.Parse(os.Args[1:])
 
@@ -42,6 +65,50 @@ func main() {
 
 	defer log.Close()
 
+	var exec executor = tcExecutor{
+		dev:           *dev,
+		cmd:           *cmd,
+		ifb:           *ifb,
+		ipCmd:         *ipCmd,
+		hashThreshold: *hash,
+		out:           log,
+	}
+
+	switch *backend {
+	case backendNetlink:
+		nlExec, err := newNetlinkExecutor(*dev, *ifb, *hash, log)
+		if err != nil {
+			// The tc command is used as a fallback.
+			fmt.Fprintf(log, "Netlink is not available: %v\n", err)
+		} else {
+			exec = nlExec
+		}
+	case backendTc:
+	default:
+		checkErr(xerrors.Errorf("unknown backend '%s'", *backend), "invalid arguments")
+	}
+
+	if *show {
+		rules, err := exec.Show()
+		checkErr(err, "couldn't read the rules")
+
+		err = json.NewEncoder(os.Stdout).Encode(rules)
+		checkErr(err, "couldn't encode")
+
+		return
+	}
+
+	if *reset {
+		fmt.Fprintln(log, "Removing the rules from the network manager...")
+
+		err = exec.Reset()
+		checkErr(err, "couldn't reset the rules")
+
+		fmt.Fprintln(log, "Removing the rules from the network manager... Done")
+
+		return
+	}
+
 	reader := os.Stdin
 	if *input != "" {
 		reader, err = os.Open(*input)
@@ -55,11 +122,6 @@ func main() {
 	checkErr(err, "couldn't decode")
 
 	fmt.Fprintln(log, "Applying rules to the network manager...")
-	exec := executor{
-		dev: *dev,
-		cmd: *cmd,
-		out: log,
-	}
 
 	err = exec.Execute(data)
 	checkErr(err, "couldn't execute the rules")
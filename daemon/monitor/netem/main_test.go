@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
 )
 
 func TestMain(t *testing.T) {
@@ -23,7 +24,7 @@ func TestMain(t *testing.T) {
 	defer log.Close()
 	defer os.Remove(log.Name())
 
-	os.Args = []string{os.Args[0], "-cmd", "echo", "-input", file.Name(), "-log", log.Name()}
+	os.Args = []string{os.Args[0], "-backend", "tc", "-cmd", "echo", "-input", file.Name(), "-log", log.Name()}
 
 	enc := json.NewEncoder(file)
 	err = enc.Encode(testMakeRules())
@@ -41,6 +42,66 @@ func TestMain(t *testing.T) {
 	}
 }
 
+func TestMain_Reset(t *testing.T) {
+	log, err := ioutil.TempFile(os.TempDir(), "netem-test")
+	require.NoError(t, err)
+
+	defer log.Close()
+	defer os.Remove(log.Name())
+
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0": testQDiscs,
+	}, false)
+	defer clean()
+
+	os.Args = []string{os.Args[0], "-backend", "tc", "-cmd", cmd, "-ip", "false", "-reset", "-log", log.Name()}
+
+	main()
+
+	data, err := ioutil.ReadAll(log)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "qdisc del dev eth0 root\n")
+}
+
+func TestMain_Show(t *testing.T) {
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"filter show dev eth0": testFilters,
+	}, false)
+	defer clean()
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	os.Args = []string{os.Args[0], "-backend", "tc", "-cmd", cmd, "-show"}
+
+	main()
+
+	writer.Close()
+
+	var rules []network.Rule
+	err = json.NewDecoder(reader).Decode(&rules)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+}
+
+func TestMain_UnknownBackend(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expect a panic")
+		} else {
+			require.Equal(t, "invalid arguments: unknown backend 'abc'", r.(error).Error())
+		}
+	}()
+
+	os.Args = []string{os.Args[0], "-backend", "abc"}
+
+	main()
+}
+
 func TestMain_BadInput(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
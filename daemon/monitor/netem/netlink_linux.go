@@ -0,0 +1,744 @@
+// +build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/sys/unix"
+	"golang.org/x/xerrors"
+)
+
+// defaultRate is the rate, in bits per second, of the classes without a
+// bandwidth limit. It is the same as the 1000Mbps of the tc executor, which
+// tc reads as megabytes per second.
+const defaultRate = 8 * network.Gbps
+
+// netlinkHandle is the subset of the netlink API used by the executor so that
+// it can be replaced in the tests.
+type netlinkHandle interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+	QdiscReplace(qdisc netlink.Qdisc) error
+	QdiscDel(qdisc netlink.Qdisc) error
+	ClassList(link netlink.Link, parent uint32) ([]netlink.Class, error)
+	ClassReplace(class netlink.Class) error
+	ClassDel(class netlink.Class) error
+	FilterList(link netlink.Link, parent uint32) ([]netlink.Filter, error)
+	FilterAdd(filter netlink.Filter) error
+	FilterDel(filter netlink.Filter) error
+}
+
+// netlinkExecutor is the executor that applies the rules by talking directly
+// to the kernel through netlink, which avoids to fork a process per command.
+// It creates the same tree of queuing disciplines as the tc executor except
+// that the upload bandwidth is enforced by the class of the destination.
+type netlinkExecutor struct {
+	dev           string
+	ifb           string
+	hashThreshold int
+	handle        netlinkHandle
+	out           io.Writer
+}
+
+func newNetlinkExecutor(dev, ifb string, hashThreshold int, out io.Writer) (executor, error) {
+	handle, err := netlink.NewHandle()
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't open netlink: %v", err)
+	}
+
+	return netlinkExecutor{
+		dev:           dev,
+		ifb:           ifb,
+		hashThreshold: hashThreshold,
+		handle:        handle,
+		out:           out,
+	}, nil
+}
+
+// Execute applies the rules to the network device. The rules can be applied
+// again on a device that already emulates previous ones.
+func (e netlinkExecutor) Execute(rules []network.Rule) error {
+	rules, err := sortRules(rules)
+	if err != nil {
+		return xerrors.Errorf("invalid rules: %v", err)
+	}
+
+	link, err := e.handle.LinkByName(e.dev)
+	if err != nil {
+		return xerrors.Errorf("couldn't find %s: %v", e.dev, err)
+	}
+
+	qdiscs, err := e.handle.QdiscList(link)
+	if err != nil {
+		return xerrors.Errorf("couldn't list qdiscs of %s: %v", e.dev, err)
+	}
+
+	err = e.applyTree(link, rules, true)
+	if err != nil {
+		return err
+	}
+
+	if hasDownload(rules) {
+		err = e.executeIngress(link, rules)
+		if err != nil {
+			return xerrors.Errorf("couldn't shape ingress: %v", err)
+		}
+	} else if ingress := findQdisc(qdiscs, netlink.HANDLE_INGRESS); ingress != nil {
+		e.logf("qdisc del dev %s ingress", e.dev)
+
+		err = e.handle.QdiscDel(ingress)
+		if err != nil {
+			return xerrors.Errorf("couldn't remove ingress: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Reset removes the queuing disciplines created to emulate the rules and the
+// intermediate device for the ingress traffic.
+func (e netlinkExecutor) Reset() error {
+	link, err := e.handle.LinkByName(e.dev)
+	if err != nil {
+		return xerrors.Errorf("couldn't find %s: %v", e.dev, err)
+	}
+
+	qdiscs, err := e.handle.QdiscList(link)
+	if err != nil {
+		return xerrors.Errorf("couldn't list qdiscs of %s: %v", e.dev, err)
+	}
+
+	remove := make([]netlink.Qdisc, 0, 2)
+
+	// Only the root qdisc created by the executor is removed so that the
+	// default one of the device is left untouched.
+	root := findQdisc(qdiscs, netlink.HANDLE_ROOT)
+	if root != nil && root.Attrs().Handle == netlink.MakeHandle(1, 0) {
+		remove = append(remove, root)
+	}
+
+	ingress := findQdisc(qdiscs, netlink.HANDLE_INGRESS)
+	if ingress != nil {
+		remove = append(remove, ingress)
+	}
+
+	for _, qdisc := range remove {
+		e.logf("qdisc del dev %s %s", e.dev, qdisc.Type())
+
+		err = e.handle.QdiscDel(qdisc)
+		if err != nil {
+			return xerrors.Errorf("couldn't delete %s qdisc: %v", qdisc.Type(), err)
+		}
+	}
+
+	ifb, err := e.handle.LinkByName(e.ifb)
+	if err == nil {
+		e.logf("link del dev %s", e.ifb)
+
+		err = e.handle.LinkDel(ifb)
+		if err != nil {
+			return xerrors.Errorf("couldn't delete %s: %v", e.ifb, err)
+		}
+	}
+
+	return nil
+}
+
+// Show returns the rules currently applied to the network device.
+func (e netlinkExecutor) Show() ([]network.Rule, error) {
+	link, err := e.handle.LinkByName(e.dev)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't find %s: %v", e.dev, err)
+	}
+
+	qdiscs, err := e.handle.QdiscList(link)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't list qdiscs of %s: %v", e.dev, err)
+	}
+
+	filters, _, err := e.readTree(link)
+	if err != nil {
+		return nil, err
+	}
+
+	netems := make(map[uint16]*netlink.Netem)
+	for _, qdisc := range qdiscs {
+		netem, ok := qdisc.(*netlink.Netem)
+		if ok {
+			netems[minorOf(netem.Parent)] = netem
+		}
+	}
+
+	rules := make([]network.Rule, 0, len(filters))
+	for _, filter := range filters {
+		rule := filter.rule
+
+		netem := netems[uint16(filter.minor)]
+		if netem != nil {
+			rule.Delay.Value = ticksToDuration(netem.Latency)
+			rule.Delay.Leeway = ticksToDuration(netem.Jitter)
+			rule.Loss.Value = float64(netem.Loss) / float64(^uint32(0))
+			// The rate of the netem qdisc is in bytes.
+			rule.Upload.Value = netem.Rate64 * 8
+		}
+
+		rules = append(rules, rule)
+	}
+
+	ifb, err := e.handle.LinkByName(e.ifb)
+	if err != nil || findQdisc(qdiscs, netlink.HANDLE_INGRESS) == nil {
+		// The ingress traffic is not shaped.
+		return rules, nil
+	}
+
+	filters, rates, err := e.readTree(ifb)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filter := range filters {
+		rules = mergeDownload(rules, filter.rule, rates[filter.minor])
+	}
+
+	return rules, nil
+}
+
+// executeIngress redirects the incoming traffic of the network device to an
+// intermediate functional block (IFB) so that it can be shaped as egress
+// traffic.
+func (e netlinkExecutor) executeIngress(link netlink.Link, rules []network.Rule) error {
+	ifb, err := e.handle.LinkByName(e.ifb)
+	if err != nil {
+		// The device does not exist yet.
+		e.logf("link add %s type ifb", e.ifb)
+
+		err = e.handle.LinkAdd(&netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: e.ifb}})
+		if err != nil {
+			return xerrors.Errorf("couldn't create %s: %v", e.ifb, err)
+		}
+
+		ifb, err = e.handle.LinkByName(e.ifb)
+		if err != nil {
+			return xerrors.Errorf("couldn't find %s: %v", e.ifb, err)
+		}
+	}
+
+	err = e.handle.LinkSetUp(ifb)
+	if err != nil {
+		return xerrors.Errorf("couldn't set %s up: %v", e.ifb, err)
+	}
+
+	e.logf("qdisc replace dev %s ingress", e.dev)
+
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+
+	err = e.handle.QdiscReplace(ingress)
+	if err != nil {
+		return xerrors.Errorf("couldn't replace ingress qdisc: %v", err)
+	}
+
+	err = e.flushFilters(link, ingress.Handle)
+	if err != nil {
+		return err
+	}
+
+	e.logf("filter add dev %s ingress redirect to %s", e.dev, e.ifb)
+
+	// A filter without selector matches every packet.
+	redirect := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: link.Attrs().Index,
+			Parent:    ingress.Handle,
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		RedirIndex: ifb.Attrs().Index,
+	}
+
+	err = e.handle.FilterAdd(redirect)
+	if err != nil {
+		return xerrors.Errorf("couldn't redirect to %s: %v", e.ifb, err)
+	}
+
+	return e.applyTree(ifb, rules, false)
+}
+
+// applyTree replaces the root of the link by a HTB queuing discipline with a
+// class per set of emulated properties of the rules. For the egress traffic,
+// the class leads to a netem qdisc that emulates the properties, including the
+// upload bandwidth like the tc executor does. For the ingress traffic, only
+// the rules with a download bandwidth are considered and the class is limited
+// to it.
+func (e netlinkExecutor) applyTree(link netlink.Link, rules []network.Rule, egress bool) error {
+	name := link.Attrs().Name
+	index := link.Attrs().Index
+	root := netlink.MakeHandle(1, 0)
+
+	e.logf("qdisc replace dev %s root handle 1: htb", name)
+
+	err := e.handle.QdiscReplace(netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    root,
+		Parent:    netlink.HANDLE_ROOT,
+	}))
+	if err != nil {
+		return xerrors.Errorf("couldn't replace root qdisc of %s: %v", name, err)
+	}
+
+	err = e.replaceClass(link, 1, network.Bandwidth{Value: defaultRate})
+	if err != nil {
+		return err
+	}
+
+	err = e.flushFilters(link, root)
+	if err != nil {
+		return err
+	}
+
+	existing, err := e.handle.ClassList(link, root)
+	if err != nil {
+		return xerrors.Errorf("couldn't list classes of %s: %v", name, err)
+	}
+
+	used := map[uint32]struct{}{netlink.MakeHandle(1, 1): {}}
+	classes, filters, err := groupRules(rules, egress)
+	if err != nil {
+		return xerrors.Errorf("couldn't group the rules: %v", err)
+	}
+
+	for _, class := range classes {
+		minor, err := makeMinor(class.minor)
+		if err != nil {
+			return err
+		}
+
+		used[netlink.MakeHandle(1, minor)] = struct{}{}
+
+		rate := class.props.Download
+		if egress || rate.Value == 0 {
+			rate.Value = defaultRate
+		}
+
+		err = e.replaceClass(link, class.minor, rate)
+		if err != nil {
+			return err
+		}
+
+		if egress {
+			err = e.replaceNetEm(link, class.props, class.minor)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = e.addFilters(link, filters, egress)
+	if err != nil {
+		return err
+	}
+
+	// Finally remove the classes of the previous rules that are not used
+	// anymore.
+	for _, class := range existing {
+		if _, ok := used[class.Attrs().Handle]; !ok {
+			e.logf("class del dev %s classid %s", name, netlink.HandleStr(class.Attrs().Handle))
+
+			err = e.handle.ClassDel(class)
+			if err != nil {
+				return xerrors.Errorf("couldn't delete class %s: %v",
+					netlink.HandleStr(class.Attrs().Handle), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e netlinkExecutor) replaceClass(link netlink.Link, minor int, rate network.Bandwidth) error {
+	parent := netlink.MakeHandle(1, 1)
+	if minor == 1 {
+		parent = netlink.MakeHandle(1, 0)
+	}
+
+	e.logf("class replace dev %s classid 1:%d htb %s", link.Attrs().Name, minor, rate)
+
+	handle, err := makeMinor(minor)
+	if err != nil {
+		return err
+	}
+
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    parent,
+		Handle:    netlink.MakeHandle(1, handle),
+	}, netlink.HtbClassAttrs{Rate: rate.Value})
+
+	err = e.handle.ClassReplace(class)
+	if err != nil {
+		return xerrors.Errorf("couldn't replace class 1:%d: %v", minor, err)
+	}
+
+	return nil
+}
+
+// addFilters adds the filters to the root of the link. The hosts are stored
+// in hash tables when they are numerous enough.
+func (e netlinkExecutor) addFilters(link netlink.Link, filters []trafficFilter, egress bool) error {
+	head, tables, tail := splitFilters(filters, e.hashThreshold)
+
+	for _, filter := range head {
+		err := e.addFilter(link, filter, egress, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range tables {
+		err := e.addHashTable(link, table, egress)
+		if err != nil {
+			return err
+		}
+
+		for _, filter := range table.filters {
+			err = e.addFilter(link, filter, egress, makeHashHandle(table.handle, hashBucket(filter.rule)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, filter := range tail {
+		err := e.addFilter(link, filter, egress, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFilter adds the filter to the bucket of a hash table given by its
+// handle, or to the root table of the priority when the handle is zero.
+func (e netlinkExecutor) addFilter(link netlink.Link, filter trafficFilter, egress bool, ht uint32) error {
+	keys, err := makeKeys(filter.rule, egress)
+	if err != nil {
+		return xerrors.Errorf("couldn't make filter: %v", err)
+	}
+
+	e.logf("filter add dev %s %s flowid 1:%d", link.Attrs().Name, filter.rule.MatchAddr(), filter.minor)
+
+	minor, err := makeMinor(filter.minor)
+	if err != nil {
+		return err
+	}
+
+	u32 := &netlink.U32{
+		FilterAttrs: makeFilterAttrs(link, filter.rule.IsIPv6()),
+		ClassId:     netlink.MakeHandle(1, minor),
+		Hash:        ht,
+		Sel: &netlink.TcU32Sel{
+			Flags: netlink.TC_U32_TERMINAL,
+			Keys:  keys,
+		},
+	}
+
+	err = e.handle.FilterAdd(u32)
+	if err != nil {
+		return xerrors.Errorf("couldn't add filter for %s: %v", filter.rule.MatchAddr(), err)
+	}
+
+	return nil
+}
+
+// addHashTable creates the hash table and the filter of the root table that
+// sends every packet to the bucket given by the last byte of the address.
+func (e netlinkExecutor) addHashTable(link netlink.Link, table hashTable, egress bool) error {
+	e.logf("filter add dev %s handle %x: u32 divisor %d", link.Attrs().Name, table.handle, hashBuckets)
+
+	attrs := makeFilterAttrs(link, table.ipv6)
+	attrs.Handle = makeHashHandle(table.handle, 0)
+
+	err := e.handle.FilterAdd(&netlink.U32{FilterAttrs: attrs, Divisor: hashBuckets})
+	if err != nil {
+		return xerrors.Errorf("couldn't create hash table: %v", err)
+	}
+
+	offset := hashOffset(table.ipv6, egress)
+
+	e.logf("filter add dev %s u32 hashkey mask 0x000000ff at %d link %x:", link.Attrs().Name, offset, table.handle)
+
+	err = e.handle.FilterAdd(&netlink.U32{
+		FilterAttrs: makeFilterAttrs(link, table.ipv6),
+		Link:        makeHashHandle(table.handle, 0),
+		Sel: &netlink.TcU32Sel{
+			Hmask: 0xff,
+			Hoff:  int16(offset),
+			// A key without mask matches every packet.
+			Keys: []netlink.TcU32Key{{}},
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("couldn't link hash table: %v", err)
+	}
+
+	return nil
+}
+
+func (e netlinkExecutor) replaceNetEm(link netlink.Link, rule network.Rule, minor int) error {
+	e.logf("qdisc replace dev %s parent 1:%d handle %d: %s", link.Attrs().Name, minor, minor+1, makeNetEm(rule))
+
+	attrs := netlink.NetemQdiscAttrs{}
+	if rule.Delay.Value > 0 {
+		attrs.Latency = uint32(rule.Delay.Value.Microseconds())
+		attrs.Jitter = uint32(rule.Delay.Leeway.Microseconds())
+	}
+
+	if rule.Loss.Value > 0 && rule.Loss.Value <= 1 {
+		attrs.Loss = float32(rule.Loss.Value * 100)
+	}
+
+	// The rate of the netem qdisc is in bytes.
+	attrs.Rate64 = rule.Upload.Value / 8
+
+	parent, err := makeMinor(minor)
+	if err != nil {
+		return err
+	}
+
+	major, err := makeMinor(minor + 1)
+	if err != nil {
+		return err
+	}
+
+	netem := netlink.NewNetem(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    netlink.MakeHandle(1, parent),
+		Handle:    netlink.MakeHandle(major, 0),
+	}, attrs)
+
+	err = e.handle.QdiscReplace(netem)
+	if err != nil {
+		return xerrors.Errorf("couldn't replace netem qdisc of 1:%d: %v", minor, err)
+	}
+
+	return nil
+}
+
+// flushFilters deletes the filters of the parent, priority by priority.
+func (e netlinkExecutor) flushFilters(link netlink.Link, parent uint32) error {
+	filters, err := e.handle.FilterList(link, parent)
+	if err != nil {
+		return xerrors.Errorf("couldn't list filters of %s: %v", link.Attrs().Name, err)
+	}
+
+	done := make(map[[2]uint16]struct{})
+	for _, filter := range filters {
+		attrs := filter.Attrs()
+		key := [2]uint16{attrs.Priority, attrs.Protocol}
+
+		if _, ok := done[key]; ok {
+			continue
+		}
+
+		done[key] = struct{}{}
+
+		e.logf("filter del dev %s parent %s prio %d", link.Attrs().Name, netlink.HandleStr(parent), attrs.Priority)
+
+		// Without a handle, every filter of the priority is deleted.
+		err = e.handle.FilterDel(&netlink.U32{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: link.Attrs().Index,
+				Parent:    parent,
+				Priority:  attrs.Priority,
+				Protocol:  attrs.Protocol,
+			},
+		})
+		if err != nil {
+			return xerrors.Errorf("couldn't delete filters: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// readTree returns the filters of the root qdisc of the link and the rates of
+// the classes.
+func (e netlinkExecutor) readTree(link netlink.Link) ([]filterState, map[int]network.Bandwidth, error) {
+	root := netlink.MakeHandle(1, 0)
+
+	classes, err := e.handle.ClassList(link, root)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't list classes of %s: %v", link.Attrs().Name, err)
+	}
+
+	rates := make(map[int]network.Bandwidth)
+	for _, class := range classes {
+		htb, ok := class.(*netlink.HtbClass)
+		if ok {
+			// The rate of the class is in bytes.
+			rates[int(minorOf(htb.Handle))] = network.Bandwidth{Value: htb.Rate * 8}
+		}
+	}
+
+	filters, err := e.handle.FilterList(link, root)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't list filters of %s: %v", link.Attrs().Name, err)
+	}
+
+	states := make([]filterState, 0, len(filters))
+	for _, filter := range filters {
+		u32, ok := filter.(*netlink.U32)
+		if !ok || u32.ClassId == 0 || u32.Sel == nil {
+			continue
+		}
+
+		keys := make([]u32Key, len(u32.Sel.Keys))
+		for i, key := range u32.Sel.Keys {
+			keys[i] = u32Key{value: key.Val, mask: key.Mask, off: strconv.Itoa(int(key.Off))}
+		}
+
+		states = append(states, filterState{
+			minor: int(minorOf(u32.ClassId)),
+			rule:  makeRuleFromKeys(keys, u32.Protocol == unix.ETH_P_IPV6),
+		})
+	}
+
+	sort.SliceStable(states, func(i, j int) bool {
+		return states[i].minor < states[j].minor
+	})
+
+	return states, rates, nil
+}
+
+func (e netlinkExecutor) logf(format string, args ...interface{}) {
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// makeKeys returns the u32 keys that match the traffic of the rule, the same
+// way as the selectors of the tc executor. The address and the port of the
+// rule are the destination of the egress traffic and the source of the
+// ingress traffic.
+func makeKeys(rule network.Rule, egress bool) ([]netlink.TcU32Key, error) {
+	_, subnet, err := net.ParseCIDR(rule.MatchAddr())
+	if err != nil {
+		return nil, xerrors.Errorf("invalid address: %v", err)
+	}
+
+	// Offsets of the source address and of the protocol inside the IP
+	// header, and of the ports in the transport header.
+	addrOff, addrSize := 12, net.IPv4len
+	protoOff, protoShift := 8, 16
+	portOff := 20
+
+	if rule.IsIPv6() {
+		addrOff, addrSize = 8, net.IPv6len
+		protoOff, protoShift = 4, 8
+		portOff = 40
+	}
+
+	if egress {
+		addrOff += addrSize
+	}
+
+	ip := subnet.IP.To16()
+	if addrSize == net.IPv4len {
+		ip = subnet.IP.To4()
+	}
+
+	keys := make([]netlink.TcU32Key, 0)
+	for i := 0; i < addrSize; i += 4 {
+		mask := binary.BigEndian.Uint32(subnet.Mask[i:])
+		if mask == 0 {
+			break
+		}
+
+		keys = append(keys, netlink.TcU32Key{
+			Mask: mask,
+			Val:  binary.BigEndian.Uint32(ip[i:]) & mask,
+			Off:  int32(addrOff + i),
+		})
+	}
+
+	if rule.Protocol != "" {
+		keys = append(keys, netlink.TcU32Key{
+			Mask: 0xff << protoShift,
+			Val:  uint32(protocols[rule.Protocol]) << protoShift,
+			Off:  int32(protoOff),
+		})
+	}
+
+	if rule.Port != 0 {
+		key := netlink.TcU32Key{Mask: 0xffff, Val: uint32(rule.Port), Off: int32(portOff)}
+		if !egress {
+			key.Mask, key.Val = key.Mask<<16, key.Val<<16
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// makeFilterAttrs returns the attributes of a filter of the root qdisc. The
+// priority differs between the protocols as the filters of a given priority
+// must share the same protocol.
+func makeFilterAttrs(link netlink.Link, ipv6 bool) netlink.FilterAttrs {
+	attrs := netlink.FilterAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    netlink.MakeHandle(1, 0),
+		Priority:  1,
+		Protocol:  unix.ETH_P_IP,
+	}
+
+	if ipv6 {
+		attrs.Priority = 2
+		attrs.Protocol = unix.ETH_P_IPV6
+	}
+
+	return attrs
+}
+
+// makeHashHandle returns the u32 handle of a bucket of a hash table, which is
+// written table:bucket: by the tc command.
+func makeHashHandle(table, bucket uint32) uint32 {
+	return table<<20 | bucket<<12
+}
+
+func minorOf(handle uint32) uint16 {
+	_, minor := netlink.MajorMinor(handle)
+	return minor
+}
+
+// findQdisc returns the qdisc attached to the parent or nil if none exists.
+func findQdisc(qdiscs []netlink.Qdisc, parent uint32) netlink.Qdisc {
+	for _, qdisc := range qdiscs {
+		if qdisc.Attrs().Parent == parent {
+			return qdisc
+		}
+	}
+
+	return nil
+}
+
+// ticksToDuration converts the time of a netem qdisc to a duration.
+func ticksToDuration(ticks uint32) time.Duration {
+	return time.Duration(float64(ticks)/netlink.TickInUsec()) * time.Microsecond
+}
@@ -0,0 +1,466 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/sys/unix"
+	"golang.org/x/xerrors"
+)
+
+func TestNetlinkExecutor_New(t *testing.T) {
+	exec, err := newNetlinkExecutor("eth0", "ifb0", DefaultHashThreshold, ioutil.Discard)
+	if err != nil {
+		t.Skipf("netlink not available: %v", err)
+	}
+
+	require.NotNil(t, exec)
+}
+
+func TestNetlinkExecutor_Execute(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:    "eth0",
+		ifb:    "ifb0",
+		handle: handle,
+		out:    ioutil.Discard,
+	}
+
+	err := exec.Execute(testMakeRules())
+	require.NoError(t, err)
+
+	eth0 := handle.links["eth0"]
+
+	// Parent class, and one class per distinct set of properties.
+	require.Len(t, handle.classesOf(eth0), 3)
+	require.Len(t, handle.filtersOf(eth0), 4)
+	// Root and a netem qdisc per class.
+	require.Len(t, handle.qdiscsOf(eth0), 3)
+	require.Nil(t, handle.links["ifb0"])
+
+	filter := handle.filtersOf(eth0)[0].(*netlink.U32)
+	require.Equal(t, netlink.MakeHandle(1, 0x10), filter.ClassId)
+	require.Equal(t, []netlink.TcU32Key{{Mask: 0xffffffff, Val: 0x7f000001, Off: 16}}, filter.Sel.Keys)
+
+	// Apply fewer rules to check that the previous ones are removed.
+	err = exec.Execute(testMakeRules()[:1])
+	require.NoError(t, err)
+	require.Len(t, handle.classesOf(eth0), 2)
+	require.Len(t, handle.filtersOf(eth0), 1)
+}
+
+func TestNetlinkExecutor_ExecuteHashed(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:           "eth0",
+		ifb:           "ifb0",
+		hashThreshold: 2,
+		handle:        handle,
+		out:           ioutil.Discard,
+	}
+
+	err := exec.Execute(testMakeRules())
+	require.NoError(t, err)
+
+	filters := handle.filtersOf(handle.links["eth0"])
+	require.Len(t, filters, 6)
+
+	table := filters[0].(*netlink.U32)
+	require.Equal(t, uint32(0x200000), table.Handle)
+	require.Equal(t, uint32(hashBuckets), table.Divisor)
+
+	link := filters[1].(*netlink.U32)
+	require.Equal(t, uint32(0x200000), link.Link)
+	require.Equal(t, uint32(0xff), link.Sel.Hmask)
+	require.Equal(t, int16(16), link.Sel.Hoff)
+
+	host := filters[2].(*netlink.U32)
+	require.Equal(t, uint32(0x201000), host.Hash)
+	require.Equal(t, netlink.MakeHandle(1, 0x10), host.ClassId)
+
+	// The table and its link are not rules.
+	rules, err := exec.Show()
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+}
+
+func TestNetlinkExecutor_ExecuteIngress(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:    "eth0",
+		ifb:    "ifb0",
+		handle: handle,
+		out:    ioutil.Discard,
+	}
+
+	rules := []network.Rule{
+		{IP: "10.0.0.2", Protocol: network.UDP, Port: 7000, Delay: network.Delay{Value: 50 * time.Millisecond}},
+		{IP: "10.0.0.2", Upload: network.Bandwidth{Value: network.Mbps}},
+		{IP: "fd00::/64", Download: network.Bandwidth{Value: 2 * network.Mbps}},
+	}
+
+	err := exec.Execute(rules)
+	require.NoError(t, err)
+
+	// The upload is limited by the netem qdisc, as with tc, and the egress
+	// classes keep the default rate.
+	eth0 := handle.links["eth0"]
+	rates := make([]uint64, 0)
+	for _, qdisc := range handle.qdiscsOf(eth0) {
+		if netem, ok := qdisc.(*netlink.Netem); ok {
+			rates = append(rates, netem.Rate64)
+		}
+	}
+	require.ElementsMatch(t, []uint64{0, network.Mbps / 8, 0}, rates)
+
+	for _, class := range handle.classesOf(eth0) {
+		require.Equal(t, defaultRate/8, class.(*netlink.HtbClass).Rate)
+	}
+
+	ifb := handle.links["ifb0"]
+	require.NotNil(t, ifb)
+	require.Len(t, handle.classesOf(ifb), 2)
+	require.Len(t, handle.filtersOf(ifb), 1)
+
+	filter := handle.filtersOf(ifb)[0].(*netlink.U32)
+	require.Equal(t, uint16(unix.ETH_P_IPV6), filter.Protocol)
+	require.Equal(t, []netlink.TcU32Key{
+		{Mask: 0xffffffff, Val: 0xfd000000, Off: 8},
+		{Mask: 0xffffffff, Val: 0, Off: 12},
+	}, filter.Sel.Keys)
+
+	shown, err := exec.Show()
+	require.NoError(t, err)
+	require.Len(t, shown, 3)
+	require.Equal(t, network.UDP, shown[0].Protocol)
+	require.Equal(t, uint16(7000), shown[0].Port)
+	require.Equal(t, network.Mbps, shown[1].Upload.Value)
+	require.Equal(t, "fd00::/64", shown[2].IP)
+	require.Equal(t, 2*network.Mbps, shown[2].Download.Value)
+
+	// Without download bandwidth, the ingress is removed.
+	err = exec.Execute(rules[:1])
+	require.NoError(t, err)
+	require.Nil(t, findQdisc(handle.qdiscsOf(handle.links["eth0"]), netlink.HANDLE_INGRESS))
+}
+
+func TestNetlinkExecutor_ExecuteFailures(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:    "eth1",
+		ifb:    "ifb0",
+		handle: handle,
+		out:    ioutil.Discard,
+	}
+
+	err := exec.Execute([]network.Rule{{IP: "127.0.0.1", Protocol: "sctp"}})
+	require.EqualError(t, err, "invalid rules: unknown protocol 'sctp'")
+
+	err = exec.Execute(nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't find eth1: ")
+
+	exec.dev = "eth0"
+	err = exec.Execute([]network.Rule{{IP: "abc"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't make filter: ")
+
+	handle.err = xerrors.New("oops")
+	err = exec.Execute(testMakeRules())
+	require.EqualError(t, err, "couldn't list qdiscs of eth0: oops")
+
+	handle.err = nil
+	handle.linkAddErr = xerrors.New("oops")
+	err = exec.Execute([]network.Rule{network.NewBandwidthRule("127.0.0.1", 0, network.Mbps)})
+	require.EqualError(t, err, "couldn't shape ingress: couldn't create ifb0: oops")
+}
+
+func TestNetlinkExecutor_Reset(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:    "eth0",
+		ifb:    "ifb0",
+		handle: handle,
+		out:    ioutil.Discard,
+	}
+
+	err := exec.Execute([]network.Rule{network.NewBandwidthRule("127.0.0.1", 0, network.Mbps)})
+	require.NoError(t, err)
+
+	err = exec.Reset()
+	require.NoError(t, err)
+	require.Len(t, handle.qdiscsOf(handle.links["eth0"]), 0)
+	require.Nil(t, handle.links["ifb0"])
+
+	// The default root qdisc is kept.
+	handle.qdiscs = append(handle.qdiscs, &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{LinkIndex: 1, Parent: netlink.HANDLE_ROOT},
+		QdiscType:  "noqueue",
+	})
+
+	err = exec.Reset()
+	require.NoError(t, err)
+	require.Len(t, handle.qdiscs, 1)
+
+	handle.err = xerrors.New("oops")
+	err = exec.Reset()
+	require.EqualError(t, err, "couldn't list qdiscs of eth0: oops")
+
+	exec.dev = "eth1"
+	err = exec.Reset()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't find eth1: ")
+}
+
+func TestNetlinkExecutor_Show(t *testing.T) {
+	handle := newFakeHandle()
+	exec := netlinkExecutor{
+		dev:    "eth0",
+		ifb:    "ifb0",
+		handle: handle,
+		out:    ioutil.Discard,
+	}
+
+	rules, err := exec.Show()
+	require.NoError(t, err)
+	require.Empty(t, rules)
+
+	err = exec.Execute(testMakeRules())
+	require.NoError(t, err)
+
+	rules, err = exec.Show()
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	require.Equal(t, "127.0.0.1", rules[0].IP)
+	require.Equal(t, "127.0.0.3", rules[3].IP)
+	require.InDelta(t, 0.5, rules[3].Loss.Value, 1e-6)
+
+	handle.err = xerrors.New("oops")
+	_, err = exec.Show()
+	require.EqualError(t, err, "couldn't list qdiscs of eth0: oops")
+
+	exec.dev = "eth1"
+	_, err = exec.Show()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't find eth1: ")
+}
+
+func TestNetlinkExecutor_MakeKeys(t *testing.T) {
+	keys, err := makeKeys(network.Rule{IP: "10.0.0.0/8", Protocol: network.TCP, Port: 80}, false)
+	require.NoError(t, err)
+	require.Equal(t, []netlink.TcU32Key{
+		{Mask: 0xff000000, Val: 0x0a000000, Off: 12},
+		{Mask: 0x00ff0000, Val: 0x00060000, Off: 8},
+		{Mask: 0xffff0000, Val: 0x00500000, Off: 20},
+	}, keys)
+
+	keys, err = makeKeys(network.Rule{IP: "fd00::1", Protocol: network.UDP, Port: 80}, true)
+	require.NoError(t, err)
+	require.Len(t, keys, 6)
+	require.Equal(t, netlink.TcU32Key{Mask: 0xffffffff, Val: 1, Off: 36}, keys[3])
+	require.Equal(t, netlink.TcU32Key{Mask: 0xff00, Val: 0x1100, Off: 4}, keys[4])
+	require.Equal(t, netlink.TcU32Key{Mask: 0xffff, Val: 80, Off: 40}, keys[5])
+}
+
+func TestNetlinkExecutor_ExecuteTooManyClasses(t *testing.T) {
+	exec := netlinkExecutor{
+		dev:    "eth0",
+		ifb:    "ifb0",
+		handle: newFakeHandle(),
+		out:    ioutil.Discard,
+	}
+
+	// Each rule has a distinct delay and thus its own class.
+	rules := make([]network.Rule, 1000)
+	for i := range rules {
+		rules[i] = network.NewDelayRule(fmt.Sprintf("10.0.%d.%d", i/256, i%256), time.Duration(i+1)*time.Millisecond)
+	}
+
+	err := exec.Execute(rules)
+	require.EqualError(t, err, "couldn't group the rules: too many classes for the handle 10000")
+}
+
+// fakeHandle is an in-memory implementation of the netlink API that keeps
+// track of the links and of the traffic control objects.
+type fakeHandle struct {
+	links      map[string]netlink.Link
+	qdiscs     []netlink.Qdisc
+	classes    []netlink.Class
+	filters    []netlink.Filter
+	err        error
+	linkAddErr error
+}
+
+func newFakeHandle() *fakeHandle {
+	return &fakeHandle{
+		links: map[string]netlink.Link{
+			"eth0": &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}},
+		},
+	}
+}
+
+func (h *fakeHandle) qdiscsOf(link netlink.Link) []netlink.Qdisc {
+	qdiscs, _ := h.QdiscList(link)
+	return qdiscs
+}
+
+func (h *fakeHandle) classesOf(link netlink.Link) []netlink.Class {
+	classes, _ := h.ClassList(link, netlink.MakeHandle(1, 0))
+	return classes
+}
+
+func (h *fakeHandle) filtersOf(link netlink.Link) []netlink.Filter {
+	filters, _ := h.FilterList(link, netlink.MakeHandle(1, 0))
+	return filters
+}
+
+func (h *fakeHandle) LinkByName(name string) (netlink.Link, error) {
+	link, ok := h.links[name]
+	if !ok {
+		return nil, xerrors.New("link not found")
+	}
+
+	return link, nil
+}
+
+func (h *fakeHandle) LinkAdd(link netlink.Link) error {
+	if h.linkAddErr != nil {
+		return h.linkAddErr
+	}
+
+	link.Attrs().Index = len(h.links) + 1
+	h.links[link.Attrs().Name] = link
+
+	return nil
+}
+
+func (h *fakeHandle) LinkSetUp(link netlink.Link) error {
+	return nil
+}
+
+func (h *fakeHandle) LinkDel(link netlink.Link) error {
+	delete(h.links, link.Attrs().Name)
+
+	qdiscs := make([]netlink.Qdisc, 0, len(h.qdiscs))
+	for _, q := range h.qdiscs {
+		if q.Attrs().LinkIndex != link.Attrs().Index {
+			qdiscs = append(qdiscs, q)
+		}
+	}
+
+	h.qdiscs = qdiscs
+	return nil
+}
+
+func (h *fakeHandle) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	qdiscs := make([]netlink.Qdisc, 0)
+	for _, qdisc := range h.qdiscs {
+		if qdisc.Attrs().LinkIndex == link.Attrs().Index {
+			qdiscs = append(qdiscs, qdisc)
+		}
+	}
+
+	return qdiscs, nil
+}
+
+func (h *fakeHandle) QdiscReplace(qdisc netlink.Qdisc) error {
+	h.QdiscDel(qdisc)
+	h.qdiscs = append(h.qdiscs, qdisc)
+	return nil
+}
+
+func (h *fakeHandle) QdiscDel(qdisc netlink.Qdisc) error {
+	attrs := qdisc.Attrs()
+
+	// Deleting the root deletes the whole tree except the ingress qdisc.
+	isRoot := attrs.Parent == netlink.HANDLE_ROOT
+
+	qdiscs := make([]netlink.Qdisc, 0, len(h.qdiscs))
+	for _, q := range h.qdiscs {
+		other := q.Attrs().LinkIndex != attrs.LinkIndex
+		inTree := q.Attrs().Parent == attrs.Parent || (isRoot && q.Attrs().Parent != netlink.HANDLE_INGRESS)
+
+		if other || !inTree {
+			qdiscs = append(qdiscs, q)
+		}
+	}
+
+	h.qdiscs = qdiscs
+	return nil
+}
+
+func (h *fakeHandle) ClassList(link netlink.Link, parent uint32) ([]netlink.Class, error) {
+	major, _ := netlink.MajorMinor(parent)
+
+	classes := make([]netlink.Class, 0)
+	for _, class := range h.classes {
+		m, _ := netlink.MajorMinor(class.Attrs().Handle)
+		if class.Attrs().LinkIndex == link.Attrs().Index && m == major {
+			classes = append(classes, class)
+		}
+	}
+
+	return classes, nil
+}
+
+func (h *fakeHandle) ClassReplace(class netlink.Class) error {
+	h.ClassDel(class)
+	h.classes = append(h.classes, class)
+	return nil
+}
+
+func (h *fakeHandle) ClassDel(class netlink.Class) error {
+	attrs := class.Attrs()
+
+	classes := make([]netlink.Class, 0, len(h.classes))
+	for _, c := range h.classes {
+		if c.Attrs().LinkIndex != attrs.LinkIndex || c.Attrs().Handle != attrs.Handle {
+			classes = append(classes, c)
+		}
+	}
+
+	h.classes = classes
+	return nil
+}
+
+func (h *fakeHandle) FilterList(link netlink.Link, parent uint32) ([]netlink.Filter, error) {
+	filters := make([]netlink.Filter, 0)
+	for _, filter := range h.filters {
+		if filter.Attrs().LinkIndex == link.Attrs().Index && filter.Attrs().Parent == parent {
+			filters = append(filters, filter)
+		}
+	}
+
+	return filters, nil
+}
+
+func (h *fakeHandle) FilterAdd(filter netlink.Filter) error {
+	h.filters = append(h.filters, filter)
+	return nil
+}
+
+func (h *fakeHandle) FilterDel(filter netlink.Filter) error {
+	attrs := filter.Attrs()
+
+	filters := make([]netlink.Filter, 0, len(h.filters))
+	for _, f := range h.filters {
+		a := f.Attrs()
+		if a.LinkIndex != attrs.LinkIndex || a.Parent != attrs.Parent || a.Priority != attrs.Priority {
+			filters = append(filters, f)
+		}
+	}
+
+	h.filters = filters
+	return nil
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package main
+
+import (
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+func newNetlinkExecutor(dev, ifb string, hashThreshold int, out io.Writer) (executor, error) {
+	return nil, xerrors.New("netlink is only supported on linux")
+}
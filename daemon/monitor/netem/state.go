@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/xerrors"
+)
+
+// filterState is a u32 filter found on a network device alongside the rule
+// that it matches, without the emulated properties.
+type filterState struct {
+	minor int
+	rule  network.Rule
+}
+
+// deviceState is the state of the traffic control of a network device as
+// reported by the tc command.
+type deviceState struct {
+	root    bool
+	ingress bool
+	// classes maps the minor of the classes of the root qdisc to their rate.
+	classes map[int]network.Bandwidth
+	// qdiscs maps the minor of the parent class to the emulated properties
+	// of the netem qdisc.
+	qdiscs  map[int]network.Rule
+	filters []filterState
+}
+
+// readState returns the current state of the network device.
+func (e tcExecutor) readState(dev string) (deviceState, error) {
+	state := deviceState{
+		classes: make(map[int]network.Bandwidth),
+		qdiscs:  make(map[int]network.Rule),
+	}
+
+	out, err := e.query(e.cmd, "qdisc", "show", "dev", dev)
+	if err != nil {
+		return state, xerrors.Errorf("couldn't read qdiscs: %v", err)
+	}
+
+	parseQDiscs(out, &state)
+
+	out, err = e.query(e.cmd, "class", "show", "dev", dev)
+	if err != nil {
+		return state, xerrors.Errorf("couldn't read classes: %v", err)
+	}
+
+	parseClasses(out, &state)
+
+	out, err = e.query(e.cmd, "filter", "show", "dev", dev)
+	if err != nil {
+		return state, xerrors.Errorf("couldn't read filters: %v", err)
+	}
+
+	state.filters, err = parseFilters(out)
+	if err != nil {
+		return state, xerrors.Errorf("couldn't parse filters: %v", err)
+	}
+
+	return state, nil
+}
+
+// parseQDiscs parses the output of the qdisc show command. It looks for the
+// root and ingress qdiscs created by the executor and the netem qdiscs.
+func parseQDiscs(out string, state *deviceState) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "qdisc" {
+			continue
+		}
+
+		switch {
+		case fields[1] == "htb" && fields[2] == "1:" && fields[3] == "root":
+			state.root = true
+		case fields[1] == "ingress" && fields[2] == "ffff:":
+			state.ingress = true
+		case fields[1] == "netem" && fields[3] == "parent":
+			minor, ok := parseMinor(fields[4])
+			if ok {
+				state.qdiscs[minor] = parseNetEm(fields[5:])
+			}
+		}
+	}
+}
+
+// parseNetEm parses the parameters of a netem qdisc.
+func parseNetEm(params []string) network.Rule {
+	rule := network.Rule{}
+
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case "delay":
+			if i+1 < len(params) {
+				rule.Delay.Value, _ = time.ParseDuration(params[i+1])
+			}
+			if i+2 < len(params) {
+				// The jitter is optional thus it is ignored if the next field
+				// is not a duration.
+				rule.Delay.Leeway, _ = time.ParseDuration(params[i+2])
+			}
+		case "loss":
+			if i+1 < len(params) && params[i+1] == "random" {
+				i++
+			}
+			if i+1 < len(params) {
+				value, err := strconv.ParseFloat(strings.TrimSuffix(params[i+1], "%"), 64)
+				if err == nil {
+					rule.Loss.Value = value / 100
+				}
+			}
+		case "rate":
+			if i+1 < len(params) {
+				rule.Upload = parseRate(params[i+1])
+			}
+		}
+	}
+
+	return rule
+}
+
+// parseClasses parses the output of the class show command to find the
+// classes of the root qdisc.
+func parseClasses(out string, state *deviceState) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "class" || fields[1] != "htb" {
+			continue
+		}
+
+		minor, ok := parseMinor(fields[2])
+		if !ok {
+			continue
+		}
+
+		rate := network.Bandwidth{}
+		for i := 3; i < len(fields)-1; i++ {
+			if fields[i] == "rate" {
+				rate = parseRate(fields[i+1])
+			}
+		}
+
+		state.classes[minor] = rate
+	}
+}
+
+// parseRate parses a rate as displayed by the tc command, e.g. 10Mbit.
+func parseRate(str string) network.Bandwidth {
+	units := []struct {
+		suffix string
+		value  uint64
+	}{
+		{"Gbit", network.Gbps},
+		{"Mbit", network.Mbps},
+		{"Kbit", network.Kbps},
+		{"bit", 1},
+	}
+
+	for _, unit := range units {
+		if strings.HasSuffix(str, unit.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSuffix(str, unit.suffix), 64)
+			if err != nil {
+				return network.Bandwidth{}
+			}
+
+			return network.Bandwidth{Value: uint64(value * float64(unit.value))}
+		}
+	}
+
+	return network.Bandwidth{}
+}
+
+// parseMinor returns the minor of a handle of the root qdisc, e.g. 1:10. The
+// tc command reads and displays the handles in hexadecimal but the executor
+// writes them with decimal digits thus the minor is parsed the same way so
+// that it matches the one used to create the handle.
+func parseMinor(handle string) (int, bool) {
+	if !strings.HasPrefix(handle, "1:") {
+		return 0, false
+	}
+
+	minor, err := strconv.ParseInt(strings.TrimPrefix(handle, "1:"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(minor), true
+}
+
+// u32Key is a selector of a u32 filter which compares a word of the packet at
+// the given offset after applying the mask.
+type u32Key struct {
+	value uint32
+	mask  uint32
+	off   string
+}
+
+// parseFilters parses the output of the filter show command. Each u32 filter
+// is followed by its keys which are converted back to the address, the
+// protocol and the port of the rule.
+func parseFilters(out string) ([]filterState, error) {
+	filters := make([]filterState, 0)
+
+	var current *filterState
+	var ipv6 bool
+	var keys []u32Key
+
+	flush := func() {
+		if current != nil {
+			current.rule = makeRuleFromKeys(keys, ipv6)
+			filters = append(filters, *current)
+		}
+
+		current = nil
+		keys = nil
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "filter":
+			flush()
+
+			for i := 1; i < len(fields)-1; i++ {
+				switch fields[i] {
+				case "protocol":
+					ipv6 = fields[i+1] == "ipv6"
+				case "flowid", "classid":
+					minor, ok := parseMinor(fields[i+1])
+					if ok {
+						current = &filterState{minor: minor}
+					}
+				}
+			}
+		case "match":
+			if current == nil || len(fields) < 4 {
+				continue
+			}
+
+			parts := strings.Split(fields[1], "/")
+			if len(parts) != 2 {
+				return nil, xerrors.Errorf("invalid key '%s'", fields[1])
+			}
+
+			value, err := strconv.ParseUint(parts[0], 16, 32)
+			if err != nil {
+				return nil, xerrors.Errorf("invalid key value: %v", err)
+			}
+
+			mask, err := strconv.ParseUint(parts[1], 16, 32)
+			if err != nil {
+				return nil, xerrors.Errorf("invalid key mask: %v", err)
+			}
+
+			keys = append(keys, u32Key{value: uint32(value), mask: uint32(mask), off: fields[3]})
+		}
+	}
+
+	flush()
+
+	return filters, nil
+}
+
+// makeRuleFromKeys converts the keys of a filter created by the executor
+// back to a rule. The address is the destination for the egress filters and
+// the source for the ingress ones.
+func makeRuleFromKeys(keys []u32Key, ipv6 bool) network.Rule {
+	// Offsets of the addresses and of the protocol inside the IP header, and
+	// of the ports in the transport header.
+	addrOffsets := map[string]int{"12": 0, "16": 0}
+	protoOff, protoShift := "8", 16
+	portOff := "20"
+	size := net.IPv4len
+
+	if ipv6 {
+		addrOffsets = map[string]int{
+			"8": 0, "12": 4, "16": 8, "20": 12,
+			"24": 0, "28": 4, "32": 8, "36": 12,
+		}
+		protoOff, protoShift = "4", 8
+		portOff = "40"
+		size = net.IPv6len
+	}
+
+	rule := network.Rule{}
+	addr := make([]byte, size)
+	prefix := 0
+
+	for _, key := range keys {
+		if idx, ok := addrOffsets[key.off]; ok {
+			binary.BigEndian.PutUint32(addr[idx:], key.value)
+			prefix += bits.OnesCount32(key.mask)
+			continue
+		}
+
+		switch {
+		case key.off == protoOff && key.mask == 0xff<<protoShift:
+			num := int(key.value >> protoShift)
+			for name, value := range protocols {
+				if value == num {
+					rule.Protocol = name
+				}
+			}
+		case key.off == portOff && key.mask == 0xffff:
+			rule.Port = uint16(key.value)
+		case key.off == portOff && key.mask == 0xffff0000:
+			rule.Port = uint16(key.value >> 16)
+		}
+	}
+
+	rule.IP = net.IP(addr).String()
+	if prefix < size*8 {
+		rule.IP += "/" + strconv.Itoa(prefix)
+	}
+
+	return rule
+}
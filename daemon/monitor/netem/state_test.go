@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
+)
+
+func TestState_ParseQDiscs(t *testing.T) {
+	state := deviceState{qdiscs: make(map[int]network.Rule)}
+	parseQDiscs(testQDiscs, &state)
+
+	require.True(t, state.root)
+	require.True(t, state.ingress)
+	require.Len(t, state.qdiscs, 2)
+	require.Equal(t, network.Delay{Value: time.Second}, state.qdiscs[20].Delay)
+
+	state = deviceState{qdiscs: make(map[int]network.Rule)}
+	parseQDiscs("qdisc noqueue 0: root refcnt 2\n", &state)
+	require.False(t, state.root)
+	require.False(t, state.ingress)
+}
+
+func TestState_ParseNetEm(t *testing.T) {
+	rule := parseNetEm([]string{"limit", "1000", "delay", "500us", "loss", "random", "1.5%", "rate", "1Kbit"})
+	require.Equal(t, network.Rule{
+		Delay:  network.Delay{Value: 500 * time.Microsecond},
+		Loss:   network.Loss{Value: 0.015},
+		Upload: network.Bandwidth{Value: network.Kbps},
+	}, rule)
+
+	require.Equal(t, network.Rule{}, parseNetEm([]string{"delay"}))
+}
+
+func TestState_ParseRate(t *testing.T) {
+	require.Equal(t, 2*network.Gbps, parseRate("2Gbit").Value)
+	require.Equal(t, 1500*network.Kbps, parseRate("1.5Mbit").Value)
+	require.Equal(t, uint64(800), parseRate("800bit").Value)
+	require.Equal(t, uint64(0), parseRate("abcbit").Value)
+	require.Equal(t, uint64(0), parseRate("10Mbps").Value)
+}
+
+func TestState_ParseMinor(t *testing.T) {
+	minor, ok := parseMinor("1:10")
+	require.True(t, ok)
+	require.Equal(t, 10, minor)
+
+	_, ok = parseMinor("ffff:")
+	require.False(t, ok)
+
+	_, ok = parseMinor("1:a")
+	require.False(t, ok)
+}
+
+func TestState_ParseFilters(t *testing.T) {
+	filters, err := parseFilters(`filter parent 1: protocol ipv6 pref 2 u32 chain 0 fh 800::800 flowid 1:10
+  match fd000000/ffffffff at 24
+  match 00000000/ffffffff at 28
+  match 00000000/ffffffff at 32
+  match 00000001/ffffffff at 36
+  match 00000600/0000ff00 at 4
+  match 00500000/ffff0000 at 40
+`)
+	require.NoError(t, err)
+	require.Equal(t, []filterState{{
+		minor: 10,
+		rule:  network.Rule{IP: "fd00::1", Protocol: network.TCP, Port: 80},
+	}}, filters)
+
+	filters, err = parseFilters(`filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::800 flowid 1:10
+  match 0a000000/ff000000 at 16
+`)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.0/8", filters[0].rule.IP)
+
+	_, err = parseFilters("filter flowid 1:10\n  match abc at 16")
+	require.EqualError(t, err, "invalid key 'abc'")
+
+	_, err = parseFilters("filter flowid 1:10\n  match xyz/ffffffff at 16")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid key value: ")
+
+	_, err = parseFilters("filter flowid 1:10\n  match ffffffff/xyz at 16")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid key mask: ")
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/xerrors"
+)
+
+// tcExecutor is the executor that applies the rules by running the tc
+// command. The ip command is used to create the intermediate device of the
+// ingress traffic.
+type tcExecutor struct {
+	dev   string
+	cmd   string
+	ifb   string
+	ipCmd string
+	// hashThreshold is the number of hosts of a protocol family from which
+	// their filters are stored in a hash table.
+	hashThreshold int
+	out           io.Writer
+}
+
+type command []string
+
+// Execute takes a bunch of rules and apply them to the network device by
+// using the tc command. The rules can be applied again on a device that
+// already emulates previous ones as the differences are computed against the
+// current state of the device.
+func (e tcExecutor) Execute(rules []network.Rule) error {
+	rules, err := sortRules(rules)
+	if err != nil {
+		return xerrors.Errorf("invalid rules: %v", err)
+	}
+
+	state, err := e.readState(e.dev)
+	if err != nil {
+		return xerrors.Errorf("couldn't read the state of %s: %v", e.dev, err)
+	}
+
+	commands := make([]command, 0)
+
+	// Replace the root by a HTB queueing discpline and also create a single
+	// class that will allow the flows to be shared correctly. The filters are
+	// flushed as they are created again afterwards.
+	commands = append(commands, e.makeRoot(), e.makeParent(), e.makeFlush(e.dev, "1:"))
+
+	// Then create a path for each set of emulated properties and direct the
+	// targets (single IP or a range, optionally restricted to a protocol
+	// and a port) to it.
+	classes, filters, err := groupRules(rules, true)
+	if err != nil {
+		return xerrors.Errorf("couldn't group the rules: %v", err)
+	}
+
+	for _, class := range classes {
+		commands = append(
+			commands,
+			// Define the new flow attached to the parent class created
+			// previously.
+			e.makeClass(class.minor),
+			// Attach the first queuing discpline (delay, loss, etc...).
+			e.makeQDisc(class.minor, class.minor+1, class.props),
+		)
+	}
+
+	// Define which IPs can go through each flow.
+	commands = append(commands, e.makeFilters(e.dev, filters, "dst")...)
+
+	// Finally remove the classes of the previous rules that are not used
+	// anymore.
+	commands = append(commands, e.makeStaleClasses(e.dev, state, classes)...)
+
+	err = e.execAll(e.cmd, commands)
+	if err != nil {
+		return err
+	}
+
+	if hasDownload(rules) {
+		err := e.executeIngress(rules)
+		if err != nil {
+			return xerrors.Errorf("couldn't shape ingress: %v", err)
+		}
+	} else if state.ingress {
+		err := e.execAll(e.cmd, []command{e.makeIngressDel()})
+		if err != nil {
+			return xerrors.Errorf("couldn't remove ingress: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Reset removes the queuing disciplines created to emulate the rules and
+// the intermediate device for the ingress traffic.
+func (e tcExecutor) Reset() error {
+	state, err := e.readState(e.dev)
+	if err != nil {
+		return xerrors.Errorf("couldn't read the state of %s: %v", e.dev, err)
+	}
+
+	commands := make([]command, 0, 2)
+	if state.root {
+		commands = append(commands, e.makeRootDel())
+	}
+
+	if state.ingress {
+		commands = append(commands, e.makeIngressDel())
+	}
+
+	err = e.execAll(e.cmd, commands)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.query(e.ipCmd, "link", "show", "dev", e.ifb)
+	if err == nil {
+		err = e.execAll(e.ipCmd, []command{e.makeIFBDel()})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Show returns the rules currently applied to the network device.
+func (e tcExecutor) Show() ([]network.Rule, error) {
+	state, err := e.readState(e.dev)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't read the state of %s: %v", e.dev, err)
+	}
+
+	rules := make([]network.Rule, 0, len(state.filters))
+	for _, filter := range state.filters {
+		props := state.qdiscs[filter.minor]
+
+		rule := filter.rule
+		rule.Delay = props.Delay
+		rule.Loss = props.Loss
+		rule.Upload = props.Upload
+
+		rules = append(rules, rule)
+	}
+
+	if !state.ingress {
+		return rules, nil
+	}
+
+	ifbState, err := e.readState(e.ifb)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't read the state of %s: %v", e.ifb, err)
+	}
+
+	for _, filter := range ifbState.filters {
+		rules = mergeDownload(rules, filter.rule, ifbState.classes[filter.minor])
+	}
+
+	return rules, nil
+}
+
+// executeIngress redirects the incoming traffic of the network device to an
+// intermediate functional block (IFB) so that it can be shaped as egress
+// traffic. Each source with a download bandwidth gets its own class.
+func (e tcExecutor) executeIngress(rules []network.Rule) error {
+	links := []command{e.makeIFBUp()}
+
+	_, err := e.query(e.ipCmd, "link", "show", "dev", e.ifb)
+	if err != nil {
+		// The device does not exist yet.
+		links = append([]command{e.makeIFB()}, links...)
+	}
+
+	err = e.execAll(e.ipCmd, links)
+	if err != nil {
+		return err
+	}
+
+	state, err := e.readState(e.ifb)
+	if err != nil {
+		return xerrors.Errorf("couldn't read the state of %s: %v", e.ifb, err)
+	}
+
+	commands := []command{
+		e.makeIngress(),
+		e.makeFlush(e.dev, "ffff:"),
+		e.makeRedirect(),
+		e.makeIFBRoot(),
+		e.makeIFBParent(),
+		e.makeFlush(e.ifb, "1:"),
+	}
+
+	classes, filters, err := groupRules(rules, false)
+	if err != nil {
+		return xerrors.Errorf("couldn't group the rules: %v", err)
+	}
+
+	for _, class := range classes {
+		// The class limits the rate of the packets coming from the sources.
+		commands = append(commands, e.makeIFBClass(class.minor, class.props.Download))
+	}
+
+	commands = append(commands, e.makeFilters(e.ifb, filters, "src")...)
+
+	commands = append(commands, e.makeStaleClasses(e.ifb, state, classes)...)
+
+	return e.execAll(e.cmd, commands)
+}
+
+// execAll runs the commands in order and stops at the first failure.
+func (e tcExecutor) execAll(name string, commands []command) error {
+	for _, args := range commands {
+		err := e.execCmd(name, args)
+		if err != nil {
+			return xerrors.Errorf("%s command failed: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// query runs a command that reads the state of a device and returns its
+// output.
+func (e tcExecutor) query(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", xerrors.Errorf("cmd failed: %v", err)
+	}
+
+	return string(out), nil
+}
+
+func (e tcExecutor) execCmd(name string, args []string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = e.out
+	cmd.Stdout = e.out
+
+	fmt.Fprintln(e.out, cmd.String())
+
+	err := cmd.Run()
+	if err != nil {
+		return xerrors.Errorf("cmd failed: %v", err)
+	}
+
+	return nil
+}
+
+func (e tcExecutor) makeRoot() []string {
+	cmd := fmt.Sprintf("qdisc replace dev %s root handle 1: htb", e.dev)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeRootDel() []string {
+	cmd := fmt.Sprintf("qdisc del dev %s root", e.dev)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeParent() []string {
+	cmd := fmt.Sprintf("class replace dev %s parent 1: classid 1:1 htb rate 1000Mbps", e.dev)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeClass(minor int) []string {
+	cmd := fmt.Sprintf("class replace dev %s parent 1:1 classid 1:%d htb rate 1000Mbps", e.dev, minor)
+	return strings.Split(cmd, " ")
+}
+
+// makeStaleClasses returns the commands to delete the classes of the device
+// that are not used by the current rules.
+func (e tcExecutor) makeStaleClasses(dev string, state deviceState, classes []trafficClass) []command {
+	used := map[int]struct{}{1: {}}
+	for _, class := range classes {
+		used[class.minor] = struct{}{}
+	}
+
+	stale := make([]int, 0)
+	for minor := range state.classes {
+		if _, ok := used[minor]; !ok {
+			stale = append(stale, minor)
+		}
+	}
+
+	sort.Ints(stale)
+
+	commands := make([]command, len(stale))
+	for i, minor := range stale {
+		cmd := fmt.Sprintf("class del dev %s classid 1:%d", dev, minor)
+		commands[i] = strings.Split(cmd, " ")
+	}
+
+	return commands
+}
+
+// makeFlush returns the command to delete every filter of the parent. It
+// succeeds even when the parent does not have any filter.
+func (e tcExecutor) makeFlush(dev, parent string) []string {
+	cmd := fmt.Sprintf("filter del dev %s parent %s", dev, parent)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeQDisc(minor, major int, rule network.Rule) []string {
+	cmd := fmt.Sprintf("qdisc replace dev %s parent 1:%d handle %d: %s", e.dev, minor, major, makeNetEm(rule))
+	return strings.Split(cmd, " ")
+}
+
+func makeNetEm(rule network.Rule) string {
+	params := []string{"netem"}
+	for _, param := range []fmt.Stringer{rule.Delay, rule.Loss, rule.Upload} {
+		// Empty parameters are skipped so that the command does not end up
+		// with empty arguments.
+		if str := param.String(); str != "" {
+			params = append(params, str)
+		}
+	}
+
+	return strings.Join(params, " ")
+}
+
+// makeFilters returns the commands to create the filters of the device where
+// the direction is either the destination or the source of the packets. The
+// hosts are stored in hash tables when they are numerous enough.
+func (e tcExecutor) makeFilters(dev string, filters []trafficFilter, dir string) []command {
+	head, tables, tail := splitFilters(filters, e.hashThreshold)
+
+	commands := make([]command, 0, len(filters)+2*len(tables))
+	for _, filter := range head {
+		commands = append(commands, makeFilter(dev, filter, dir))
+	}
+
+	for _, table := range tables {
+		commands = append(commands, makeHashTable(dev, table), makeHashLink(dev, table, dir))
+
+		for _, filter := range table.filters {
+			commands = append(commands, makeHashFilter(dev, table, filter, dir))
+		}
+	}
+
+	for _, filter := range tail {
+		commands = append(commands, makeFilter(dev, filter, dir))
+	}
+
+	return commands
+}
+
+func makeFilter(dev string, filter trafficFilter, dir string) []string {
+	cmd := fmt.Sprintf("filter add dev %s %s u32 %s flowid 1:%d",
+		dev, makeFilterProto(filter.rule.IsIPv6()), makeMatch(filter.rule, dir), filter.minor)
+	return strings.Split(cmd, " ")
+}
+
+func makeHashTable(dev string, table hashTable) []string {
+	cmd := fmt.Sprintf("filter add dev %s %s handle %x: u32 divisor %d",
+		dev, makeFilterProto(table.ipv6), table.handle, hashBuckets)
+	return strings.Split(cmd, " ")
+}
+
+// makeHashLink returns the command of the filter that sends every packet to
+// the bucket of the table given by the last byte of the address.
+func makeHashLink(dev string, table hashTable, dir string) []string {
+	cmd := fmt.Sprintf("filter add dev %s %s u32 match u32 0 0 hashkey mask 0x000000ff at %d link %x:",
+		dev, makeFilterProto(table.ipv6), hashOffset(table.ipv6, dir == "dst"), table.handle)
+	return strings.Split(cmd, " ")
+}
+
+func makeHashFilter(dev string, table hashTable, filter trafficFilter, dir string) []string {
+	cmd := fmt.Sprintf("filter add dev %s %s u32 ht %x:%x: %s flowid 1:%d", dev, makeFilterProto(table.ipv6),
+		table.handle, hashBucket(filter.rule), makeMatch(filter.rule, dir), filter.minor)
+	return strings.Split(cmd, " ")
+}
+
+// makeFilterProto returns the protocol and the parent of a filter. The
+// priority differs between the protocols as the filters of a given priority
+// must share the same protocol.
+func makeFilterProto(ipv6 bool) string {
+	if ipv6 {
+		return "protocol ipv6 parent 1:0 prio 2"
+	}
+
+	return "protocol ip parent 1:0 prio 1"
+}
+
+func makeMatchProto(ipv6 bool) string {
+	if ipv6 {
+		return "ip6"
+	}
+
+	return "ip"
+}
+
+// makeMatch returns the u32 selectors of a rule where the direction is either
+// the destination or the source of the packets. The port of the rule is the
+// port of the distant address thus it is the destination port of the outgoing
+// traffic and the source port of the incoming traffic.
+func makeMatch(rule network.Rule, dir string) string {
+	port := "dport"
+	if dir == "src" {
+		port = "sport"
+	}
+
+	proto := makeMatchProto(rule.IsIPv6())
+
+	selectors := []string{fmt.Sprintf("match %s %s %s", proto, dir, rule.MatchAddr())}
+
+	if rule.Protocol != "" {
+		selectors = append(selectors, fmt.Sprintf("match %s protocol %d 0xff", proto, protocols[rule.Protocol]))
+	}
+
+	if rule.Port != 0 {
+		selectors = append(selectors, fmt.Sprintf("match %s %s %d 0xffff", proto, port, rule.Port))
+	}
+
+	return strings.Join(selectors, " ")
+}
+
+func (e tcExecutor) makeIFB() []string {
+	cmd := fmt.Sprintf("link add %s type ifb", e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIFBUp() []string {
+	cmd := fmt.Sprintf("link set dev %s up", e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIFBDel() []string {
+	cmd := fmt.Sprintf("link del dev %s", e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIngress() []string {
+	cmd := fmt.Sprintf("qdisc replace dev %s handle ffff: ingress", e.dev)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIngressDel() []string {
+	cmd := fmt.Sprintf("qdisc del dev %s ingress", e.dev)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeRedirect() []string {
+	cmd := fmt.Sprintf("filter add dev %s parent ffff: protocol all u32 match u32 0 0 action mirred egress redirect dev %s", e.dev, e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIFBRoot() []string {
+	cmd := fmt.Sprintf("qdisc replace dev %s root handle 1: htb", e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIFBParent() []string {
+	cmd := fmt.Sprintf("class replace dev %s parent 1: classid 1:1 htb rate 1000Mbps", e.ifb)
+	return strings.Split(cmd, " ")
+}
+
+func (e tcExecutor) makeIFBClass(minor int, bw network.Bandwidth) []string {
+	cmd := fmt.Sprintf("class replace dev %s parent 1:1 classid 1:%d htb %s", e.ifb, minor, bw)
+	return strings.Split(cmd, " ")
+}
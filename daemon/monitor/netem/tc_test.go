@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
+)
+
+func TestExecutor_Execute(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "echo",
+		out: out,
+	}
+
+	err := exec.Execute(testMakeRules())
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(out)
+	for _, cmd := range testExpectedCommands {
+		require.True(t, scanner.Scan()) // ignore the log
+		require.True(t, scanner.Scan())
+		require.Equal(t, cmd, scanner.Text())
+	}
+}
+
+func TestExecutor_ExecuteIngress(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev:   "eth0",
+		cmd:   "echo",
+		ifb:   "ifb0",
+		ipCmd: "echo",
+		out:   out,
+	}
+
+	rules := []network.Rule{
+		network.NewBandwidthRule("127.0.0.1", network.Mbps, 2*network.Mbps),
+		network.NewDelayRule("127.0.0.2", time.Second),
+	}
+
+	err := exec.Execute(rules)
+	require.NoError(t, err)
+
+	scanner := bufio.NewScanner(out)
+	for i := 0; i < 18; i++ {
+		// Skip the egress commands and their logs.
+		require.True(t, scanner.Scan())
+	}
+
+	for _, cmd := range testExpectedIngressCommands {
+		require.True(t, scanner.Scan()) // ignore the log
+		require.True(t, scanner.Scan())
+		require.Equal(t, cmd, scanner.Text())
+	}
+
+	require.False(t, scanner.Scan())
+}
+
+func TestExecutor_ExecuteIngressFailure(t *testing.T) {
+	exec := tcExecutor{
+		dev:   "eth0",
+		cmd:   "echo",
+		ifb:   "ifb0",
+		ipCmd: "definitely not a valid command",
+		out:   ioutil.Discard,
+	}
+
+	err := exec.Execute([]network.Rule{network.NewBandwidthRule("127.0.0.1", 0, network.Mbps)})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't shape ingress: ")
+}
+
+func TestExecutor_ExecuteIPv6(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "echo",
+		out: out,
+	}
+
+	rules := []network.Rule{
+		network.NewDelayRule("fd00::1", time.Second),
+		network.NewLossRule("10.0.0.0/8", 0.5),
+	}
+
+	err := exec.Execute(rules)
+	require.NoError(t, err)
+	require.Contains(t, out.String(),
+		"filter add dev eth0 protocol ipv6 parent 1:0 prio 2 u32 match ip6 dst fd00::1/128 flowid 1:10")
+	require.Contains(t, out.String(),
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 10.0.0.0/8 flowid 1:20")
+}
+
+func TestExecutor_ExecutePort(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev:   "eth0",
+		cmd:   "echo",
+		ifb:   "ifb0",
+		ipCmd: "echo",
+		out:   out,
+	}
+
+	rules := []network.Rule{
+		network.NewDelayRule("127.0.0.1", time.Second),
+		{IP: "127.0.0.1", Protocol: network.UDP, Port: 7000, Delay: network.Delay{Value: 2 * time.Second}},
+		{IP: "127.0.0.1", Protocol: network.TCP, Download: network.Bandwidth{Value: network.Mbps}},
+	}
+
+	err := exec.Execute(rules)
+	require.NoError(t, err)
+
+	// Specific rules must be defined first so that they take precedence.
+	require.Contains(t, out.String(), "filter add dev eth0 protocol ip parent 1:0 prio 1 u32 "+
+		"match ip dst 127.0.0.1/32 match ip protocol 17 0xff match ip dport 7000 0xffff flowid 1:10")
+	require.Contains(t, out.String(), "filter add dev eth0 protocol ip parent 1:0 prio 1 u32 "+
+		"match ip dst 127.0.0.1/32 match ip protocol 6 0xff flowid 1:20")
+	require.Contains(t, out.String(), "filter add dev eth0 protocol ip parent 1:0 prio 1 u32 "+
+		"match ip dst 127.0.0.1/32 flowid 1:30")
+	require.Contains(t, out.String(), "filter add dev ifb0 protocol ip parent 1:0 prio 1 u32 "+
+		"match ip src 127.0.0.1/32 match ip protocol 6 0xff flowid 1:10")
+}
+
+func TestExecutor_ExecuteHashed(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev:           "eth0",
+		cmd:           "echo",
+		ifb:           "ifb0",
+		ipCmd:         "echo",
+		hashThreshold: 2,
+		out:           out,
+	}
+
+	rules := []network.Rule{
+		network.NewDelayRule("10.0.0.0/8", time.Second),
+		network.NewDelayRule("10.0.0.1", time.Second),
+		network.NewBandwidthRule("10.0.1.2", 0, network.Mbps),
+		{IP: "10.0.0.1", Protocol: network.UDP, Port: 7000, Delay: network.Delay{Value: time.Second}},
+		network.NewDelayRule("fd00::1", time.Second),
+	}
+
+	err := exec.Execute(rules)
+	require.NoError(t, err)
+
+	// The specific filter comes first, then the hash table of the IPv4
+	// hosts, the IPv6 host as a single one is not enough for a table, and
+	// the subnet.
+	expected := []string{
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 10.0.0.1/32 " +
+			"match ip protocol 17 0xff match ip dport 7000 0xffff flowid 1:10",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 handle 2: u32 divisor 256",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match u32 0 0 hashkey mask 0x000000ff at 16 link 2:",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 ht 2:1: match ip dst 10.0.0.1/32 flowid 1:10",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 ht 2:2: match ip dst 10.0.1.2/32 flowid 1:20",
+		"filter add dev eth0 protocol ipv6 parent 1:0 prio 2 u32 match ip6 dst fd00::1/128 flowid 1:10",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 10.0.0.0/8 flowid 1:10",
+	}
+
+	prev := -1
+	for _, cmd := range expected {
+		idx := strings.Index(out.String(), "\n"+cmd+"\n")
+		require.True(t, idx > prev, cmd)
+		prev = idx
+	}
+
+	exec.hashThreshold = 0
+	out.Reset()
+	err = exec.Execute(rules)
+	require.NoError(t, err)
+	require.NotContains(t, out.String(), "divisor")
+
+	// The hosts still take precedence over the subnet without the tables.
+	host := strings.Index(out.String(), "match ip dst 10.0.0.1/32 flowid 1:10")
+	subnet := strings.Index(out.String(), "match ip dst 10.0.0.0/8 flowid 1:10")
+	require.True(t, host >= 0 && host < subnet)
+}
+
+func TestExecutor_ExecuteInvalidRules(t *testing.T) {
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "echo",
+		out: ioutil.Discard,
+	}
+
+	err := exec.Execute([]network.Rule{{IP: "127.0.0.1", Protocol: "sctp"}})
+	require.EqualError(t, err, "invalid rules: unknown protocol 'sctp'")
+
+	err = exec.Execute([]network.Rule{{IP: "127.0.0.1", Port: 80}})
+	require.EqualError(t, err, "invalid rules: port 80 requires tcp or udp")
+}
+
+func TestExecutor_ExecuteTooManyClasses(t *testing.T) {
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "echo",
+		out: out,
+	}
+
+	// Each rule has a distinct delay and thus its own class.
+	rules := make([]network.Rule, 1000)
+	for i := range rules {
+		rules[i] = network.NewDelayRule(fmt.Sprintf("10.0.%d.%d", i/256, i%256), time.Duration(i+1)*time.Millisecond)
+	}
+
+	err := exec.Execute(rules)
+	require.EqualError(t, err, "couldn't group the rules: too many classes for the handle 10000")
+	// The handles are checked before any change is made to the device.
+	require.NotContains(t, out.String(), "qdisc replace")
+}
+
+func TestExecutor_ExecuteDiff(t *testing.T) {
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0": testQDiscs,
+		"class show dev eth0": testClasses,
+	}, false)
+	defer clean()
+
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev:   "eth0",
+		cmd:   cmd,
+		ifb:   "ifb0",
+		ipCmd: "echo",
+		out:   out,
+	}
+
+	err := exec.Execute([]network.Rule{network.NewDelayRule("127.0.0.1", time.Second)})
+	require.NoError(t, err)
+
+	// The classes of the previous rules are removed, alongside the ingress
+	// qdisc as no download bandwidth is set.
+	require.Contains(t, out.String(), "class del dev eth0 classid 1:20\n")
+	require.NotContains(t, out.String(), "class del dev eth0 classid 1:10\n")
+	require.Contains(t, out.String(), "qdisc del dev eth0 ingress\n")
+}
+
+func TestExecutor_ExecuteFailure(t *testing.T) {
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "definitely not a valid command",
+		out: ioutil.Discard,
+	}
+
+	err := exec.Execute(testMakeRules())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the state of eth0: ")
+
+	cmd, clean := makeFakeCommand(t, testEmptyState, true)
+	defer clean()
+
+	exec.cmd = cmd
+	err = exec.Execute(testMakeRules())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), " command failed:")
+}
+
+func TestExecutor_Reset(t *testing.T) {
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0": testQDiscs,
+	}, false)
+	defer clean()
+
+	out := new(bytes.Buffer)
+	exec := tcExecutor{
+		dev:   "eth0",
+		cmd:   cmd,
+		ifb:   "ifb0",
+		ipCmd: "echo",
+		out:   out,
+	}
+
+	err := exec.Reset()
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "qdisc del dev eth0 root\n")
+	require.Contains(t, out.String(), "qdisc del dev eth0 ingress\n")
+	require.Contains(t, out.String(), "link del dev ifb0\n")
+
+	// Nothing to remove.
+	out.Reset()
+	exec.cmd = "echo"
+	exec.ipCmd = "false"
+	err = exec.Reset()
+	require.NoError(t, err)
+	require.Equal(t, "", out.String())
+}
+
+func TestExecutor_ResetFailure(t *testing.T) {
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "definitely not a valid command",
+		out: ioutil.Discard,
+	}
+
+	err := exec.Reset()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the state of eth0: ")
+
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0":  testQDiscs,
+		"class show dev eth0":  "",
+		"filter show dev eth0": "",
+	}, true)
+	defer clean()
+
+	exec.cmd = cmd
+	err = exec.Reset()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), " command failed:")
+}
+
+func TestExecutor_Show(t *testing.T) {
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0":  testQDiscs,
+		"class show dev eth0":  testClasses,
+		"filter show dev eth0": testFilters,
+		"class show dev ifb0":  testIFBClasses,
+		"filter show dev ifb0": testIFBFilters,
+	}, false)
+	defer clean()
+
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: cmd,
+		ifb: "ifb0",
+		out: ioutil.Discard,
+	}
+
+	rules, err := exec.Show()
+	require.NoError(t, err)
+	require.Equal(t, []network.Rule{
+		{
+			IP:       "10.0.0.2",
+			Protocol: network.UDP,
+			Port:     7000,
+			Delay:    network.Delay{Value: 50 * time.Millisecond, Leeway: 5 * time.Millisecond},
+			Loss:     network.Loss{Value: 0.1},
+		},
+		{
+			IP:       "10.0.0.2",
+			Delay:    network.Delay{Value: time.Second},
+			Upload:   network.Bandwidth{Value: 10 * network.Mbps},
+			Download: network.Bandwidth{Value: 2 * network.Mbps},
+		},
+		{
+			IP:       "fd00::/64",
+			Download: network.Bandwidth{Value: 500 * network.Kbps},
+		},
+	}, rules)
+}
+
+func TestExecutor_ShowFailure(t *testing.T) {
+	exec := tcExecutor{
+		dev: "eth0",
+		cmd: "definitely not a valid command",
+		ifb: "ifb0",
+		out: ioutil.Discard,
+	}
+
+	_, err := exec.Show()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the state of eth0: ")
+
+	cmd, clean := makeFakeCommand(t, map[string]string{
+		"qdisc show dev eth0":  testQDiscs,
+		"class show dev eth0":  "",
+		"filter show dev eth0": "",
+	}, true)
+	defer clean()
+
+	exec.cmd = cmd
+	_, err = exec.Show()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read the state of ifb0: ")
+}
+
+func TestExecutor_MakeNetEm(t *testing.T) {
+	rule := network.Rule{
+		Delay:  network.Delay{Value: 50 * time.Millisecond, Leeway: 5 * time.Millisecond},
+		Loss:   network.Loss{Value: 0.01},
+		Upload: network.Bandwidth{Value: 10 * network.Mbps},
+	}
+	require.Equal(t, "netem delay 50ms 5ms loss 1.00% rate 10000000bit", makeNetEm(rule))
+
+	rule = network.NewBandwidthRule("127.0.0.1", network.Kbps, 0)
+	require.Equal(t, "netem rate 1000bit", makeNetEm(rule))
+}
+
+var testExpectedCommands = []string{
+	// Root
+	"qdisc replace dev eth0 root handle 1: htb",
+	"class replace dev eth0 parent 1: classid 1:1 htb rate 1000Mbps",
+	"filter del dev eth0 parent 1:",
+	// Delay
+	"class replace dev eth0 parent 1:1 classid 1:10 htb rate 1000Mbps",
+	"qdisc replace dev eth0 parent 1:10 handle 11: netem delay 1000ms",
+	// Loss
+	"class replace dev eth0 parent 1:1 classid 1:20 htb rate 1000Mbps",
+	"qdisc replace dev eth0 parent 1:20 handle 21: netem loss 50.00%",
+	// Filters
+	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.1/32 flowid 1:10",
+	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.2/32 flowid 1:10",
+	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.3/32 flowid 1:20",
+	"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 127.0.0.4/32 flowid 1:10",
+}
+
+var testExpectedIngressCommands = []string{
+	"link set dev ifb0 up",
+	"qdisc replace dev eth0 handle ffff: ingress",
+	"filter del dev eth0 parent ffff:",
+	"filter add dev eth0 parent ffff: protocol all u32 match u32 0 0 action mirred egress redirect dev ifb0",
+	"qdisc replace dev ifb0 root handle 1: htb",
+	"class replace dev ifb0 parent 1: classid 1:1 htb rate 1000Mbps",
+	"filter del dev ifb0 parent 1:",
+	"class replace dev ifb0 parent 1:1 classid 1:10 htb rate 2000000bit",
+	"filter add dev ifb0 protocol ip parent 1:0 prio 1 u32 match ip src 127.0.0.1/32 flowid 1:10",
+}
+
+func testMakeRules() []network.Rule {
+	return []network.Rule{
+		network.NewDelayRule("127.0.0.1", time.Second),
+		network.NewDelayRule("127.0.0.2", time.Second),
+		network.NewDelayRule("127.0.0.2", time.Second),
+		network.NewLossRule("127.0.0.3", 0.5),
+		network.NewDelayRule("127.0.0.4", time.Second),
+	}
+}
+
+var testEmptyState = map[string]string{
+	"qdisc show dev eth0":  "",
+	"class show dev eth0":  "",
+	"filter show dev eth0": "",
+}
+
+const testQDiscs = `qdisc htb 1: root refcnt 2 r2q 10 default 0 direct_packets_stat 0 direct_qlen 1000
+qdisc netem 11: parent 1:10 limit 1000 delay 50ms  5ms loss 10%
+qdisc netem 21: parent 1:20 limit 1000 delay 1s rate 10Mbit
+qdisc ingress ffff: parent ffff:fff1 ----------------
+`
+
+const testClasses = `class htb 1:1 root rate 1Gbit ceil 1Gbit burst 1375b cburst 1375b
+class htb 1:10 parent 1:1 leaf 11: prio 0 rate 1Gbit ceil 1Gbit burst 1375b cburst 1375b
+class htb 1:20 parent 1:1 leaf 21: prio 0 rate 1Gbit ceil 1Gbit burst 1375b cburst 1375b
+`
+
+const testFilters = `filter parent 1: protocol ip pref 1 u32 chain 0
+filter parent 1: protocol ip pref 1 u32 chain 0 fh 800: ht divisor 1
+filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::800 order 2048 key ht 800 bkt 0 flowid 1:10 not_in_hw
+  match 0a000002/ffffffff at 16
+  match 00110000/00ff0000 at 8
+  match 00001b58/0000ffff at 20
+filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::801 order 2049 key ht 800 bkt 0 flowid 1:20 not_in_hw
+  match 0a000002/ffffffff at 16
+`
+
+const testIFBClasses = `class htb 1:1 root rate 1Gbit ceil 1Gbit burst 1375b cburst 1375b
+class htb 1:10 parent 1:1 prio 0 rate 2Mbit ceil 2Mbit burst 1600b cburst 1600b
+class htb 1:20 parent 1:1 prio 0 rate 500Kbit ceil 500Kbit burst 1600b cburst 1600b
+`
+
+const testIFBFilters = `filter parent 1: protocol ip pref 1 u32 chain 0 fh 800::800 order 2048 key ht 800 bkt 0 flowid 1:10 not_in_hw
+  match 0a000002/ffffffff at 12
+filter parent 1: protocol ipv6 pref 2 u32 chain 0 fh 801::800 order 2048 key ht 801 bkt 0 flowid 1:20 not_in_hw
+  match fd000000/ffffffff at 8
+  match 00000000/ffffffff at 12
+`
+
+// makeFakeCommand writes a script that prints the given outputs for the
+// matching arguments. Otherwise it either fails, or echoes the arguments of
+// the commands and prints nothing for the unknown states.
+func makeFakeCommand(t *testing.T, outputs map[string]string, fail bool) (string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "netem-test")
+	require.NoError(t, err)
+
+	script := new(strings.Builder)
+	fmt.Fprintln(script, "#!/bin/sh")
+	fmt.Fprintln(script, "case \"$*\" in")
+	for args, out := range outputs {
+		fmt.Fprintf(script, "\"%s\")\ncat <<'EOF'\n%s\nEOF\n;;\n", args, out)
+	}
+
+	if fail {
+		fmt.Fprintln(script, "*) exit 1 ;;")
+	} else {
+		fmt.Fprintln(script, "*\" show \"*) ;;")
+		fmt.Fprintln(script, "*) echo \"$@\" ;;")
+	}
+	fmt.Fprintln(script, "esac")
+
+	path := filepath.Join(dir, "tc")
+	err = ioutil.WriteFile(path, []byte(script.String()), 0755)
+	require.NoError(t, err)
+
+	return path, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+var errNoMatch = errors.New("missing substring match")
+
+// peerTraffic is the number of bytes exchanged with a distant address.
+type peerTraffic struct {
+	Rx uint64
+	Tx uint64
+}
+
+// netCounters reads the counters of the network namespace of the monitor
+// which is shared with the pod. The traffic of the peers is read from the
+// connection tracking table that only keeps the open connections, so the
+// totals are accumulated from one reading to the next.
+type netCounters struct {
+	procPath   string
+	localAddrs func() ([]net.Addr, error)
+	conns      map[string]peerTraffic
+	peers      map[string]peerTraffic
+}
+
+func newNetCounters() *netCounters {
+	return &netCounters{
+		procPath:   "/proc",
+		localAddrs: net.InterfaceAddrs,
+		conns:      make(map[string]peerTraffic),
+		peers:      make(map[string]peerTraffic),
+	}
+}
+
+// gather fills the sample with the network counters of the pod.
+func (nc *netCounters) gather(s *sample) error {
+	netstat, err := nc.readInterface(DockerNetworkInterface)
+	if err != nil {
+		return fmt.Errorf("couldn't read the interface: %w", err)
+	}
+
+	s.Networks = make(map[string]types.NetworkStats)
+	s.Networks[DockerNetworkInterface] = netstat
+
+	s.Retransmissions, err = nc.readRetransmissions()
+	if err != nil {
+		return fmt.Errorf("couldn't read the retransmissions: %w", err)
+	}
+
+	s.Peers, err = nc.readPeers()
+	if err != nil {
+		return fmt.Errorf("couldn't read the connections: %w", err)
+	}
+
+	return nil
+}
+
+// enableAccounting tries to enable the accounting of the bytes of each
+// connection which is disabled by default.
+func (nc *netCounters) enableAccounting() error {
+	path := filepath.Join(nc.procPath, "sys", "net", "netfilter", "nf_conntrack_acct")
+
+	return ioutil.WriteFile(path, []byte("1"), 0644)
+}
+
+// readInterface returns the counters of the interface found in the device
+// statistics.
+func (nc *netCounters) readInterface(name string) (types.NetworkStats, error) {
+	stats := types.NetworkStats{}
+
+	file, err := os.Open(filepath.Join(nc.procPath, "net", "dev"))
+	if err != nil {
+		return stats, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != name {
+			continue
+		}
+
+		// Receive: bytes packets errs drop fifo frame compressed multicast
+		// Transmit: bytes packets errs drop fifo colls carrier compressed
+		fields := strings.Fields(parts[1])
+		if len(fields) < 12 {
+			return stats, fmt.Errorf("invalid line for '%s'", name)
+		}
+
+		values := make([]uint64, 12)
+		for i := range values {
+			values[i], err = strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return stats, err
+			}
+		}
+
+		stats.RxBytes = values[0]
+		stats.RxPackets = values[1]
+		stats.RxErrors = values[2]
+		stats.RxDropped = values[3]
+		stats.TxBytes = values[8]
+		stats.TxPackets = values[9]
+		stats.TxErrors = values[10]
+		stats.TxDropped = values[11]
+
+		return stats, nil
+	}
+
+	return stats, errNoMatch
+}
+
+// readRetransmissions returns the number of TCP segments retransmitted.
+func (nc *netCounters) readRetransmissions() (uint64, error) {
+	file, err := os.Open(filepath.Join(nc.procPath, "net", "snmp"))
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	// The protocol has a first line with the names of the counters followed
+	// by a second one with the values.
+	var header []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+
+		if header == nil {
+			header = fields
+			continue
+		}
+
+		for i, name := range header {
+			if name == "RetransSegs" && i < len(fields) {
+				return strconv.ParseUint(fields[i], 10, 64)
+			}
+		}
+	}
+
+	return 0, errNoMatch
+}
+
+// readPeers updates the totals of the peers with the traffic of the tracked
+// connections since the previous reading and returns them. Nothing is
+// returned when the connection tracking is not available.
+func (nc *netCounters) readPeers() (map[string]peerTraffic, error) {
+	file, err := os.Open(filepath.Join(nc.procPath, "net", "nf_conntrack"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	local, err := nc.readLocalAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	conns := make(map[string]peerTraffic)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, peer, traffic, ok := parseConnection(scanner.Text(), local)
+		if !ok {
+			continue
+		}
+
+		// A lower value means that the connection has been replaced by a
+		// new one with the same tuple.
+		prev := nc.conns[key]
+		if traffic.Rx < prev.Rx || traffic.Tx < prev.Tx {
+			prev = peerTraffic{}
+		}
+
+		total := nc.peers[peer]
+		total.Rx += traffic.Rx - prev.Rx
+		total.Tx += traffic.Tx - prev.Tx
+		nc.peers[peer] = total
+
+		conns[key] = traffic
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	nc.conns = conns
+
+	peers := make(map[string]peerTraffic, len(nc.peers))
+	for addr, traffic := range nc.peers {
+		peers[addr] = traffic
+	}
+
+	return peers, nil
+}
+
+func (nc *netCounters) readLocalAddrs() (map[string]struct{}, error) {
+	addrs, err := nc.localAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]struct{})
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if ok {
+			local[ipnet.IP.String()] = struct{}{}
+		}
+	}
+
+	return local, nil
+}
+
+// parseConnection parses an entry of the connection tracking table and
+// returns a key that identifies the connection, the address of the peer and
+// the traffic exchanged with it. The first tuple of the entry is the original
+// direction and the second one is the reply.
+func parseConnection(line string, local map[string]struct{}) (string, string, peerTraffic, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", "", peerTraffic{}, false
+	}
+
+	tuples := [2]map[string]string{{}, {}}
+	index := 0
+	key := []string{fields[2]}
+
+	for _, field := range fields[3:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if parts[0] == "src" && tuples[index]["src"] != "" {
+			if index == 1 {
+				break
+			}
+
+			index++
+		}
+
+		tuples[index][parts[0]] = parts[1]
+
+		if index == 0 && parts[0] != "packets" && parts[0] != "bytes" {
+			key = append(key, field)
+		}
+	}
+
+	orig, reply := tuples[0], tuples[1]
+
+	origBytes, err := strconv.ParseUint(orig["bytes"], 10, 64)
+	if err != nil {
+		// Accounting is disabled.
+		return "", "", peerTraffic{}, false
+	}
+
+	replyBytes, err := strconv.ParseUint(reply["bytes"], 10, 64)
+	if err != nil {
+		return "", "", peerTraffic{}, false
+	}
+
+	var peer string
+	var traffic peerTraffic
+
+	if _, ok := local[orig["src"]]; ok {
+		peer = orig["dst"]
+		traffic = peerTraffic{Rx: replyBytes, Tx: origBytes}
+	} else {
+		peer = orig["src"]
+		traffic = peerTraffic{Rx: origBytes, Tx: replyBytes}
+	}
+
+	ip := net.ParseIP(peer)
+	if _, ok := local[peer]; ok || ip == nil || ip.IsLoopback() {
+		return "", "", peerTraffic{}, false
+	}
+
+	return strings.Join(key, " "), peer, traffic, true
+}
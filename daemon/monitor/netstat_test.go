@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    1234      10    1    2    0     0          0         0     4321      20    3    4    0     0       0          0
+`
+
+const testNetSnmp = `Ip: Forwarding DefaultTTL
+Ip: 1 64
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 10 2 0 0 1 100 200 42 0 0 0
+`
+
+const testConntrack = `ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.1 dst=10.0.0.2 sport=40000 dport=2000 packets=10 bytes=1000 src=10.0.0.2 dst=10.0.0.1 sport=2000 dport=40000 packets=8 bytes=800 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 udp      17 20 src=10.0.0.3 dst=10.0.0.1 sport=5000 dport=2000 packets=1 bytes=100 src=10.0.0.1 dst=10.0.0.3 sport=2000 dport=5000 packets=1 bytes=50 mark=0 zone=0 use=2
+ipv4     2 tcp      6 10 ESTABLISHED src=127.0.0.1 dst=127.0.0.1 sport=40001 dport=2000 packets=1 bytes=60 src=127.0.0.1 dst=127.0.0.1 sport=2000 dport=40001 packets=1 bytes=60 mark=0 zone=0 use=2
+ipv4     2 tcp      6 10 ESTABLISHED src=10.0.0.1 dst=10.0.0.4 sport=40002 dport=2000 src=10.0.0.4 dst=10.0.0.1 sport=2000 dport=40002 mark=0 zone=0 use=2
+`
+
+func TestNetCounters_ReadInterface(t *testing.T) {
+	dir, clean := makeTestProc(t)
+	defer clean()
+
+	nc := newNetCounters()
+	nc.procPath = dir
+
+	stats, err := nc.readInterface("eth0")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), stats.RxBytes)
+	require.Equal(t, uint64(10), stats.RxPackets)
+	require.Equal(t, uint64(1), stats.RxErrors)
+	require.Equal(t, uint64(2), stats.RxDropped)
+	require.Equal(t, uint64(4321), stats.TxBytes)
+	require.Equal(t, uint64(20), stats.TxPackets)
+	require.Equal(t, uint64(3), stats.TxErrors)
+	require.Equal(t, uint64(4), stats.TxDropped)
+
+	_, err = nc.readInterface("eth1")
+	require.True(t, errors.Is(err, errNoMatch))
+
+	writeTestProcFile(t, dir, "dev", "eth0: 1 2 3\n")
+	_, err = nc.readInterface("eth0")
+	require.EqualError(t, err, "invalid line for 'eth0'")
+
+	writeTestProcFile(t, dir, "dev", "eth0: 1 2 3 4 5 6 7 8 9 10 11 abc\n")
+	_, err = nc.readInterface("eth0")
+	require.Error(t, err)
+}
+
+func TestNetCounters_ReadRetransmissions(t *testing.T) {
+	dir, clean := makeTestProc(t)
+	defer clean()
+
+	nc := newNetCounters()
+	nc.procPath = dir
+
+	retrans, err := nc.readRetransmissions()
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), retrans)
+
+	nc.procPath = "/non/existing/proc"
+	_, err = nc.readRetransmissions()
+	require.Error(t, err)
+}
+
+func TestNetCounters_ReadPeers(t *testing.T) {
+	dir, clean := makeTestProc(t)
+	defer clean()
+
+	nc := newNetCounters()
+	nc.procPath = dir
+	nc.localAddrs = testLocalAddrs
+
+	peers, err := nc.readPeers()
+	require.NoError(t, err)
+	require.Equal(t, map[string]peerTraffic{
+		"10.0.0.2": {Rx: 800, Tx: 1000},
+		"10.0.0.3": {Rx: 100, Tx: 50},
+	}, peers)
+
+	// The first connection makes progress and the second one is closed, but
+	// its traffic is kept in the totals.
+	writeTestProcFile(t, dir, "nf_conntrack", "ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.1 dst=10.0.0.2 sport=40000 dport=2000 packets=20 bytes=1500 src=10.0.0.2 dst=10.0.0.1 sport=2000 dport=40000 packets=16 bytes=1000 [ASSURED]\n")
+
+	peers, err = nc.readPeers()
+	require.NoError(t, err)
+	require.Equal(t, map[string]peerTraffic{
+		"10.0.0.2": {Rx: 1000, Tx: 1500},
+		"10.0.0.3": {Rx: 100, Tx: 50},
+	}, peers)
+
+	// A new connection with the same tuple.
+	writeTestProcFile(t, dir, "nf_conntrack", "ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.1 dst=10.0.0.2 sport=40000 dport=2000 packets=1 bytes=100 src=10.0.0.2 dst=10.0.0.1 sport=2000 dport=40000 packets=1 bytes=10 [ASSURED]\n")
+
+	peers, err = nc.readPeers()
+	require.NoError(t, err)
+	require.Equal(t, peerTraffic{Rx: 1010, Tx: 1600}, peers["10.0.0.2"])
+
+	nc.localAddrs = func() ([]net.Addr, error) {
+		return nil, errors.New("oops")
+	}
+	_, err = nc.readPeers()
+	require.EqualError(t, err, "oops")
+
+	// Connection tracking is not available.
+	nc.procPath = "/non/existing/proc"
+	peers, err = nc.readPeers()
+	require.NoError(t, err)
+	require.Nil(t, peers)
+}
+
+func TestNetCounters_ParseConnection(t *testing.T) {
+	local := map[string]struct{}{"10.0.0.1": {}}
+
+	key, peer, traffic, ok := parseConnection("ipv4 2 icmp 1 29 src=10.0.0.2 dst=10.0.0.1 type=8 code=0 id=1 packets=5 bytes=420 src=10.0.0.1 dst=10.0.0.2 type=0 code=0 id=1 packets=5 bytes=420 mark=0", local)
+	require.True(t, ok)
+	require.Equal(t, "icmp src=10.0.0.2 dst=10.0.0.1 type=8 code=0 id=1", key)
+	require.Equal(t, "10.0.0.2", peer)
+	require.Equal(t, peerTraffic{Rx: 420, Tx: 420}, traffic)
+
+	_, _, _, ok = parseConnection("ipv4 2", local)
+	require.False(t, ok)
+
+	_, _, _, ok = parseConnection("ipv4 2 tcp 6 src=10.0.0.1 dst=10.0.0.2 bytes=1 src=10.0.0.2 dst=10.0.0.1 bytes=abc", local)
+	require.False(t, ok)
+}
+
+func TestNetCounters_EnableAccounting(t *testing.T) {
+	nc := newNetCounters()
+	nc.procPath = "/non/existing/proc"
+
+	require.Error(t, nc.enableAccounting())
+}
+
+func testLocalAddrs() ([]net.Addr, error) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		&net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)},
+	}
+
+	return addrs, nil
+}
+
+func makeTestProc(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "monitor-proc")
+	require.NoError(t, err)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "net"), 0755))
+
+	writeTestProcFile(t, dir, "dev", testNetDev)
+	writeTestProcFile(t, dir, "snmp", testNetSnmp)
+	writeTestProcFile(t, dir, "nf_conntrack", testConntrack)
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func writeTestProcFile(t *testing.T, dir, name, content string) {
+	err := ioutil.WriteFile(filepath.Join(dir, "net", name), []byte(content), 0644)
+	require.NoError(t, err)
+}
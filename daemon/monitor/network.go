@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// networkMonitor only reads the counters of the network namespace of the
+// monitor. It runs alongside a strategy that gathers the usage of the
+// resources by itself, like Docker, to add the retransmissions and the
+// traffic with each peer.
+type networkMonitor struct {
+	wg       sync.WaitGroup
+	interval time.Duration
+	c        chan *sample
+	closing  chan struct{}
+	counters *netCounters
+}
+
+func newNetworkMonitor(interval time.Duration) *networkMonitor {
+	return &networkMonitor{
+		interval: interval,
+		c:        make(chan *sample),
+		closing:  make(chan struct{}),
+		counters: newNetCounters(),
+	}
+}
+
+func (m *networkMonitor) Start() error {
+	err := m.counters.enableAccounting()
+	if err != nil {
+		fmt.Printf("Couldn't enable the accounting of the connections: %v\n", err)
+	}
+
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.closing:
+				return
+			case <-ticker.C:
+				s := &sample{StatsJSON: &types.StatsJSON{}}
+
+				err := m.counters.gather(s)
+				if err != nil {
+					fmt.Printf("Error when reading network stats: %v\n", err)
+				}
+
+				select {
+				case m.c <- s:
+				case <-m.closing:
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (m *networkMonitor) Stop() error {
+	close(m.closing)
+	m.wg.Wait()
+
+	return nil
+}
+
+func (m *networkMonitor) Stream() <-chan *sample {
+	return m.c
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkMonitor_Start(t *testing.T) {
+	proc, clean := makeTestProc(t)
+	defer clean()
+
+	monitor := newNetworkMonitor(time.Millisecond)
+	monitor.counters.procPath = proc
+	monitor.counters.localAddrs = testLocalAddrs
+
+	require.NoError(t, monitor.Start())
+
+	s := <-monitor.Stream()
+
+	require.NoError(t, monitor.Stop())
+
+	require.Equal(t, uint64(1234), s.Networks[DockerNetworkInterface].RxBytes)
+	require.Equal(t, uint64(42), s.Retransmissions)
+	require.NotEmpty(t, s.Peers)
+	require.Zero(t, s.CPUStats.CPUUsage.TotalUsage)
+}
+
+func TestNetworkMonitor_StartFailure(t *testing.T) {
+	proc, clean := makeTestProc(t)
+	clean()
+
+	monitor := newNetworkMonitor(time.Millisecond)
+	monitor.counters.procPath = proc
+
+	require.NoError(t, monitor.Start())
+
+	// The sample is still sent so that the timeline is kept.
+	s := <-monitor.Stream()
+
+	require.NoError(t, monitor.Stop())
+	require.Empty(t, s.Networks)
+}
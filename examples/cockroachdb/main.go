@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"go.dedis.ch/simnet"
+	"go.dedis.ch/simnet/metrics"
 	"go.dedis.ch/simnet/network"
 	"go.dedis.ch/simnet/sim"
 	"go.dedis.ch/simnet/sim/kubernetes"
@@ -44,7 +45,7 @@ func (s simRound) Execute(simio sim.IO, nodes []sim.NodeInfo) error {
 
 	go io.Copy(os.Stdout, reader)
 
-	simio.Tag("Init")
+	simio.Tag(metrics.PhaseStart("init"))
 	err := simio.Exec("node0", commandWorkloadInit, sim.ExecOptions{
 		Stdout: writer,
 		Stderr: writer,
@@ -53,7 +54,9 @@ func (s simRound) Execute(simio sim.IO, nodes []sim.NodeInfo) error {
 		return err
 	}
 
-	simio.Tag("Run")
+	simio.Tag(metrics.PhaseEnd("init"))
+
+	simio.Tag(metrics.PhaseStart("run"))
 	err = simio.Exec("node0", commandWorkloadRun, sim.ExecOptions{
 		Stdout: writer,
 		Stderr: writer,
@@ -62,6 +65,8 @@ func (s simRound) Execute(simio sim.IO, nodes []sim.NodeInfo) error {
 		return err
 	}
 
+	simio.Tag(metrics.PhaseEnd("run"))
+
 	// In order to clean the Go routine, it's important to close one side of
 	// the pipe.
 	writer.Close()
@@ -83,6 +88,9 @@ func main() {
 			nil,
 			[]string{"start", "--insecure", "--join=node0,node1,node2"},
 		),
+		// The statistics will contain the number of statements and the
+		// latency of the SQL service.
+		sim.WithPrometheus(8080, "/_status/vars", "sql_select_count", "sql_service_latency_sum"),
 	}
 
 	kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
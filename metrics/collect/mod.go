@@ -0,0 +1,135 @@
+package collect
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"go.dedis.ch/simnet/metrics"
+	"golang.org/x/xerrors"
+)
+
+// Stats is the statistics of a container completed with the number of online
+// CPUs, which the types of the Docker API used by the module do not have. It
+// is the only count of the CPUs on the hosts with cgroup v2 as the usage per
+// CPU is not reported.
+type Stats struct {
+	*types.StatsJSON
+	OnlineCPUs uint32
+}
+
+// DecodeStats reads the next statistics of a stream of the Docker API. The
+// error of the stream is returned as is so that the end can be detected.
+func DecodeStats(dec *json.Decoder) (Stats, error) {
+	var raw json.RawMessage
+	err := dec.Decode(&raw)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{StatsJSON: &types.StatsJSON{}}
+	err = json.Unmarshal(raw, stats.StatsJSON)
+	if err != nil {
+		return Stats{}, xerrors.Errorf("couldn't decode the stats: %v", err)
+	}
+
+	var online struct {
+		CPUStats struct {
+			OnlineCPUs uint32 `json:"online_cpus"`
+		} `json:"cpu_stats"`
+	}
+
+	err = json.Unmarshal(raw, &online)
+	if err != nil {
+		return Stats{}, xerrors.Errorf("couldn't decode the online cpus: %v", err)
+	}
+
+	stats.OnlineCPUs = online.CPUStats.OnlineCPUs
+
+	return stats, nil
+}
+
+// CPUPercent returns the usage of the CPU between the previous reading and
+// the statistics, as a percentage of one CPU. It follows the computation of
+// the Docker client, which counts the online CPUs when the usage per CPU is
+// not reported.
+// https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L175-L188
+func CPUPercent(previousCPU, previousSystem uint64, v Stats) float64 {
+	var (
+		cpuPercent = 0.0
+		// calculate the change for the cpu usage of the container in between readings
+		cpuDelta = float64(v.CPUStats.CPUUsage.TotalUsage) - float64(previousCPU)
+		// calculate the change for the entire system between readings
+		systemDelta = float64(v.CPUStats.SystemUsage) - float64(previousSystem)
+		onlineCPUs  = float64(v.OnlineCPUs)
+	)
+
+	if onlineCPUs == 0.0 {
+		onlineCPUs = float64(len(v.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	if systemDelta > 0.0 && cpuDelta > 0.0 {
+		cpuPercent = (cpuDelta / systemDelta) * onlineCPUs * 100.0
+	}
+
+	return cpuPercent
+}
+
+// CPU returns the usage of the CPU since the previous reading of the
+// statistics in the unit of the statistics, which is the hundredth of a
+// percent of one CPU.
+func CPU(v Stats) uint64 {
+	percent := CPUPercent(v.PreCPUStats.CPUUsage.TotalUsage, v.PreCPUStats.SystemUsage, v)
+
+	return uint64(math.Ceil(percent * metrics.CPUScale))
+}
+
+// Values returns the value of each column of the monitor that the statistics
+// of the container can fill, where the network counters are read from the
+// given interface. The retransmissions are not part of it.
+func Values(v Stats, iface string) map[string]uint64 {
+	netstat := v.Networks[iface]
+
+	values := map[string]uint64{
+		metrics.ColumnRxBytes:   netstat.RxBytes,
+		metrics.ColumnTxBytes:   netstat.TxBytes,
+		metrics.ColumnCPU:       CPU(v),
+		metrics.ColumnMemory:    v.MemoryStats.Usage,
+		metrics.ColumnRxPackets: netstat.RxPackets,
+		metrics.ColumnTxPackets: netstat.TxPackets,
+		metrics.ColumnRxErrors:  netstat.RxErrors,
+		metrics.ColumnTxErrors:  netstat.TxErrors,
+		metrics.ColumnRxDropped: netstat.RxDropped,
+		metrics.ColumnTxDropped: netstat.TxDropped,
+		metrics.ColumnPIDs:      v.PidsStats.Current,
+	}
+
+	for _, entry := range v.BlkioStats.IoServiceBytesRecursive {
+		// cgroup v1 capitalizes the operations but not v2.
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			values[metrics.ColumnBlockRead] += entry.Value
+		case "write":
+			values[metrics.ColumnBlockWrite] += entry.Value
+		}
+	}
+
+	// cgroup v2 names the page cache and the anonymous memory differently.
+	values[metrics.ColumnMemoryCache] = firstStat(v.MemoryStats.Stats, "cache", "file")
+	values[metrics.ColumnMemoryRSS] = firstStat(v.MemoryStats.Stats, "rss", "anon")
+
+	return values
+}
+
+// firstStat returns the value of the first key found in the statistics.
+func firstStat(stats map[string]uint64, keys ...string) uint64 {
+	for _, key := range keys {
+		value, ok := stats[key]
+		if ok {
+			return value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,95 @@
+package collect
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
+)
+
+func TestCollect_CPUPercent(t *testing.T) {
+	v := &types.StatsJSON{}
+	v.CPUStats.CPUUsage.TotalUsage = 150
+	v.CPUStats.CPUUsage.PercpuUsage = []uint64{0, 0}
+	v.CPUStats.SystemUsage = 1200
+
+	require.Equal(t, 50.0, CPUPercent(100, 1000, Stats{StatsJSON: v}))
+	require.Equal(t, 0.0, CPUPercent(200, 1000, Stats{StatsJSON: v}))
+	require.Equal(t, 0.0, CPUPercent(100, 1200, Stats{StatsJSON: v}))
+
+	// The online CPUs are counted first, and they are the only count when
+	// the usage per CPU is not reported with cgroup v2.
+	require.Equal(t, 100.0, CPUPercent(100, 1000, Stats{StatsJSON: v, OnlineCPUs: 4}))
+	v.CPUStats.CPUUsage.PercpuUsage = nil
+	require.Equal(t, 0.0, CPUPercent(100, 1000, Stats{StatsJSON: v}))
+	require.Equal(t, 50.0, CPUPercent(100, 1000, Stats{StatsJSON: v, OnlineCPUs: 2}))
+
+	v.PreCPUStats.CPUUsage.TotalUsage = 100
+	v.PreCPUStats.SystemUsage = 1000
+	require.Equal(t, uint64(5000), CPU(Stats{StatsJSON: v, OnlineCPUs: 2}))
+
+	// The hundredths of a percent are rounded up.
+	v.CPUStats.SystemUsage = 1000 + 3*1000
+	require.Equal(t, uint64(334), CPU(Stats{StatsJSON: v, OnlineCPUs: 2}))
+}
+
+func TestCollect_DecodeStats(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(
+		`{"cpu_stats":{"cpu_usage":{"total_usage":150},"system_cpu_usage":1200,"online_cpus":2}}`))
+
+	stats, err := DecodeStats(dec)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), stats.CPUStats.CPUUsage.TotalUsage)
+	require.Equal(t, uint32(2), stats.OnlineCPUs)
+
+	_, err = DecodeStats(dec)
+	require.Equal(t, io.EOF, err)
+
+	_, err = DecodeStats(json.NewDecoder(strings.NewReader(`{"cpu_stats":[]}`)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't decode the stats: ")
+}
+
+func TestCollect_Values(t *testing.T) {
+	v := &types.StatsJSON{
+		Networks: map[string]types.NetworkStats{
+			"eth0": {RxBytes: 1, TxBytes: 2, RxPackets: 3, TxPackets: 4, RxErrors: 5, TxErrors: 6, RxDropped: 7, TxDropped: 8},
+		},
+	}
+	v.MemoryStats.Usage = 9
+	v.PidsStats.Current = 10
+	v.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "Read", Value: 1},
+		{Op: "read", Value: 2},
+		{Op: "Write", Value: 3},
+		{Op: "Total", Value: 6},
+	}
+	v.MemoryStats.Stats = map[string]uint64{"file": 11, "anon": 12}
+
+	values := Values(Stats{StatsJSON: v}, "eth0")
+	require.Equal(t, map[string]uint64{
+		metrics.ColumnRxBytes:     1,
+		metrics.ColumnTxBytes:     2,
+		metrics.ColumnCPU:         0,
+		metrics.ColumnMemory:      9,
+		metrics.ColumnRxPackets:   3,
+		metrics.ColumnTxPackets:   4,
+		metrics.ColumnRxErrors:    5,
+		metrics.ColumnTxErrors:    6,
+		metrics.ColumnRxDropped:   7,
+		metrics.ColumnTxDropped:   8,
+		metrics.ColumnPIDs:        10,
+		metrics.ColumnBlockRead:   3,
+		metrics.ColumnBlockWrite:  3,
+		metrics.ColumnMemoryCache: 11,
+		metrics.ColumnMemoryRSS:   12,
+	}, values)
+
+	v.MemoryStats.Stats = map[string]uint64{"cache": 13, "file": 11}
+	require.Equal(t, uint64(13), Values(Stats{StatsJSON: v}, "eth0")[metrics.ColumnMemoryCache])
+	require.Equal(t, uint64(0), Values(Stats{StatsJSON: v}, "eth1")[metrics.ColumnRxBytes])
+}
@@ -0,0 +1,264 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/xerrors"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Names of the resource metrics compared between runs. The network is
+// compared on the throughput as the counters are cumulative.
+const (
+	CompareCPU    = "cpu"
+	CompareMemory = "memory"
+	CompareRxRate = "rx_rate"
+	CompareTxRate = "tx_rate"
+)
+
+var compareResources = []string{CompareCPU, CompareMemory, CompareRxRate, CompareTxRate}
+
+// Summary describes the values of a metric in one run. The values are the
+// statistical units of the comparison, which are the means of the batches of
+// consecutive samples for a node, and the means of the nodes over the run when
+// they are aggregated.
+type Summary struct {
+	// Count is the number of units, not the number of samples.
+	Count  int
+	Mean   float64
+	StdDev float64
+}
+
+// Difference is the comparison of a metric between the baseline, which is the
+// first run, and another run.
+type Difference struct {
+	Metric string
+	// Node is the name of the node, or empty when the values of every node
+	// are aggregated.
+	Node string
+	// Run is the index of the run compared to the baseline.
+	Run   int
+	Base  Summary
+	Other Summary
+	// Delta is the difference between the mean of the run and the mean of
+	// the baseline.
+	Delta float64
+	// Lower and Upper are the bounds of the confidence interval of the
+	// difference.
+	Lower float64
+	Upper float64
+	// PValue is the probability given by the Welch's t-test to observe such
+	// a difference if the means are equal. It is NaN when a run has less than
+	// two values.
+	PValue float64
+}
+
+// Relative returns the difference relative to the mean of the baseline, or
+// NaN when it is zero.
+func (d Difference) Relative() float64 {
+	if d.Base.Mean == 0 {
+		return math.NaN()
+	}
+
+	return d.Delta / d.Base.Mean
+}
+
+// Significant returns true when the difference is significant for the
+// confidence level.
+func (d Difference) Significant(confidence float64) bool {
+	return d.PValue < 1-confidence
+}
+
+// Compare compares each run to the first one for the resource usage and the
+// custom metrics, both per node and for every node together. The samples of a
+// node are correlated over time so they are not compared one by one: the test
+// uses the means of batches of consecutive samples for a node, and the mean of
+// each node over the run for the aggregate, which assumes that the batches and
+// the nodes are independent. The confidence is the level of the intervals,
+// between 0 and 1 excluded.
+func Compare(runs []Stats, confidence float64) ([]Difference, error) {
+	if len(runs) < 2 {
+		return nil, xerrors.New("need at least two runs to compare")
+	}
+
+	if confidence <= 0 || confidence >= 1 {
+		return nil, xerrors.Errorf("invalid confidence level %.3f", confidence)
+	}
+
+	base := compareValues(runs[0])
+	diffs := []Difference{}
+
+	for i, run := range runs[1:] {
+		other := compareValues(run)
+
+		for _, name := range compareNames(runs[0]) {
+			nodes := make([]string, 0, len(base[name]))
+			for node := range base[name] {
+				nodes = append(nodes, node)
+			}
+
+			// The aggregated values come first as the empty name is the
+			// smallest.
+			sort.Strings(nodes)
+
+			for _, node := range nodes {
+				values, ok := other[name][node]
+				if !ok {
+					continue
+				}
+
+				d := welch(base[name][node], values, confidence)
+				d.Metric = name
+				d.Node = node
+				d.Run = i + 1
+
+				diffs = append(diffs, d)
+			}
+		}
+	}
+
+	return diffs, nil
+}
+
+// compareNames returns the names of the metrics of the run in the order of
+// the comparison, the resources first.
+func compareNames(stats Stats) []string {
+	names := append([]string{}, compareResources...)
+
+	custom := make([]string, 0, len(stats.Metrics))
+	for name := range stats.Metrics {
+		custom = append(custom, name)
+	}
+
+	sort.Strings(custom)
+
+	return append(names, custom...)
+}
+
+// compareValues returns the units of each metric indexed by the name of the
+// node, where the empty name gathers the mean of every node. A custom metric
+// recorded only for the whole simulation uses the batch means of its samples
+// instead.
+func compareValues(stats Stats) map[string]map[string][]float64 {
+	values := make(map[string]map[string][]float64)
+
+	add := func(name, node string, s Series) {
+		if len(s.Values) == 0 {
+			return
+		}
+
+		if values[name] == nil {
+			values[name] = make(map[string][]float64)
+		}
+
+		values[name][node] = batchMeans(s.Values)
+		values[name][""] = append(values[name][""], stat.Mean(s.Values, nil))
+	}
+
+	for node, ns := range stats.Nodes {
+		add(CompareCPU, node, ns.CPUUsage())
+		add(CompareMemory, node, ns.MemoryUsage())
+		add(CompareRxRate, node, ns.RxRate())
+		add(CompareTxRate, node, ns.TxRate())
+	}
+
+	for name, m := range stats.Metrics {
+		for node := range m.Nodes {
+			add(name, node, m.Series(node))
+		}
+
+		if len(m.Nodes) == 0 && len(m.Samples) > 0 {
+			values[name] = map[string][]float64{
+				"": batchMeans(m.Series("").Values),
+			}
+		}
+	}
+
+	return values
+}
+
+// batchMeans splits the samples in batches of consecutive values and returns
+// the mean of each of them. The size of the batches is about the square root
+// of the number of samples so that both the size and the number of batches
+// grow with the length of the run.
+func batchMeans(values []float64) []float64 {
+	n := len(values)
+	if n == 0 {
+		return nil
+	}
+
+	size := int(math.Ceil(math.Sqrt(float64(n))))
+	count := n / size
+
+	means := make([]float64, count)
+	for i := range means {
+		// The values are spread evenly so that none is left out.
+		means[i] = stat.Mean(values[i*n/count:(i+1)*n/count], nil)
+	}
+
+	return means
+}
+
+func summarize(values []float64) Summary {
+	s := Summary{Count: len(values)}
+	if s.Count == 0 {
+		return s
+	}
+
+	s.Mean = stat.Mean(values, nil)
+	if s.Count > 1 {
+		s.StdDev = stat.StdDev(values, nil)
+	}
+
+	return s
+}
+
+// welch returns the difference of the means of the two sets of values with
+// the confidence interval and the p-value of the Welch's t-test, which does
+// not assume that the variances are equal.
+func welch(base, other []float64, confidence float64) Difference {
+	d := Difference{
+		Base:  summarize(base),
+		Other: summarize(other),
+	}
+
+	d.Delta = d.Other.Mean - d.Base.Mean
+
+	if d.Base.Count < 2 || d.Other.Count < 2 {
+		d.Lower = math.NaN()
+		d.Upper = math.NaN()
+		d.PValue = math.NaN()
+		return d
+	}
+
+	vb := d.Base.StdDev * d.Base.StdDev / float64(d.Base.Count)
+	vo := d.Other.StdDev * d.Other.StdDev / float64(d.Other.Count)
+	se := math.Sqrt(vb + vo)
+
+	if se == 0 {
+		// Both runs have constant values so the difference is exact.
+		d.Lower = d.Delta
+		d.Upper = d.Delta
+		d.PValue = 0
+		if d.Delta == 0 {
+			d.PValue = 1
+		}
+
+		return d
+	}
+
+	// Welch-Satterthwaite approximation of the degrees of freedom.
+	df := (vb + vo) * (vb + vo) / (vb*vb/float64(d.Base.Count-1) + vo*vo/float64(d.Other.Count-1))
+	dist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}
+
+	t := d.Delta / se
+	d.PValue = 2 * (1 - dist.CDF(math.Abs(t)))
+
+	q := dist.Quantile(1 - (1-confidence)/2)
+	d.Lower = d.Delta - q*se
+	d.Upper = d.Delta + q*se
+
+	return d
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare_Welch(t *testing.T) {
+	d := welch([]float64{1, 2, 3, 4, 5}, []float64{2, 4, 6, 8, 10}, 0.95)
+	require.Equal(t, Summary{Count: 5, Mean: 3, StdDev: math.Sqrt(2.5)}, d.Base)
+	require.Equal(t, 6.0, d.Other.Mean)
+	require.Equal(t, 3.0, d.Delta)
+	require.InDelta(t, 0.107, d.PValue, 0.001)
+	require.InDelta(t, -0.88, d.Lower, 0.01)
+	require.InDelta(t, 6.88, d.Upper, 0.01)
+	require.False(t, d.Significant(0.95))
+	require.True(t, d.Significant(0.85))
+	require.Equal(t, 1.0, d.Relative())
+
+	d = welch([]float64{1, 1}, []float64{2, 2}, 0.95)
+	require.Equal(t, 0.0, d.PValue)
+	require.Equal(t, 1.0, d.Lower)
+	require.Equal(t, 1.0, d.Upper)
+
+	d = welch([]float64{1, 1}, []float64{1, 1}, 0.95)
+	require.Equal(t, 1.0, d.PValue)
+
+	d = welch([]float64{1}, []float64{0, 2}, 0.95)
+	require.Equal(t, 0.0, d.Delta)
+	require.True(t, math.IsNaN(d.PValue))
+	require.True(t, math.IsNaN(d.Lower))
+	require.False(t, d.Significant(0.95))
+
+	require.True(t, math.IsNaN(Difference{}.Relative()))
+}
+
+func TestCompare_BatchMeans(t *testing.T) {
+	require.Nil(t, batchMeans(nil))
+	require.Equal(t, []float64{5}, batchMeans([]float64{5}))
+	require.Equal(t, []float64{3, 8}, batchMeans([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}))
+	require.Len(t, batchMeans(make([]float64, 100)), 10)
+}
+
+func TestCompare_Runs(t *testing.T) {
+	base := Stats{
+		Nodes: map[string]NodeStats{
+			"node0": makeCompareNode(10, 1),
+			"node1": makeCompareNode(20, 1),
+		},
+		Metrics: map[string]Metric{
+			"height": {
+				Samples: []Sample{{Value: 1}},
+				Nodes: map[string][]Sample{
+					"node0": {{Value: 2}, {Value: 3}, {Value: 2}, {Value: 3}},
+				},
+			},
+		},
+	}
+
+	other := Stats{
+		Nodes: map[string]NodeStats{
+			"node0": makeCompareNode(40, 2),
+		},
+	}
+
+	diffs, err := Compare([]Stats{base, other, base}, 0.95)
+	require.NoError(t, err)
+
+	// The second run lacks the node1 and the custom metric.
+	require.Len(t, diffs, 8+14)
+
+	// The aggregate compares the mean of each node.
+	require.Equal(t, CompareCPU, diffs[0].Metric)
+	require.Equal(t, "", diffs[0].Node)
+	require.Equal(t, 1, diffs[0].Run)
+	require.Equal(t, Summary{Count: 2, Mean: 55, StdDev: math.Sqrt(50)}, diffs[0].Base)
+	require.Equal(t, 1, diffs[0].Other.Count)
+	require.Equal(t, 25.0, diffs[0].Delta)
+	require.True(t, math.IsNaN(diffs[0].PValue))
+
+	// A node compares the batch means of its samples.
+	require.Equal(t, "node0", diffs[1].Node)
+	require.Equal(t, Summary{Count: 3, Mean: 50, StdDev: 30}, diffs[1].Base)
+	require.Equal(t, 30.0, diffs[1].Delta)
+	require.False(t, diffs[1].Significant(0.95))
+
+	require.Equal(t, CompareMemory, diffs[2].Metric)
+	require.Equal(t, CompareRxRate, diffs[4].Metric)
+	require.Equal(t, 0.0, diffs[4].Delta)
+
+	last := diffs[len(diffs)-2:]
+	require.Equal(t, "height", last[0].Metric)
+	require.Equal(t, 1, last[0].Base.Count)
+	require.Equal(t, "node0", last[1].Node)
+	require.Equal(t, 2, last[1].Run)
+	require.Equal(t, 2, last[1].Base.Count)
+	require.Equal(t, 1.0, last[1].PValue)
+
+	_, err = Compare([]Stats{base}, 0.95)
+	require.EqualError(t, err, "need at least two runs to compare")
+
+	_, err = Compare([]Stats{base, other}, 1)
+	require.EqualError(t, err, "invalid confidence level 1.000")
+}
+
+func TestCompare_GlobalMetric(t *testing.T) {
+	samples := make([]Sample, 9)
+	for i := range samples {
+		samples[i].Value = float64(i)
+	}
+
+	stats := Stats{
+		Metrics: map[string]Metric{"latency": {Samples: samples}},
+	}
+
+	values := compareValues(stats)
+	require.Equal(t, []float64{1, 4, 7}, values["latency"][""])
+}
+
+// makeCompareNode returns the statistics of a node with nine samples where
+// the CPU grows from the given value and the memory is constant.
+func makeCompareNode(cpu, memory uint64) NodeStats {
+	ns := NodeStats{}
+	for i := uint64(0); i < 9; i++ {
+		ns.Timestamps = append(ns.Timestamps, int64(i+1))
+		ns.CPU = append(ns.CPU, cpu+i*10)
+		ns.Memory = append(ns.Memory, memory)
+		ns.RxBytes = append(ns.RxBytes, i*10)
+		ns.TxBytes = append(ns.TxBytes, i*10)
+	}
+
+	return ns
+}
@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of the histograms. They
+// grow exponentially to cover values of different magnitudes whatever the
+// unit is.
+var DefaultBuckets = ExponentialBuckets(0.001, 2, 32)
+
+// ExponentialBuckets returns the upper bounds of count buckets where the first
+// one is start and each following one is multiplied by the factor.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+
+	return buckets
+}
+
+// Sample is a value of a metric recorded at a point in time. The timestamp is
+// in nanoseconds like the tags.
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// Metric contains the samples recorded for a custom metric.
+type Metric struct {
+	// Samples are the values recorded for the whole simulation.
+	Samples []Sample
+	// Nodes are the values recorded for each node.
+	Nodes map[string][]Sample
+}
+
+// Series returns the samples of the node, or the global ones when the node is
+// empty, with the timestamps converted in seconds.
+func (m Metric) Series(node string) Series {
+	samples := m.Samples
+	if node != "" {
+		samples = m.Nodes[node]
+	}
+
+	s := Series{
+		Timestamps: make([]int64, len(samples)),
+		Values:     make([]float64, len(samples)),
+	}
+
+	for i, sample := range samples {
+		s.Timestamps[i] = sample.Timestamp / int64(time.Second)
+		s.Values[i] = sample.Value
+	}
+
+	return s
+}
+
+// Histogram counts the values observed in buckets. A value goes to the first
+// bucket with an upper bound greater or equal to the value, and the last
+// bucket counts the values above every bound.
+type Histogram struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+	Min    float64
+	Max    float64
+}
+
+// NewHistogram returns an empty histogram with the given upper bounds which
+// must be sorted.
+func NewHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds the value to the histogram.
+func (h *Histogram) Observe(value float64) {
+	index := sort.SearchFloat64s(h.Bounds, value)
+	h.Counts[index]++
+
+	if h.Count == 0 || value < h.Min {
+		h.Min = value
+	}
+
+	if h.Count == 0 || value > h.Max {
+		h.Max = value
+	}
+
+	h.Count++
+	h.Sum += value
+}
+
+// Average returns the mean of the values, or zero when the histogram is empty.
+func (h Histogram) Average() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile returns an estimation of the value below which the fraction of the
+// values falls. It assumes the values are uniformly distributed inside a
+// bucket, and the estimation is bounded by the minimum and the maximum.
+func (h Histogram) Quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := q * float64(h.Count)
+	cumul := 0.0
+
+	for i, count := range h.Counts {
+		if count == 0 || cumul+float64(count) < rank {
+			cumul += float64(count)
+			continue
+		}
+
+		lower := h.Min
+		if i > 0 {
+			lower = math.Max(h.Bounds[i-1], h.Min)
+		}
+
+		upper := h.Max
+		if i < len(h.Bounds) {
+			upper = math.Min(h.Bounds[i], h.Max)
+		}
+
+		return lower + (upper-lower)*(rank-cumul)/float64(count)
+	}
+
+	return h.Max
+}
+
+// HistogramMetric contains the histograms of a custom metric.
+type HistogramMetric struct {
+	// Histogram counts the values observed for the whole simulation.
+	Histogram Histogram
+	// Nodes are the histograms of the values observed for each node.
+	Nodes map[string]Histogram
+}
+
+// Recorder collects the custom metrics of a simulation. It is safe for
+// concurrent use.
+type Recorder struct {
+	sync.Mutex
+	metrics    map[string]Metric
+	histograms map[string]HistogramMetric
+}
+
+// NewRecorder returns a new empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		metrics:    make(map[string]Metric),
+		histograms: make(map[string]HistogramMetric),
+	}
+}
+
+// Record saves a sample of the metric for the node, or a global sample when
+// the node is empty, using the current timestamp.
+func (r *Recorder) Record(name, node string, value float64) {
+	sample := Sample{Timestamp: time.Now().UnixNano(), Value: value}
+
+	r.Lock()
+	defer r.Unlock()
+
+	m := r.metrics[name]
+	if node == "" {
+		m.Samples = append(m.Samples, sample)
+	} else {
+		if m.Nodes == nil {
+			m.Nodes = make(map[string][]Sample)
+		}
+
+		m.Nodes[node] = append(m.Nodes[node], sample)
+	}
+
+	r.metrics[name] = m
+}
+
+// Observe adds the value to the histogram of the metric for the node, or to
+// the global histogram when the node is empty. The histograms use the default
+// buckets.
+func (r *Recorder) Observe(name, node string, value float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	hm, ok := r.histograms[name]
+	if !ok {
+		hm = HistogramMetric{
+			Histogram: NewHistogram(DefaultBuckets),
+			Nodes:     make(map[string]Histogram),
+		}
+	}
+
+	if node == "" {
+		hm.Histogram.Observe(value)
+	} else {
+		h, ok := hm.Nodes[node]
+		if !ok {
+			h = NewHistogram(DefaultBuckets)
+		}
+
+		h.Observe(value)
+		hm.Nodes[node] = h
+	}
+
+	r.histograms[name] = hm
+}
+
+// Fill copies the metrics and the histograms recorded so far into the
+// statistics.
+func (r *Recorder) Fill(stats *Stats) {
+	r.Lock()
+	defer r.Unlock()
+
+	stats.Metrics = make(map[string]Metric, len(r.metrics))
+	for name, m := range r.metrics {
+		cp := Metric{
+			Samples: append([]Sample(nil), m.Samples...),
+			Nodes:   make(map[string][]Sample, len(m.Nodes)),
+		}
+
+		for node, samples := range m.Nodes {
+			cp.Nodes[node] = append([]Sample(nil), samples...)
+		}
+
+		stats.Metrics[name] = cp
+	}
+
+	stats.Histograms = make(map[string]HistogramMetric, len(r.histograms))
+	for name, hm := range r.histograms {
+		cp := HistogramMetric{
+			Histogram: hm.Histogram.copy(),
+			Nodes:     make(map[string]Histogram, len(hm.Nodes)),
+		}
+
+		for node, h := range hm.Nodes {
+			cp.Nodes[node] = h.copy()
+		}
+
+		stats.Histograms[name] = cp
+	}
+}
+
+func (h Histogram) copy() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
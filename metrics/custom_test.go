@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustom_ExponentialBuckets(t *testing.T) {
+	require.Equal(t, []float64{1, 2, 4, 8}, ExponentialBuckets(1, 2, 4))
+	require.Len(t, DefaultBuckets, 32)
+}
+
+func TestCustom_MetricSeries(t *testing.T) {
+	m := Metric{
+		Samples: []Sample{{Timestamp: 2 * int64(time.Second), Value: 1.5}},
+		Nodes: map[string][]Sample{
+			"node0": {{Timestamp: 3 * int64(time.Second), Value: 2}},
+		},
+	}
+
+	require.Equal(t, Series{Timestamps: []int64{2}, Values: []float64{1.5}}, m.Series(""))
+	require.Equal(t, Series{Timestamps: []int64{3}, Values: []float64{2}}, m.Series("node0"))
+	require.Equal(t, 0, m.Series("node1").Len())
+}
+
+func TestCustom_Histogram(t *testing.T) {
+	h := NewHistogram([]float64{10, 20, 30})
+	require.Equal(t, 0.0, h.Average())
+	require.Equal(t, 0.0, h.Quantile(0.5))
+
+	for _, v := range []float64{5, 10, 12, 18, 25, 100} {
+		h.Observe(v)
+	}
+
+	require.Equal(t, []uint64{2, 2, 1, 1}, h.Counts)
+	require.Equal(t, uint64(6), h.Count)
+	require.Equal(t, 5.0, h.Min)
+	require.Equal(t, 100.0, h.Max)
+	require.InDelta(t, 28.33, h.Average(), 0.01)
+
+	require.Equal(t, 5.0, h.Quantile(0))
+	require.Equal(t, 10.0, h.Quantile(1.0/3))
+	require.Equal(t, 20.0, h.Quantile(2.0/3))
+	require.Equal(t, 100.0, h.Quantile(1))
+}
+
+func TestCustom_Recorder(t *testing.T) {
+	r := NewRecorder()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.Record("height", "node0", float64(i))
+			r.Observe("latency", "", float64(i))
+		}(i)
+	}
+
+	wg.Wait()
+
+	r.Record("throughput", "", 42)
+	r.Observe("latency", "node1", 0.5)
+
+	stats := Stats{}
+	r.Fill(&stats)
+
+	require.Len(t, stats.Metrics, 2)
+	require.Len(t, stats.Metrics["height"].Nodes["node0"], 10)
+	require.Empty(t, stats.Metrics["height"].Samples)
+	require.Equal(t, 42.0, stats.Metrics["throughput"].Samples[0].Value)
+
+	require.Len(t, stats.Histograms, 1)
+	require.Equal(t, uint64(10), stats.Histograms["latency"].Histogram.Count)
+	require.Equal(t, uint64(1), stats.Histograms["latency"].Nodes["node1"].Count)
+
+	// The statistics are a copy of the recorded values.
+	r.Record("throughput", "", 43)
+	r.Observe("latency", "", 1)
+	require.Len(t, stats.Metrics["throughput"].Samples, 1)
+	require.Equal(t, uint64(10), stats.Histograms["latency"].Histogram.Count)
+}
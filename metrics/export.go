@@ -0,0 +1,495 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// OpenMetricsPrefix is the prefix of the names of the metric families written
+// in the OpenMetrics format.
+const OpenMetricsPrefix = "simnet_"
+
+var reInvalidName = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+// column is a column of the resource usage of the nodes.
+type column struct {
+	name    string
+	help    string
+	counter bool
+	values  func(ns NodeStats) []uint64
+}
+
+var columns = []column{
+	{"rx_bytes", "Bytes received by the node.", true, func(ns NodeStats) []uint64 { return ns.RxBytes }},
+	{"tx_bytes", "Bytes transmitted by the node.", true, func(ns NodeStats) []uint64 { return ns.TxBytes }},
+	{"cpu", "CPU usage of the node.", false, func(ns NodeStats) []uint64 { return ns.CPU }},
+	{"memory", "Memory used by the node.", false, func(ns NodeStats) []uint64 { return ns.Memory }},
+	{"rx_packets", "Packets received by the node.", true, func(ns NodeStats) []uint64 { return ns.RxPackets }},
+	{"tx_packets", "Packets transmitted by the node.", true, func(ns NodeStats) []uint64 { return ns.TxPackets }},
+	{"rx_errors", "Errors while receiving.", true, func(ns NodeStats) []uint64 { return ns.RxErrors }},
+	{"tx_errors", "Errors while transmitting.", true, func(ns NodeStats) []uint64 { return ns.TxErrors }},
+	{"rx_dropped", "Packets dropped while receiving.", true, func(ns NodeStats) []uint64 { return ns.RxDropped }},
+	{"tx_dropped", "Packets dropped while transmitting.", true, func(ns NodeStats) []uint64 { return ns.TxDropped }},
+	{"retransmissions", "TCP segments retransmitted.", true, func(ns NodeStats) []uint64 { return ns.Retransmissions }},
+	{"block_read", "Bytes read on the block devices.", true, func(ns NodeStats) []uint64 { return ns.BlockRead }},
+	{"block_write", "Bytes written on the block devices.", true, func(ns NodeStats) []uint64 { return ns.BlockWrite }},
+	{"pids", "Processes and threads of the node.", false, func(ns NodeStats) []uint64 { return ns.PIDs }},
+	{"memory_cache", "Memory used by the page cache.", false, func(ns NodeStats) []uint64 { return ns.MemoryCache }},
+	{"memory_rss", "Anonymous memory used by the processes.", false, func(ns NodeStats) []uint64 { return ns.MemoryRSS }},
+}
+
+// row is the resource usage of a node at a point in time. A value is nil
+// when the column is not measured.
+type row struct {
+	node string
+	// timestamp is in seconds with the milliseconds when they are known.
+	timestamp float64
+	values    []*uint64
+}
+
+// makeRows returns one row per node and timestamp ordered by node.
+func makeRows(stats Stats) []row {
+	rows := make([]row, 0)
+
+	for _, node := range sortedNodes(stats) {
+		ns := stats.Nodes[node]
+
+		for i, ts := range ns.Seconds() {
+			r := row{node: node, timestamp: ts, values: make([]*uint64, len(columns))}
+
+			for j, col := range columns {
+				values := col.values(ns)
+				if i < len(values) {
+					r.values[j] = &values[i]
+				}
+			}
+
+			rows = append(rows, r)
+		}
+	}
+
+	return rows
+}
+
+// WriteCSV writes the resource usage of the nodes with one row per node and
+// timestamp. The first row is the header and the values that are not measured
+// are left empty.
+func WriteCSV(out io.Writer, stats Stats) error {
+	w := csv.NewWriter(out)
+
+	header := []string{"node", "timestamp"}
+	for _, col := range columns {
+		header = append(header, col.name)
+	}
+
+	err := w.Write(header)
+	if err != nil {
+		return xerrors.Errorf("couldn't write the header: %v", err)
+	}
+
+	for _, r := range makeRows(stats) {
+		record := []string{r.node, formatTimestamp(r.timestamp)}
+		for _, value := range r.values {
+			if value == nil {
+				record = append(record, "")
+			} else {
+				record = append(record, strconv.FormatUint(*value, 10))
+			}
+		}
+
+		err = w.Write(record)
+		if err != nil {
+			return xerrors.Errorf("couldn't write the row: %v", err)
+		}
+	}
+
+	w.Flush()
+
+	err = w.Error()
+	if err != nil {
+		return xerrors.Errorf("couldn't flush: %v", err)
+	}
+
+	return nil
+}
+
+// WriteColumnarJSON writes the resource usage of the nodes as a JSON object
+// where each column is an array of the values of every row, in the same order
+// as the CSV format. The values that are not measured are null. It can be
+// loaded as is in a data frame.
+func WriteColumnarJSON(out io.Writer, stats Stats) error {
+	rows := makeRows(stats)
+
+	nodes := make([]string, len(rows))
+	timestamps := make([]float64, len(rows))
+	values := make([][]*uint64, len(columns))
+	for j := range values {
+		values[j] = make([]*uint64, len(rows))
+	}
+
+	for i, r := range rows {
+		nodes[i] = r.node
+		timestamps[i] = r.timestamp
+
+		for j, value := range r.values {
+			values[j][i] = value
+		}
+	}
+
+	// The object is written by hand to keep the order of the columns.
+	w := bufio.NewWriter(out)
+	w.WriteString("{")
+
+	err := writeJSONField(w, "node", nodes)
+	if err != nil {
+		return err
+	}
+
+	w.WriteString(",")
+
+	err = writeJSONField(w, "timestamp", timestamps)
+	if err != nil {
+		return err
+	}
+
+	for j, col := range columns {
+		w.WriteString(",")
+
+		err = writeJSONField(w, col.name, values[j])
+		if err != nil {
+			return err
+		}
+	}
+
+	w.WriteString("}\n")
+
+	err = w.Flush()
+	if err != nil {
+		return xerrors.Errorf("couldn't flush: %v", err)
+	}
+
+	return nil
+}
+
+func writeJSONField(w io.Writer, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return xerrors.Errorf("couldn't encode '%s': %v", name, err)
+	}
+
+	fmt.Fprintf(w, "%q:%s", name, data)
+
+	return nil
+}
+
+// WriteOpenMetrics writes the statistics in the OpenMetrics text format with
+// the timestamps of the samples so that they can be imported as a backfill.
+// The resource usage, the traffic of the peers, the series scraped from the
+// application and the custom metrics have one family per column, and the
+// histograms are stamped with the end of the run as they summarize it.
+func WriteOpenMetrics(out io.Writer, stats Stats) error {
+	w := bufio.NewWriter(out)
+	nodes := sortedNodes(stats)
+
+	for _, col := range columns {
+		family := OpenMetricsPrefix + col.name
+		writeFamilyHeader(w, family, col.help, col.counter)
+
+		for _, node := range nodes {
+			ns := stats.Nodes[node]
+			values := col.values(ns)
+			labels := formatLabels("node", node)
+
+			for i, ts := range ns.Seconds() {
+				if i < len(values) {
+					writeSample(w, family, col.counter, labels, float64(values[i]), ts)
+				}
+			}
+		}
+	}
+
+	writePeers(w, stats, nodes)
+	writeApplication(w, stats, nodes)
+	writeCustomMetrics(w, stats)
+	writeHistograms(w, stats)
+	writeTags(w, stats)
+
+	w.WriteString("# EOF\n")
+
+	err := w.Flush()
+	if err != nil {
+		return xerrors.Errorf("couldn't flush: %v", err)
+	}
+
+	return nil
+}
+
+func writePeers(w io.Writer, stats Stats, nodes []string) {
+	directions := []struct {
+		name   string
+		help   string
+		values func(PeerStats) []uint64
+	}{
+		{"peer_rx_bytes", "Bytes received from a peer.", func(ps PeerStats) []uint64 { return ps.RxBytes }},
+		{"peer_tx_bytes", "Bytes transmitted to a peer.", func(ps PeerStats) []uint64 { return ps.TxBytes }},
+	}
+
+	for _, dir := range directions {
+		family := OpenMetricsPrefix + dir.name
+		writeFamilyHeader(w, family, dir.help, true)
+
+		for _, node := range nodes {
+			ns := stats.Nodes[node]
+
+			for _, peer := range sortedKeys(ns.Peers) {
+				values := dir.values(ns.Peers[peer])
+				labels := formatLabels("node", node, "peer", peer)
+
+				for i, ts := range ns.Seconds() {
+					if i < len(values) {
+						writeSample(w, family, true, labels, float64(values[i]), ts)
+					}
+				}
+			}
+		}
+	}
+}
+
+// writeApplication writes the series scraped from the application with the
+// name of the node added to their labels, and the prefix of the simulation
+// added to their name. The type of the series is unknown.
+func writeApplication(w io.Writer, stats Stats, nodes []string) {
+	families := make(map[string][]string)
+	for _, node := range nodes {
+		for key := range stats.Nodes[node].Application {
+			name := key
+			if i := strings.Index(key, "{"); i >= 0 {
+				name = key[:i]
+			}
+
+			families[name] = append(families[name], node+"\x00"+key)
+		}
+	}
+
+	for _, name := range sortedKeys(families) {
+		family := OpenMetricsPrefix + "app_" + sanitizeName(name)
+		fmt.Fprintf(w, "# TYPE %s unknown\n", family)
+
+		entries := families[name]
+		sort.Strings(entries)
+
+		for _, entry := range entries {
+			parts := strings.SplitN(entry, "\x00", 2)
+			node, key := parts[0], parts[1]
+
+			labels := formatLabels("node", node)
+			if i := strings.Index(key, "{"); i >= 0 && len(key)-i > 2 {
+				labels = labels[:len(labels)-1] + "," + key[i+1:]
+			}
+
+			series := stats.Nodes[node].Application[key]
+			for i, ts := range series.Timestamps {
+				// The series are stored per second so only the last sample of
+				// a second is written when the monitor samples more often.
+				if i+1 < len(series.Timestamps) && series.Timestamps[i+1] == ts {
+					continue
+				}
+
+				writeSample(w, family, false, labels, series.Values[i], float64(ts))
+			}
+		}
+	}
+}
+
+func writeCustomMetrics(w io.Writer, stats Stats) {
+	for _, name := range sortedKeys(stats.Metrics) {
+		family := OpenMetricsPrefix + "custom_" + sanitizeName(name)
+		writeFamilyHeader(w, family, "Custom metric "+name+".", false)
+
+		m := stats.Metrics[name]
+
+		for _, sample := range m.Samples {
+			writeSampleNano(w, family, "", sample)
+		}
+
+		for _, node := range sortedKeys(m.Nodes) {
+			labels := formatLabels("node", node)
+
+			for _, sample := range m.Nodes[node] {
+				writeSampleNano(w, family, labels, sample)
+			}
+		}
+	}
+}
+
+func writeHistograms(w io.Writer, stats Stats) {
+	ts := endOfRun(stats)
+
+	for _, name := range sortedKeys(stats.Histograms) {
+		family := OpenMetricsPrefix + "custom_" + sanitizeName(name)
+		fmt.Fprintf(w, "# TYPE %s histogram\n", family)
+		fmt.Fprintf(w, "# HELP %s Custom histogram %s.\n", family, name)
+
+		hm := stats.Histograms[name]
+
+		if hm.Histogram.Count > 0 {
+			writeHistogram(w, family, nil, hm.Histogram, ts)
+		}
+
+		for _, node := range sortedKeys(hm.Nodes) {
+			writeHistogram(w, family, []string{"node", node}, hm.Nodes[node], ts)
+		}
+	}
+}
+
+func writeHistogram(w io.Writer, family string, labels []string, h Histogram, ts float64) {
+	stamp := formatTimestamp(ts)
+
+	cumul := uint64(0)
+	for i, count := range h.Counts {
+		cumul += count
+
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = formatFloat(h.Bounds[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d %s\n", family, formatLabels(append(labels, "le", le)...), cumul, stamp)
+	}
+
+	fmt.Fprintf(w, "%s_count%s %d %s\n", family, formatLabels(labels...), h.Count, stamp)
+	fmt.Fprintf(w, "%s_sum%s %s %s\n", family, formatLabels(labels...), formatFloat(h.Sum), stamp)
+}
+
+// endOfRun returns the latest timestamp in seconds among the samples of the
+// nodes, the custom metrics and the tags, or the beginning of the statistics
+// when there is none.
+func endOfRun(stats Stats) float64 {
+	end := float64(stats.Timestamp)
+
+	for _, ns := range stats.Nodes {
+		seconds := ns.Seconds()
+		if len(seconds) > 0 {
+			end = math.Max(end, seconds[len(seconds)-1])
+		}
+	}
+
+	for _, m := range stats.Metrics {
+		for _, sample := range m.Samples {
+			end = math.Max(end, float64(sample.Timestamp)/1e9)
+		}
+
+		for _, samples := range m.Nodes {
+			for _, sample := range samples {
+				end = math.Max(end, float64(sample.Timestamp)/1e9)
+			}
+		}
+	}
+
+	for ts := range stats.Tags {
+		end = math.Max(end, float64(ts)/1e9)
+	}
+
+	return end
+}
+
+func writeTags(w io.Writer, stats Stats) {
+	family := OpenMetricsPrefix + "tag"
+	fmt.Fprintf(w, "# TYPE %s info\n", family)
+	fmt.Fprintf(w, "# HELP %s Points in time tagged by the simulation.\n", family)
+
+	keys := make([]int64, 0, len(stats.Tags))
+	for ts := range stats.Tags {
+		keys = append(keys, ts)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, ts := range keys {
+		writeSampleNano(w, family+"_info", formatLabels("tag", stats.Tags[ts]), Sample{Timestamp: ts, Value: 1})
+	}
+}
+
+func writeFamilyHeader(w io.Writer, family, help string, counter bool) {
+	kind := "gauge"
+	if counter {
+		kind = "counter"
+	}
+
+	fmt.Fprintf(w, "# TYPE %s %s\n", family, kind)
+	fmt.Fprintf(w, "# HELP %s %s\n", family, help)
+}
+
+func writeSample(w io.Writer, family string, counter bool, labels string, value, ts float64) {
+	if counter {
+		family += "_total"
+	}
+
+	fmt.Fprintf(w, "%s%s %s %s\n", family, labels, formatFloat(value), formatTimestamp(ts))
+}
+
+// writeSampleNano writes a sample with a timestamp in nanoseconds which is
+// converted in seconds with a fractional part.
+func writeSampleNano(w io.Writer, family, labels string, sample Sample) {
+	ts := strconv.FormatFloat(float64(sample.Timestamp)/1e9, 'f', 9, 64)
+	fmt.Fprintf(w, "%s%s %s %s\n", family, labels, formatFloat(sample.Value), ts)
+}
+
+// formatLabels returns the labels for the pairs of name and value, or an
+// empty string when there is none.
+func formatLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", pairs[i], value))
+	}
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// formatTimestamp returns the timestamp in seconds without the fractional part
+// when it is a whole second.
+func formatTimestamp(ts float64) string {
+	return strconv.FormatFloat(ts, 'f', -1, 64)
+}
+
+func sanitizeName(name string) string {
+	return reInvalidName.ReplaceAllString(name, "_")
+}
+
+func sortedNodes(stats Stats) []string {
+	return sortedKeys(stats.Nodes)
+}
+
+// sortedKeys returns the keys of a map indexed by strings in order.
+func sortedKeys(m interface{}) []string {
+	value := reflect.ValueOf(m)
+
+	keys := make([]string, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		keys = append(keys, key.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport_WriteCSV(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	err := WriteCSV(out, makeTestStats())
+	require.NoError(t, err)
+
+	lines := strings.Split(out.String(), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, "node,timestamp,rx_bytes,tx_bytes,cpu,memory,rx_packets,tx_packets,"+
+		"rx_errors,tx_errors,rx_dropped,tx_dropped,retransmissions,block_read,block_write,pids,"+
+		"memory_cache,memory_rss", lines[0])
+	require.Equal(t, "node0,1,10,20,30,40,,,,,,,1,,,,,", lines[1])
+	require.Equal(t, "node0,2,11,21,31,41,,,,,,,,,,,,", lines[2])
+	require.Equal(t, "node1,1,0,0,0,0,,,,,,,,,,,,", lines[3])
+
+	// The samples of the same second are distinct with the milliseconds.
+	stats := makeTestStats()
+	ns := stats.Nodes["node0"]
+	ns.Timestamps = []int64{1, 1}
+	ns.TimestampsMs = []int64{1000, 1250}
+	stats.Nodes["node0"] = ns
+
+	out.Reset()
+	require.NoError(t, WriteCSV(out, stats))
+
+	lines = strings.Split(out.String(), "\n")
+	require.True(t, strings.HasPrefix(lines[1], "node0,1,"))
+	require.True(t, strings.HasPrefix(lines[2], "node0,1.25,"))
+
+	out.Reset()
+	require.NoError(t, WriteOpenMetrics(out, stats))
+	require.Contains(t, out.String(), `simnet_rx_bytes_total{node="node0"} 11 1.25`)
+
+	err = WriteCSV(badWriter{}, makeTestStats())
+	require.EqualError(t, err, "couldn't flush: oops")
+}
+
+func TestExport_WriteColumnarJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	err := WriteColumnarJSON(out, makeTestStats())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out.String(), `{"node":["node0","node0","node1"],"timestamp":[1,2,1],"rx_bytes":[10,11,0]`))
+
+	data := make(map[string][]interface{})
+	require.NoError(t, json.Unmarshal(out.Bytes(), &data))
+	require.Len(t, data, 18)
+	require.Equal(t, []interface{}{1.0, nil, nil}, data["retransmissions"])
+
+	err = WriteColumnarJSON(badWriter{}, makeTestStats())
+	require.EqualError(t, err, "couldn't flush: oops")
+}
+
+func TestExport_WriteOpenMetrics(t *testing.T) {
+	stats := makeTestStats()
+	stats.Tags[1500000000] = "Init"
+	stats.Metrics["block-height"] = Metric{
+		Samples: []Sample{{Timestamp: 1500000000, Value: 2}},
+		Nodes:   map[string][]Sample{"node0": {{Timestamp: 2000000000, Value: 3}}},
+	}
+
+	h := NewHistogram([]float64{1, 2})
+	h.Observe(1.5)
+	stats.Histograms["latency"] = HistogramMetric{Nodes: map[string]Histogram{"node0": h}}
+
+	out := new(bytes.Buffer)
+	err := WriteOpenMetrics(out, stats)
+	require.NoError(t, err)
+
+	text := out.String()
+	require.Contains(t, text, "# TYPE simnet_rx_bytes counter\n")
+	require.Contains(t, text, "simnet_rx_bytes_total{node=\"node0\"} 10 1\n")
+	require.Contains(t, text, "# TYPE simnet_cpu gauge\n")
+	require.Contains(t, text, "simnet_cpu{node=\"node0\"} 31 2\n")
+	require.Contains(t, text, "simnet_retransmissions_total{node=\"node0\"} 1 1\n")
+	require.NotContains(t, text, "simnet_retransmissions_total{node=\"node0\"} 1 2\n")
+	require.Contains(t, text, "simnet_peer_tx_bytes_total{node=\"node0\",peer=\"node1\"} 6 2\n")
+	require.Contains(t, text, "# TYPE simnet_app_sql_count unknown\n")
+	require.Contains(t, text, "simnet_app_sql_count{node=\"node0\",type=\"select\"} 4 1\n")
+	require.Contains(t, text, "simnet_app_raft_leaders{node=\"node0\"} 3 2\n")
+	require.Contains(t, text, "simnet_custom_block_height 2 1.500000000\n")
+	require.Contains(t, text, "simnet_custom_block_height{node=\"node0\"} 3 2.000000000\n")
+	// The histograms are stamped with the end of the run.
+	require.Contains(t, text, "simnet_custom_latency_bucket{node=\"node0\",le=\"1\"} 0 2\n")
+	require.Contains(t, text, "simnet_custom_latency_bucket{node=\"node0\",le=\"+Inf\"} 1 2\n")
+	require.Contains(t, text, "simnet_custom_latency_count{node=\"node0\"} 1 2\n")
+	require.Contains(t, text, "simnet_custom_latency_sum{node=\"node0\"} 1.5 2\n")
+	require.Contains(t, text, "simnet_tag_info{tag=\"Init\"} 1 1.500000000\n")
+	require.True(t, strings.HasSuffix(text, "# EOF\n"))
+
+	err = WriteOpenMetrics(badWriter{}, stats)
+	require.EqualError(t, err, "couldn't flush: oops")
+}
+
+func TestExport_EndOfRun(t *testing.T) {
+	stats := NewStats()
+	stats.Timestamp = 1
+	require.Equal(t, 1.0, endOfRun(stats))
+
+	stats.Nodes["node0"] = NodeStats{Timestamps: []int64{2, 3}}
+	require.Equal(t, 3.0, endOfRun(stats))
+
+	stats.Metrics["height"] = Metric{
+		Nodes: map[string][]Sample{"node0": {{Timestamp: 3500000000}}},
+	}
+	require.Equal(t, 3.5, endOfRun(stats))
+
+	stats.Tags[4000000000] = "done"
+	require.Equal(t, 4.0, endOfRun(stats))
+}
+
+func TestExport_FormatLabels(t *testing.T) {
+	require.Equal(t, "", formatLabels())
+	require.Equal(t, `{a="b\"\\\n"}`, formatLabels("a", "b\"\\\n"))
+}
+
+func makeTestStats() Stats {
+	stats := NewStats()
+	stats.Nodes["node0"] = NodeStats{
+		Timestamps:      []int64{1, 2},
+		RxBytes:         []uint64{10, 11},
+		TxBytes:         []uint64{20, 21},
+		CPU:             []uint64{30, 31},
+		Memory:          []uint64{40, 41},
+		Retransmissions: []uint64{1},
+		Peers: map[string]PeerStats{
+			"node1": {RxBytes: []uint64{3, 4}, TxBytes: []uint64{5, 6}},
+		},
+		Application: map[string]Series{
+			`sql_count{type="select"}`: {Timestamps: []int64{1}, Values: []float64{4}},
+			"raft_leaders":             {Timestamps: []int64{2}, Values: []float64{3}},
+		},
+	}
+	stats.Nodes["node1"] = NodeStats{
+		Timestamps: []int64{1},
+		RxBytes:    []uint64{0},
+		TxBytes:    []uint64{0},
+		CPU:        []uint64{0},
+		Memory:     []uint64{0},
+	}
+
+	return stats
+}
+
+type badWriter struct{}
+
+func (badWriter) Write([]byte) (int, error) {
+	return 0, errors.New("oops")
+}
@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Snapshot is the latest resource usage of a node.
+type Snapshot struct {
+	Timestamp int64
+	CPU       uint64
+	Memory    uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+	// RxRate and TxRate are the number of bytes per second over the last
+	// interval, or zero when there is only one sample.
+	RxRate float64
+	TxRate float64
+}
+
+// Snapshot returns the latest sample of the node. The boolean is false when
+// the node has no sample yet.
+func (ns NodeStats) Snapshot() (Snapshot, bool) {
+	n := len(ns.Timestamps)
+	if n == 0 {
+		return Snapshot{}, false
+	}
+
+	last := func(values []uint64) uint64 {
+		if len(values) < n {
+			return 0
+		}
+
+		return values[n-1]
+	}
+
+	snap := Snapshot{
+		Timestamp: ns.Timestamps[n-1],
+		CPU:       last(ns.CPU),
+		Memory:    last(ns.Memory),
+		RxBytes:   last(ns.RxBytes),
+		TxBytes:   last(ns.TxBytes),
+		RxPackets: last(ns.RxPackets),
+		TxPackets: last(ns.TxPackets),
+	}
+
+	rx := ns.RxRate()
+	if rx.Len() > 0 {
+		snap.RxRate = rx.Values[rx.Len()-1]
+	}
+
+	tx := ns.TxRate()
+	if tx.Len() > 0 {
+		snap.TxRate = tx.Values[tx.Len()-1]
+	}
+
+	return snap, true
+}
+
+// liveFamily is a metric family exposed by the live endpoint.
+type liveFamily struct {
+	name    string
+	help    string
+	counter bool
+	value   func(Snapshot) float64
+}
+
+var liveFamilies = []liveFamily{
+	{"cpu", "CPU usage of the node.", false, func(s Snapshot) float64 { return float64(s.CPU) }},
+	{"memory", "Memory used by the node.", false, func(s Snapshot) float64 { return float64(s.Memory) }},
+	{"rx_bytes_total", "Bytes received by the node.", true, func(s Snapshot) float64 { return float64(s.RxBytes) }},
+	{"tx_bytes_total", "Bytes transmitted by the node.", true, func(s Snapshot) float64 { return float64(s.TxBytes) }},
+	{"rx_packets_total", "Packets received by the node.", true, func(s Snapshot) float64 { return float64(s.RxPackets) }},
+	{"tx_packets_total", "Packets transmitted by the node.", true, func(s Snapshot) float64 { return float64(s.TxPackets) }},
+	{"rx_rate_bytes", "Bytes received per second by the node.", false, func(s Snapshot) float64 { return s.RxRate }},
+	{"tx_rate_bytes", "Bytes transmitted per second by the node.", false, func(s Snapshot) float64 { return s.TxRate }},
+}
+
+// NewLiveHandler returns a handler that serves the current resource usage of
+// the nodes given by the source. The Prometheus text format is available at
+// /metrics and the JSON API at /api/nodes for every node, or at
+// /api/nodes/<name> for a single one.
+func NewLiveHandler(source func() map[string]Snapshot) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeLive(w, source())
+	})
+
+	mux.HandleFunc("/api/nodes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, source())
+	})
+
+	mux.HandleFunc("/api/nodes/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/api/nodes/"):]
+
+		snap, ok := source()[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown node '%s'", name), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, snap)
+	})
+
+	return mux
+}
+
+func writeLive(out http.ResponseWriter, snapshots map[string]Snapshot) {
+	w := bufio.NewWriter(out)
+
+	nodes := make([]string, 0, len(snapshots))
+	for node := range snapshots {
+		nodes = append(nodes, node)
+	}
+
+	sort.Strings(nodes)
+
+	for _, family := range liveFamilies {
+		name := OpenMetricsPrefix + family.name
+
+		kind := "gauge"
+		if family.counter {
+			kind = "counter"
+		}
+
+		fmt.Fprintf(w, "# HELP %s %s\n", name, family.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+
+		for _, node := range nodes {
+			value := family.value(snapshots[node])
+			fmt.Fprintf(w, "%s%s %s\n", name, formatLabels("node", node), formatFloat(value))
+		}
+	}
+
+	w.Flush()
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
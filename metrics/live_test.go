@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeStats_Snapshot(t *testing.T) {
+	ns := NodeStats{
+		Timestamps: []int64{10, 12},
+		RxBytes:    []uint64{100, 300},
+		TxBytes:    []uint64{50, 450},
+		CPU:        []uint64{20, 30},
+		Memory:     []uint64{1000, 2000},
+		RxPackets:  []uint64{1, 3},
+		TxPackets:  []uint64{2},
+	}
+
+	snap, ok := ns.Snapshot()
+	require.True(t, ok)
+	require.Equal(t, Snapshot{
+		Timestamp: 12,
+		CPU:       30,
+		Memory:    2000,
+		RxBytes:   300,
+		TxBytes:   450,
+		RxPackets: 3,
+		RxRate:    100,
+		TxRate:    200,
+	}, snap)
+
+	snap, ok = NodeStats{Timestamps: []int64{10}, CPU: []uint64{5}}.Snapshot()
+	require.True(t, ok)
+	require.Equal(t, Snapshot{Timestamp: 10, CPU: 5}, snap)
+
+	_, ok = NodeStats{}.Snapshot()
+	require.False(t, ok)
+}
+
+func TestLiveHandler_Metrics(t *testing.T) {
+	srv := httptest.NewServer(NewLiveHandler(makeTestSnapshots))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "# TYPE simnet_cpu gauge\n")
+	require.Contains(t, string(body), "simnet_cpu{node=\"node0\"} 10\nsimnet_cpu{node=\"node1\"} 20\n")
+	require.Contains(t, string(body), "# TYPE simnet_rx_bytes_total counter\n")
+	require.Contains(t, string(body), "simnet_rx_bytes_total{node=\"node1\"} 200\n")
+	require.Contains(t, string(body), "simnet_tx_rate_bytes{node=\"node0\"} 1.5\n")
+
+	values, err := ParsePrometheus(bytes.NewReader(body), []string{"simnet_memory"})
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+}
+
+func TestLiveHandler_API(t *testing.T) {
+	srv := httptest.NewServer(NewLiveHandler(makeTestSnapshots))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/nodes")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	snapshots := make(map[string]Snapshot)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&snapshots))
+	require.Equal(t, makeTestSnapshots(), snapshots)
+
+	resp, err = http.Get(srv.URL + "/api/nodes/node1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	snap := Snapshot{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&snap))
+	require.Equal(t, makeTestSnapshots()["node1"], snap)
+
+	resp, err = http.Get(srv.URL + "/api/nodes/node2")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func makeTestSnapshots() map[string]Snapshot {
+	return map[string]Snapshot{
+		"node0": {Timestamp: 1, CPU: 10, Memory: 100, RxBytes: 100, TxRate: 1.5},
+		"node1": {Timestamp: 1, CPU: 20, Memory: 200, RxBytes: 200},
+	}
+}
@@ -3,25 +3,46 @@ package metrics
 import (
 	"bufio"
 	"io"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/xerrors"
 	"gonum.org/v1/gonum/stat"
 )
 
+// maxLineLength is the maximum length of a line of the data files and of the
+// Prometheus endpoints, which can be long when many series are scraped.
+const maxLineLength = 16 * 1024 * 1024
+
 // Stats represents the JSON structure of the statistics written for each node.
 type Stats struct {
+	// Version is the version of the format of the statistics.
+	Version int
+	// Units are the units of the values indexed by the name of the column.
+	Units     map[string]string
 	Timestamp int64
 	Tags      map[int64]string
 	Nodes     map[string]NodeStats
+	// Metrics are the custom metrics recorded by the rounds.
+	Metrics map[string]Metric
+	// Histograms are the custom histograms recorded by the rounds.
+	Histograms map[string]HistogramMetric
+	// Parameters describe the options of the simulation.
+	Parameters map[string]string
 }
 
 // NewStats returns a new instance of a statistics object.
 func NewStats() Stats {
 	return Stats{
-		Tags:  make(map[int64]string),
-		Nodes: make(map[string]NodeStats),
+		Version:    StatsVersion,
+		Units:      DefaultUnits(),
+		Tags:       make(map[int64]string),
+		Nodes:      make(map[string]NodeStats),
+		Metrics:    make(map[string]Metric),
+		Histograms: make(map[string]HistogramMetric),
+		Parameters: make(map[string]string),
 	}
 }
 
@@ -33,34 +54,354 @@ type NodeStats struct {
 	TxBytes    []uint64
 	CPU        []uint64
 	Memory     []uint64
+	RxPackets  []uint64
+	TxPackets  []uint64
+	RxErrors   []uint64
+	TxErrors   []uint64
+	RxDropped  []uint64
+	TxDropped  []uint64
+	// TimestampsMs are the timestamps in milliseconds when the monitor
+	// provides them. It is empty otherwise.
+	TimestampsMs []int64
+	// Retransmissions is the number of TCP segments retransmitted. It is
+	// empty when the strategy cannot measure it.
+	Retransmissions []uint64
+	// BlockRead and BlockWrite are the number of bytes read and written on
+	// the block devices. They are empty when the metric set is not selected
+	// in the monitor, like the following ones.
+	BlockRead  []uint64
+	BlockWrite []uint64
+	// PIDs is the number of processes and threads.
+	PIDs []uint64
+	// MemoryCache and MemoryRSS are the parts of the memory used by the page
+	// cache and by the anonymous memory of the processes.
+	MemoryCache []uint64
+	MemoryRSS   []uint64
+	// Peers contains the traffic exchanged with each distant node, indexed
+	// by its address or by its name when it is resolved. It is empty when
+	// the strategy cannot measure it.
+	Peers map[string]PeerStats
+	// Application contains the series scraped from the metrics endpoint of
+	// the application, indexed by the name and the labels of the series.
+	Application map[string]Series
+}
+
+// PeerStats contains the cumulative number of bytes exchanged with a distant
+// node. The values are aligned with the timestamps of the node statistics.
+type PeerStats struct {
+	RxBytes []uint64
+	TxBytes []uint64
 }
 
-// NewNodeStats creates statistics for a node by reading the reader line by line.
-func NewNodeStats(reader io.Reader, start, end time.Time) NodeStats {
-	ns := NodeStats{}
+// NewNodeStats creates statistics for a node by reading the data file of the
+// monitor line by line. The file starts with a header that describes the
+// columns of the lines, otherwise the lines have the legacy format which
+// starts with the timestamp in seconds, the received and transmitted bytes,
+// the CPU and the memory, and can be followed by the packets, the errors, the
+// drops and the retransmissions. The values can then be followed by segments
+// separated by a semicolon for the traffic of each peer and for the series
+// scraped from the application. The default counters that are missing are
+// filled with zeros so that every array is aligned with the timestamps, while
+// the extra ones are left empty when they are not in the header. The CPU of
+// the files written by older monitors is converted from percents. It returns
+// the statistics read so far with an error when the file cannot be read.
+func NewNodeStats(reader io.Reader, start, end time.Time) (NodeStats, error) {
+	ns := NodeStats{
+		Peers:       make(map[string]PeerStats),
+		Application: make(map[string]Series),
+	}
+
+	var columns []string
+	version := 0
 
 	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+
 	for scanner.Scan() {
 		line := scanner.Text()
-		numbers := parseLine(line)
 
-		if len(numbers) >= 5 {
-			ts := int64(numbers[0])
+		header, v, ok := parseHeader(line)
+		if ok {
+			columns = header
+			version = v
+			continue
+		}
+
+		segments := strings.Split(line, ";")
+
+		var values map[string]uint64
+		if columns == nil {
+			values, ok = legacyValues(segments[0])
+		} else {
+			values, ok = parseValues(segments[0], columns)
+		}
+
+		if !ok {
+			continue
+		}
 
-			if ts >= start.Unix() && ts <= end.Unix() {
-				ns.Timestamps = append(ns.Timestamps, ts)
-				ns.RxBytes = append(ns.RxBytes, numbers[1])
-				ns.TxBytes = append(ns.TxBytes, numbers[2])
-				ns.CPU = append(ns.CPU, numbers[3])
-				ns.Memory = append(ns.Memory, numbers[4])
+		ts := int64(values[ColumnTimestamp])
+
+		ms, precise := values[ColumnTimestampMs]
+		if precise {
+			ts = int64(ms) / 1000
+		}
+
+		if ts < start.Unix() || ts > end.Unix() {
+			continue
+		}
+
+		if version < cpuScaleVersion {
+			// Older monitors wrote the percentage of the CPU.
+			values[ColumnCPU] *= CPUScale
+		}
+
+		for _, col := range legacyColumns[1:] {
+			// The default counters are aligned with the timestamps.
+			_, ok := values[col]
+			if !ok {
+				values[col] = 0
 			}
 		}
+
+		ns.Append(ts, values)
+		if precise {
+			ns.TimestampsMs = append(ns.TimestampsMs, int64(ms))
+		}
+
+		ns.appendPeers(segments[1:])
+		ns.appendApplication(ts, segments[1:])
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return ns, xerrors.Errorf("couldn't read: %v", err)
+	}
+
+	return ns, nil
+}
+
+// Append appends the timestamp in seconds and the values of the columns
+// found in the map. The columns that are missing are left untouched so the
+// caller must provide the same ones at every timestamp.
+func (ns *NodeStats) Append(ts int64, values map[string]uint64) {
+	ns.Timestamps = append(ns.Timestamps, ts)
+
+	for col, value := range values {
+		field := ns.column(col)
+		if field != nil {
+			*field = append(*field, value)
+		}
+	}
+}
+
+// column returns the array of the values of the column.
+func (ns *NodeStats) column(name string) *[]uint64 {
+	switch name {
+	case ColumnRxBytes:
+		return &ns.RxBytes
+	case ColumnTxBytes:
+		return &ns.TxBytes
+	case ColumnCPU:
+		return &ns.CPU
+	case ColumnMemory:
+		return &ns.Memory
+	case ColumnRxPackets:
+		return &ns.RxPackets
+	case ColumnTxPackets:
+		return &ns.TxPackets
+	case ColumnRxErrors:
+		return &ns.RxErrors
+	case ColumnTxErrors:
+		return &ns.TxErrors
+	case ColumnRxDropped:
+		return &ns.RxDropped
+	case ColumnTxDropped:
+		return &ns.TxDropped
+	case ColumnRetransmissions:
+		return &ns.Retransmissions
+	case ColumnBlockRead:
+		return &ns.BlockRead
+	case ColumnBlockWrite:
+		return &ns.BlockWrite
+	case ColumnPIDs:
+		return &ns.PIDs
+	case ColumnMemoryCache:
+		return &ns.MemoryCache
+	case ColumnMemoryRSS:
+		return &ns.MemoryRSS
+	default:
+		return nil
+	}
+}
+
+// appendPeers appends the traffic of the peers described by the segments. A
+// peer seen for the first time is padded with zeros, and a peer missing from
+// the segments keeps its previous value as the counters are cumulative.
+func (ns *NodeStats) appendPeers(segments []string) {
+	n := len(ns.Timestamps)
+
+	for _, segment := range segments {
+		parts := strings.Split(segment, ",")
+		if len(parts) != 3 {
+			continue
+		}
+
+		rx, err := parseInteger(parts[1])
+		if err != nil {
+			continue
+		}
+
+		tx, err := parseInteger(parts[2])
+		if err != nil {
+			continue
+		}
+
+		addr := strings.TrimSpace(parts[0])
+
+		peer := ns.Peers[addr]
+		if len(peer.RxBytes) >= n {
+			// Duplicated peer in the same line.
+			continue
+		}
+
+		peer.RxBytes = append(peer.RxBytes, make([]uint64, n-1-len(peer.RxBytes))...)
+		peer.TxBytes = append(peer.TxBytes, make([]uint64, n-1-len(peer.TxBytes))...)
+		peer.RxBytes = append(peer.RxBytes, rx)
+		peer.TxBytes = append(peer.TxBytes, tx)
+
+		ns.Peers[addr] = peer
+	}
+
+	for addr, peer := range ns.Peers {
+		if len(peer.RxBytes) < n {
+			peer.RxBytes = append(peer.RxBytes, peer.RxBytes[len(peer.RxBytes)-1])
+			peer.TxBytes = append(peer.TxBytes, peer.TxBytes[len(peer.TxBytes)-1])
+			ns.Peers[addr] = peer
+		}
+	}
+}
+
+// appendApplication appends the values of the series scraped from the
+// application. A segment of a series is the escaped key of the series and the
+// value separated by an equal sign.
+func (ns *NodeStats) appendApplication(ts int64, segments []string) {
+	values := make(map[string]float64)
+
+	for _, segment := range segments {
+		parts := strings.Split(segment, "=")
+		if len(parts) != 2 {
+			continue
+		}
+
+		key, err := url.QueryUnescape(strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			continue
+		}
+
+		values[key] = value
+	}
+
+	ns.AddApplication(ts, values)
+}
+
+// AddApplication appends the values of the series scraped from the
+// application at the given timestamp.
+func (ns *NodeStats) AddApplication(ts int64, values map[string]float64) {
+	if len(values) > 0 && ns.Application == nil {
+		ns.Application = make(map[string]Series)
+	}
+
+	for key, value := range values {
+		s := ns.Application[key]
+		s.Timestamps = append(s.Timestamps, ts)
+		s.Values = append(s.Values, value)
+		ns.Application[key] = s
+	}
+}
+
+// ResolvePeers replaces the addresses of the peers by the names found in the
+// mapping. Unknown addresses are kept as is.
+func (ns *NodeStats) ResolvePeers(names map[string]string) {
+	peers := make(map[string]PeerStats, len(ns.Peers))
+	for addr, peer := range ns.Peers {
+		name, ok := names[addr]
+		if !ok {
+			name = addr
+		}
+
+		peers[name] = peer
+	}
+
+	ns.Peers = peers
+}
+
+// precise returns true when the timestamps in milliseconds are available for
+// every sample.
+func (ns NodeStats) precise() bool {
+	return len(ns.TimestampsMs) > 0 && len(ns.TimestampsMs) == len(ns.Timestamps)
+}
+
+// Seconds returns the timestamps of the samples in seconds. The milliseconds
+// are included when the monitor provides them so that the samples taken more
+// than once per second are distinct.
+func (ns NodeStats) Seconds() []float64 {
+	seconds := make([]float64, len(ns.Timestamps))
+	for i, ts := range ns.Timestamps {
+		if ns.precise() {
+			seconds[i] = float64(ns.TimestampsMs[i]) / 1000
+		} else {
+			seconds[i] = float64(ts)
+		}
 	}
 
-	return ns
+	return seconds
+}
+
+// rate derives the rate per second of the counter, from the timestamps in
+// milliseconds when they are available.
+func (ns NodeStats) rate(values []uint64) Series {
+	if !ns.precise() {
+		return NewSeries(ns.Timestamps, values).Rate()
+	}
+
+	rate := NewSeries(ns.TimestampsMs, values).Rate()
+	for i := range rate.Values {
+		rate.Timestamps[i] /= 1000
+		rate.Values[i] *= 1000
+	}
+
+	return rate
+}
+
+// RxRate returns the number of bytes received per second.
+func (ns NodeStats) RxRate() Series {
+	return ns.rate(ns.RxBytes)
+}
+
+// TxRate returns the number of bytes transmitted per second.
+func (ns NodeStats) TxRate() Series {
+	return ns.rate(ns.TxBytes)
+}
+
+// CPUUsage returns the usage of the CPU over each interval. The monitor
+// already computes it between two samples so it does not need to be derived.
+func (ns NodeStats) CPUUsage() Series {
+	return NewSeries(ns.Timestamps, ns.CPU)
+}
+
+// MemoryUsage returns the memory used at each sample.
+func (ns NodeStats) MemoryUsage() Series {
+	return NewSeries(ns.Timestamps, ns.Memory)
 }
 
-// Max returns the maximum value for each column.
+// Max returns the maximum value for each column. The network columns are
+// cumulative counters so RxRate and TxRate should be used for the throughput.
 func (ns NodeStats) Max() (uint64, uint64, uint64, uint64) {
 	cpu := uint64(0)
 	mem := uint64(0)
@@ -88,7 +429,8 @@ func (ns NodeStats) Max() (uint64, uint64, uint64, uint64) {
 	return cpu, mem, tx, rx
 }
 
-// Average returns the average for each column.
+// Average returns the average for each column. The network columns are
+// cumulative counters so RxRate and TxRate should be used for the throughput.
 func (ns NodeStats) Average() (float64, float64, float64, float64) {
 	cpu := uint64(0)
 	mem := uint64(0)
@@ -111,7 +453,9 @@ func (ns NodeStats) Average() (float64, float64, float64, float64) {
 	return acpu, amem, atx, arx
 }
 
-// StdDev returns the standard deviation for each column.
+// StdDev returns the standard deviation for each column. The network columns
+// are cumulative counters so RxRate and TxRate should be used for the
+// throughput.
 func (ns NodeStats) StdDev() (float64, float64, float64, float64) {
 	cpu := make([]float64, len(ns.Timestamps))
 	mem := make([]float64, len(ns.Timestamps))
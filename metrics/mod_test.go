@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,9 +25,112 @@ func TestStats_NodeStats(t *testing.T) {
 	lines := []byte(testLines)
 	reader := bytes.NewReader(lines)
 
-	ns := NewNodeStats(reader, time.Unix(0, 0), time.Unix(1, 0))
+	ns, err := NewNodeStats(reader, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
 	require.NotNil(t, ns)
 	require.Equal(t, 2, len(ns.Timestamps))
+	require.Equal(t, []uint64{0, 0}, ns.Retransmissions)
+	require.Empty(t, ns.Peers)
+}
+
+const testExtendedLines = `
+1,2,3,4,5,6,7,8,9,10,11,12;10.0.0.2,100,200
+2,2,3,4,5,6,7,8,9,10,11,13;10.0.0.3,10,20;abc;10.0.0.4,a,b
+3,2,3,4,5,6,7,8,9,10,11,14;10.0.0.2,300,400;10.0.0.2,500,600
+`
+
+func TestStats_NodeStatsExtended(t *testing.T) {
+	reader := bytes.NewReader([]byte(testExtendedLines))
+
+	ns, err := NewNodeStats(reader, time.Unix(0, 0), time.Unix(3, 0))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3}, ns.Timestamps)
+	require.Equal(t, []uint64{6, 6, 6}, ns.RxPackets)
+	require.Equal(t, []uint64{7, 7, 7}, ns.TxPackets)
+	require.Equal(t, []uint64{8, 8, 8}, ns.RxErrors)
+	require.Equal(t, []uint64{9, 9, 9}, ns.TxErrors)
+	require.Equal(t, []uint64{10, 10, 10}, ns.RxDropped)
+	require.Equal(t, []uint64{11, 11, 11}, ns.TxDropped)
+	require.Equal(t, []uint64{12, 13, 14}, ns.Retransmissions)
+
+	require.Len(t, ns.Peers, 2)
+	require.Equal(t, PeerStats{
+		RxBytes: []uint64{100, 100, 300},
+		TxBytes: []uint64{200, 200, 400},
+	}, ns.Peers["10.0.0.2"])
+	require.Equal(t, PeerStats{
+		RxBytes: []uint64{0, 10, 10},
+		TxBytes: []uint64{0, 20, 20},
+	}, ns.Peers["10.0.0.3"])
+
+	require.Empty(t, ns.Application)
+
+	ns.ResolvePeers(map[string]string{"10.0.0.2": "node1"})
+	require.Len(t, ns.Peers, 2)
+	require.Contains(t, ns.Peers, "node1")
+	require.Contains(t, ns.Peers, "10.0.0.3")
+}
+
+func TestStats_NodeStatsApplication(t *testing.T) {
+	lines := "1,2,3,4,5;10.0.0.2,1,2;raft_leaders=3;sql_count%7Btype%3D%22select%22%7D=4.5\n" +
+		"2,2,3,4,5;raft_leaders=2;bad=abc;%zz=1\n"
+
+	ns, err := NewNodeStats(bytes.NewBufferString(lines), time.Unix(0, 0), time.Unix(2, 0))
+	require.NoError(t, err)
+	require.Len(t, ns.Peers, 1)
+	require.Equal(t, map[string]Series{
+		"raft_leaders":             {Timestamps: []int64{1, 2}, Values: []float64{3, 2}},
+		`sql_count{type="select"}`: {Timestamps: []int64{1}, Values: []float64{4.5}},
+	}, ns.Application)
+
+	ns = NodeStats{}
+	ns.AddApplication(1, nil)
+	require.Nil(t, ns.Application)
+	ns.AddApplication(1, map[string]float64{"a": 1})
+	require.Len(t, ns.Application, 1)
+}
+
+func TestStats_NodeStatsLongLines(t *testing.T) {
+	// The series of the application can make a line longer than the default
+	// buffer of the scanner.
+	long := ";app=1" + strings.Repeat(";other=1", 10*1024)
+	lines := "1,2,3,4,5" + long + "\n2,2,3,4,5" + long + "\n3,2,3,4,5\n"
+
+	ns, err := NewNodeStats(bytes.NewBufferString(lines), time.Unix(0, 0), time.Unix(3, 0))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3}, ns.Timestamps)
+	require.Len(t, ns.Application["app"].Values, 2)
+
+	lines = "1,2,3,4,5;app=" + strings.Repeat(" ", maxLineLength) + "1\n"
+
+	_, err = NewNodeStats(bytes.NewBufferString(lines), time.Unix(0, 0), time.Unix(3, 0))
+	require.EqualError(t, err, "couldn't read: bufio.Scanner: token too long")
+}
+
+func TestNodeStats_Rates(t *testing.T) {
+	ns := NodeStats{
+		Timestamps: []int64{1, 2, 4},
+		RxBytes:    []uint64{0, 100, 300},
+		TxBytes:    []uint64{0, 50, 250},
+		CPU:        []uint64{10, 20, 30},
+		Memory:     []uint64{1, 2, 3},
+	}
+
+	require.Equal(t, []float64{100, 100}, ns.RxRate().Values)
+	require.Equal(t, []float64{50, 100}, ns.TxRate().Values)
+	require.Equal(t, []float64{10, 20, 30}, ns.CPUUsage().Values)
+	require.Equal(t, []float64{1, 2, 3}, ns.MemoryUsage().Values)
+
+	// The samples are taken every 250ms at 4000 bytes per second.
+	ns = NodeStats{
+		Timestamps:   []int64{1, 1, 1, 1, 2},
+		TimestampsMs: []int64{1000, 1250, 1500, 1750, 2000},
+		RxBytes:      []uint64{0, 1000, 2000, 3000, 4000},
+	}
+
+	require.Equal(t, []float64{4000, 4000, 4000, 4000}, ns.RxRate().Values)
+	require.Equal(t, []int64{1, 1, 1, 2}, ns.RxRate().Timestamps)
+	require.Equal(t, []float64{1, 1.25, 1.5, 1.75, 2}, ns.Seconds())
 }
 
 func TestNodeStats_Max(t *testing.T) {
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// MonitorVersion is the version of the format of the data file written by the
+// monitor.
+const MonitorVersion = 3
+
+// cpuScaleVersion is the first version of the data file where the CPU is
+// written in hundredths of a percent.
+const cpuScaleVersion = 3
+
+// Columns of the data file written by the monitor.
+const (
+	ColumnTimestamp       = "timestamp"
+	ColumnTimestampMs     = "timestamp_ms"
+	ColumnRxBytes         = "rx_bytes"
+	ColumnTxBytes         = "tx_bytes"
+	ColumnCPU             = "cpu"
+	ColumnMemory          = "memory"
+	ColumnRxPackets       = "rx_packets"
+	ColumnTxPackets       = "tx_packets"
+	ColumnRxErrors        = "rx_errors"
+	ColumnTxErrors        = "tx_errors"
+	ColumnRxDropped       = "rx_dropped"
+	ColumnTxDropped       = "tx_dropped"
+	ColumnRetransmissions = "retransmissions"
+	ColumnBlockRead       = "block_read"
+	ColumnBlockWrite      = "block_write"
+	ColumnPIDs            = "pids"
+	ColumnMemoryCache     = "memory_cache"
+	ColumnMemoryRSS       = "memory_rss"
+)
+
+// legacyColumns are the columns of the data files written without a header.
+var legacyColumns = []string{
+	ColumnTimestamp, ColumnRxBytes, ColumnTxBytes, ColumnCPU, ColumnMemory,
+	ColumnRxPackets, ColumnTxPackets, ColumnRxErrors, ColumnTxErrors,
+	ColumnRxDropped, ColumnTxDropped, ColumnRetransmissions,
+}
+
+// DefaultColumns are the columns always written by the monitor.
+var DefaultColumns = append([]string{ColumnTimestampMs}, legacyColumns[1:]...)
+
+// ExtraColumns are the columns of the metric sets that the monitor writes on
+// demand, indexed by the name of the set.
+var ExtraColumns = map[string][]string{
+	"blkio":  {ColumnBlockRead, ColumnBlockWrite},
+	"pids":   {ColumnPIDs},
+	"memory": {ColumnMemoryCache, ColumnMemoryRSS},
+}
+
+const headerPrefix = "# simnet-monitor"
+
+// MonitorColumns returns the columns written by the monitor for the given
+// metric sets, or an error if a set is unknown.
+func MonitorColumns(sets []string) ([]string, error) {
+	columns := append([]string{}, DefaultColumns...)
+
+	for _, set := range sets {
+		extra, ok := ExtraColumns[set]
+		if !ok {
+			return nil, xerrors.Errorf("unknown metric set '%s'", set)
+		}
+
+		columns = append(columns, extra...)
+	}
+
+	return columns, nil
+}
+
+// FormatHeader returns the first line of the data file which describes the
+// version of the format and the columns of the lines.
+func FormatHeader(columns []string) string {
+	return fmt.Sprintf("%s version=%d columns=%s\n", headerPrefix, MonitorVersion, strings.Join(columns, ","))
+}
+
+// parseHeader returns the columns and the version described by the header,
+// or false if the line is not a header.
+func parseHeader(line string) ([]string, int, bool) {
+	if !strings.HasPrefix(line, headerPrefix) {
+		return nil, 0, false
+	}
+
+	var columns []string
+	version := 0
+
+	for _, field := range strings.Fields(line[len(headerPrefix):]) {
+		switch {
+		case strings.HasPrefix(field, "columns="):
+			columns = strings.Split(strings.TrimPrefix(field, "columns="), ",")
+		case strings.HasPrefix(field, "version="):
+			version, _ = strconv.Atoi(strings.TrimPrefix(field, "version="))
+		}
+	}
+
+	return columns, version, columns != nil
+}
+
+// parseValues returns the values of a line described by the header. The line
+// is ignored when a value is invalid or the timestamp is missing.
+func parseValues(line string, columns []string) (map[string]uint64, bool) {
+	fields := strings.Split(line, ",")
+	if len(fields) > len(columns) {
+		return nil, false
+	}
+
+	values := make(map[string]uint64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		values[columns[i]] = value
+	}
+
+	_, sec := values[ColumnTimestamp]
+	_, ms := values[ColumnTimestampMs]
+
+	return values, sec || ms
+}
+
+// legacyValues returns the values of a line of a data file without a header.
+// It needs at least the columns up to the memory.
+func legacyValues(line string) (map[string]uint64, bool) {
+	numbers := parseLine(line)
+	if len(numbers) < 5 {
+		return nil, false
+	}
+
+	values := make(map[string]uint64, len(legacyColumns))
+	for i, col := range legacyColumns {
+		if i < len(numbers) {
+			values[col] = numbers[i]
+		}
+	}
+
+	return values, true
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor_Columns(t *testing.T) {
+	columns, err := MonitorColumns(nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultColumns, columns)
+	require.Equal(t, ColumnTimestampMs, columns[0])
+
+	columns, err = MonitorColumns([]string{"pids", "blkio"})
+	require.NoError(t, err)
+	require.Equal(t, []string{ColumnPIDs, ColumnBlockRead, ColumnBlockWrite}, columns[len(DefaultColumns):])
+
+	_, err = MonitorColumns([]string{"abc"})
+	require.EqualError(t, err, "unknown metric set 'abc'")
+}
+
+func TestMonitor_Header(t *testing.T) {
+	header := FormatHeader([]string{"timestamp_ms", "rx_bytes"})
+	require.Equal(t, "# simnet-monitor version=3 columns=timestamp_ms,rx_bytes\n", header)
+
+	columns, version, ok := parseHeader(header)
+	require.True(t, ok)
+	require.Equal(t, MonitorVersion, version)
+	require.Equal(t, []string{"timestamp_ms", "rx_bytes"}, columns)
+
+	_, _, ok = parseHeader("# simnet-monitor version=2")
+	require.False(t, ok)
+
+	_, _, ok = parseHeader("1,2,3,4,5")
+	require.False(t, ok)
+}
+
+func TestMonitor_NodeStats(t *testing.T) {
+	lines := FormatHeader([]string{ColumnTimestampMs, ColumnCPU, ColumnRxBytes, ColumnPIDs, ColumnMemoryRSS, "unknown"}) +
+		"1500,10,100,3,1000,7;10.0.0.2,1,2;raft_leaders=3\n" +
+		"2250,20,200,4,2000,7\n" +
+		"3000,abc,300,5,3000,7\n" +
+		"3500,30,300,5,3000,7,8\n" +
+		"\n" +
+		"4000,40\n"
+
+	ns, err := NewNodeStats(bytes.NewBufferString(lines), time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 4}, ns.Timestamps)
+	require.Equal(t, []int64{1500, 2250, 4000}, ns.TimestampsMs)
+	require.Equal(t, []uint64{10, 20, 40}, ns.CPU)
+	require.Equal(t, []uint64{100, 200, 0}, ns.RxBytes)
+	require.Equal(t, []uint64{0, 0, 0}, ns.Retransmissions)
+	require.Equal(t, []uint64{3, 4}, ns.PIDs)
+	require.Equal(t, []uint64{1000, 2000}, ns.MemoryRSS)
+	require.Empty(t, ns.BlockRead)
+	require.Len(t, ns.Peers, 1)
+	require.Equal(t, Series{Timestamps: []int64{1}, Values: []float64{3}}, ns.Application["raft_leaders"])
+
+	ns, err = NewNodeStats(bytes.NewBufferString(lines), time.Unix(2, 0), time.Unix(3, 0))
+	require.NoError(t, err)
+	require.Equal(t, []int64{2250}, ns.TimestampsMs)
+
+	// The CPU of older monitors is converted.
+	lines = "# simnet-monitor version=2 columns=timestamp_ms,cpu\n1000,12\n"
+	ns, err = NewNodeStats(bytes.NewBufferString(lines), time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1200}, ns.CPU)
+
+	ns, err = NewNodeStats(bytes.NewBufferString("1,2,3,12,5\n"), time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1200}, ns.CPU)
+
+	// A header without the timestamp leaves the lines unreadable.
+	ns, err = NewNodeStats(bytes.NewBufferString(FormatHeader([]string{ColumnCPU})+"1\n"), time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	require.Empty(t, ns.Timestamps)
+}
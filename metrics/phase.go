@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// PhaseStartSuffix is the suffix of the tag that marks the beginning of a
+	// phase.
+	PhaseStartSuffix = ":start"
+	// PhaseEndSuffix is the suffix of the tag that marks the end of a phase.
+	PhaseEndSuffix = ":end"
+)
+
+// Phase is a range of time of the simulation delimited by a pair of tags.
+type Phase struct {
+	Name string
+	// Start and End are the timestamps of the tags in nanoseconds.
+	Start int64
+	End   int64
+}
+
+// PhaseStart returns the name of the tag that marks the beginning of the
+// phase.
+func PhaseStart(name string) string {
+	return name + PhaseStartSuffix
+}
+
+// PhaseEnd returns the name of the tag that marks the end of the phase.
+func PhaseEnd(name string) string {
+	return name + PhaseEndSuffix
+}
+
+// Phases returns the phases of the tags sorted by their beginning. An end tag
+// closes the latest phase of the same name that is still open, so that a phase
+// can be repeated, and the tags without a counterpart are ignored.
+func (s Stats) Phases() []Phase {
+	keys := make([]int64, 0, len(s.Tags))
+	for key := range s.Tags {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	open := make(map[string][]int64)
+	phases := make([]Phase, 0)
+
+	for _, ts := range keys {
+		tag := s.Tags[ts]
+
+		if strings.HasSuffix(tag, PhaseStartSuffix) {
+			name := strings.TrimSuffix(tag, PhaseStartSuffix)
+			open[name] = append(open[name], ts)
+		} else if strings.HasSuffix(tag, PhaseEndSuffix) {
+			name := strings.TrimSuffix(tag, PhaseEndSuffix)
+
+			starts := open[name]
+			if len(starts) == 0 {
+				continue
+			}
+
+			phases = append(phases, Phase{
+				Name:  name,
+				Start: starts[len(starts)-1],
+				End:   ts,
+			})
+
+			open[name] = starts[:len(starts)-1]
+		}
+	}
+
+	sort.SliceStable(phases, func(i, j int) bool { return phases[i].Start < phases[j].Start })
+
+	return phases
+}
+
+// Phase returns the first phase with the given name, or false when the tags
+// do not delimit such a phase.
+func (s Stats) Phase(name string) (Phase, bool) {
+	for _, phase := range s.Phases() {
+		if phase.Name == name {
+			return phase, true
+		}
+	}
+
+	return Phase{}, false
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhase_Names(t *testing.T) {
+	require.Equal(t, "init:start", PhaseStart("init"))
+	require.Equal(t, "init:end", PhaseEnd("init"))
+}
+
+func TestStats_Phases(t *testing.T) {
+	stats := NewStats()
+	stats.Tags = map[int64]string{
+		10: PhaseStart("init"),
+		20: PhaseEnd("init"),
+		25: "checkpoint",
+		30: PhaseStart("round"),
+		40: PhaseEnd("round"),
+		50: PhaseStart("round"),
+		55: PhaseStart("round"),
+		60: PhaseEnd("round"),
+		70: PhaseEnd("unknown"),
+		80: PhaseStart("failed"),
+	}
+
+	phases := stats.Phases()
+	require.Equal(t, []Phase{
+		{Name: "init", Start: 10, End: 20},
+		{Name: "round", Start: 30, End: 40},
+		{Name: "round", Start: 55, End: 60},
+	}, phases)
+
+	phase, ok := stats.Phase("round")
+	require.True(t, ok)
+	require.Equal(t, int64(30), phase.Start)
+
+	_, ok = stats.Phase("failed")
+	require.False(t, ok)
+
+	require.Empty(t, Stats{}.Phases())
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ParsePrometheus parses the text format of a Prometheus endpoint and returns
+// the values of the selected series indexed by the name followed by the
+// labels as written by the endpoint. A series is selected when either its
+// name or its full key is in the list, thus nothing is selected when the list
+// is empty. Values that are not finite are ignored as they cannot be stored.
+func ParsePrometheus(reader io.Reader, selected []string) (map[string]float64, error) {
+	filter := make(map[string]struct{})
+	for _, name := range selected {
+		filter[name] = struct{}{}
+	}
+
+	values := make(map[string]float64)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, name, rest, err := splitSeries(line)
+		if err != nil {
+			return nil, xerrors.Errorf("couldn't parse '%s': %v", line, err)
+		}
+
+		_, byName := filter[name]
+		_, byKey := filter[key]
+		if !byName && !byKey {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, xerrors.Errorf("missing value for '%s'", key)
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid value '%s'", fields[0])
+		}
+
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		values[key] = value
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't read: %v", err)
+	}
+
+	return values, nil
+}
+
+// splitSeries returns the key of the series, its name and the remaining of the
+// line. The labels are kept as is but the quoted values can contain any
+// character thus they are skipped while looking for the end of the labels.
+func splitSeries(line string) (string, string, string, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end < 0 {
+		return "", "", "", xerrors.New("missing value")
+	}
+
+	name := line[:end]
+	if line[end] != '{' {
+		return name, name, line[end:], nil
+	}
+
+	quoted := false
+	for i := end + 1; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quoted:
+			i++
+		case line[i] == '"':
+			quoted = !quoted
+		case line[i] == '}' && !quoted:
+			return line[:i+1], name, line[i+1:], nil
+		}
+	}
+
+	return "", "", "", xerrors.New("unterminated labels")
+}
+
+// Scrape fetches the Prometheus endpoint at the url and returns the values of
+// the selected series.
+func Scrape(client *http.Client, url string, selected []string) (map[string]float64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't fetch: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("unexpected status '%s'", resp.Status)
+	}
+
+	values, err := ParsePrometheus(resp.Body, selected)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't parse: %v", err)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPrometheus = `# HELP sql_count Number of SQL statements
+# TYPE sql_count counter
+sql_count{type="select",query="a} b"} 42
+sql_count{type="insert"} 7 1600000000000
+
+raft_leaders 3
+go_goroutines +Inf
+go_threads NaN
+`
+
+func TestPrometheus_Parse(t *testing.T) {
+	values, err := ParsePrometheus(bytes.NewBufferString(testPrometheus),
+		[]string{"sql_count", "raft_leaders", "go_goroutines", "go_threads"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{
+		`sql_count{type="select",query="a} b"}`: 42,
+		`sql_count{type="insert"}`:              7,
+		"raft_leaders":                          3,
+	}, values)
+
+	values, err = ParsePrometheus(bytes.NewBufferString(testPrometheus),
+		[]string{"raft_leaders", `sql_count{type="insert"}`})
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	require.Equal(t, 7.0, values[`sql_count{type="insert"}`])
+
+	// Nothing is selected by an empty list.
+	values, err = ParsePrometheus(bytes.NewBufferString(testPrometheus), nil)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	_, err = ParsePrometheus(bytes.NewBufferString("abc"), nil)
+	require.EqualError(t, err, "couldn't parse 'abc': missing value")
+
+	_, err = ParsePrometheus(bytes.NewBufferString(`abc{a="b" 1`), nil)
+	require.EqualError(t, err, `couldn't parse 'abc{a="b" 1': unterminated labels`)
+
+	_, err = ParsePrometheus(bytes.NewBufferString("abc{} "), []string{"abc"})
+	require.EqualError(t, err, "missing value for 'abc{}'")
+
+	_, err = ParsePrometheus(bytes.NewBufferString("abc def"), []string{"abc"})
+	require.EqualError(t, err, "invalid value 'def'")
+}
+
+func TestPrometheus_Scrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			fmt.Fprint(w, testPrometheus)
+		case "/bad":
+			fmt.Fprint(w, "abc")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	values, err := Scrape(srv.Client(), srv.URL+"/metrics", []string{"raft_leaders"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"raft_leaders": 3}, values)
+
+	_, err = Scrape(srv.Client(), srv.URL+"/unknown", nil)
+	require.EqualError(t, err, "unexpected status '404 Not Found'")
+
+	_, err = Scrape(srv.Client(), srv.URL+"/bad", nil)
+	require.EqualError(t, err, "couldn't parse: couldn't parse 'abc': missing value")
+
+	_, err = Scrape(srv.Client(), "http://\000", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't fetch: ")
+}
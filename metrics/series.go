@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// Series is a timeline of values where the timestamps are in seconds.
+type Series struct {
+	Timestamps []int64
+	Values     []float64
+}
+
+// NewSeries creates a series from the timestamps and the values of a column
+// of the statistics. The columns that are not measured can be shorter than the
+// timestamps in which case the series is truncated.
+func NewSeries(timestamps []int64, values []uint64) Series {
+	n := len(timestamps)
+	if len(values) < n {
+		n = len(values)
+	}
+
+	s := Series{
+		Timestamps: make([]int64, n),
+		Values:     make([]float64, n),
+	}
+
+	for i := 0; i < n; i++ {
+		s.Timestamps[i] = timestamps[i]
+		s.Values[i] = float64(values[i])
+	}
+
+	return s
+}
+
+// Len returns the number of points in the series.
+func (s Series) Len() int {
+	return len(s.Values)
+}
+
+// Rate derives the rate per second of a cumulative counter. Each point is the
+// rate over the interval that ends at its timestamp, thus the first point of
+// the counter is dropped. A counter that decreases has been reset and the
+// rate is computed from zero.
+func (s Series) Rate() Series {
+	rate := Series{
+		Timestamps: make([]int64, 0, s.Len()),
+		Values:     make([]float64, 0, s.Len()),
+	}
+
+	// base is the index of the last sample that ends an interval, and the
+	// increase is accumulated since then.
+	base := 0
+	increase := 0.0
+
+	for i := 1; i < s.Len(); i++ {
+		diff := s.Values[i] - s.Values[i-1]
+		if diff < 0 {
+			diff = s.Values[i]
+		}
+
+		increase += diff
+
+		elapsed := s.Timestamps[i] - s.Timestamps[base]
+		if elapsed <= 0 {
+			// Samples of the same second are merged in the next interval.
+			continue
+		}
+
+		rate.Timestamps = append(rate.Timestamps, s.Timestamps[i])
+		rate.Values = append(rate.Values, increase/float64(elapsed))
+
+		base = i
+		increase = 0
+	}
+
+	return rate
+}
+
+// Increase returns the total increase of a cumulative counter over the series
+// while taking the resets into account.
+func (s Series) Increase() float64 {
+	total := 0.0
+	for i := 1; i < s.Len(); i++ {
+		diff := s.Values[i] - s.Values[i-1]
+		if diff < 0 {
+			diff = s.Values[i]
+		}
+
+		total += diff
+	}
+
+	return total
+}
+
+// Window returns the points of the series with a timestamp between start and
+// end, both included.
+func (s Series) Window(start, end int64) Series {
+	w := Series{}
+	for i, ts := range s.Timestamps {
+		if ts >= start && ts <= end {
+			w.Timestamps = append(w.Timestamps, ts)
+			w.Values = append(w.Values, s.Values[i])
+		}
+	}
+
+	return w
+}
+
+// Total returns the sum of the values.
+func (s Series) Total() float64 {
+	total := 0.0
+	for _, v := range s.Values {
+		total += v
+	}
+
+	return total
+}
+
+// Max returns the maximum value, or zero for an empty series.
+func (s Series) Max() float64 {
+	max := 0.0
+	for i, v := range s.Values {
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+
+	return max
+}
+
+// Average returns the mean of the values, or zero for an empty series.
+func (s Series) Average() float64 {
+	if s.Len() == 0 {
+		return 0
+	}
+
+	return stat.Mean(s.Values, nil)
+}
+
+// StdDev returns the standard deviation of the values, or zero when there are
+// less than two values.
+func (s Series) StdDev() float64 {
+	if s.Len() < 2 {
+		return 0
+	}
+
+	return stat.StdDev(s.Values, nil)
+}
+
+// Percentile returns the value below which the given fraction of the values
+// falls, where the fraction is between 0 and 1. It returns zero for an empty
+// series.
+func (s Series) Percentile(p float64) float64 {
+	if s.Len() == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, s.Len())
+	copy(sorted, s.Values)
+	sort.Float64s(sorted)
+
+	return stat.Quantile(p, stat.Empirical, sorted, nil)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeries_New(t *testing.T) {
+	s := NewSeries([]int64{1, 2, 3}, []uint64{4, 5, 6})
+	require.Equal(t, Series{Timestamps: []int64{1, 2, 3}, Values: []float64{4, 5, 6}}, s)
+
+	s = NewSeries([]int64{1, 2, 3}, nil)
+	require.Equal(t, 0, s.Len())
+}
+
+func TestSeries_Rate(t *testing.T) {
+	s := NewSeries([]int64{10, 11, 13, 13, 14, 15}, []uint64{100, 200, 600, 650, 50, 150})
+
+	rate := s.Rate()
+	require.Equal(t, []int64{11, 13, 14, 15}, rate.Timestamps)
+	// The bytes of the sample of the same second are counted in the next
+	// interval.
+	require.Equal(t, []float64{100, 200, 100, 100}, rate.Values)
+
+	require.Equal(t, 0, Series{}.Rate().Len())
+
+	s = NewSeries([]int64{0, 1, 1, 2}, []uint64{0, 100, 200, 300})
+	rate = s.Rate()
+	require.Equal(t, []int64{1, 2}, rate.Timestamps)
+	require.Equal(t, []float64{100, 200}, rate.Values)
+	require.Equal(t, s.Increase(), rate.Total())
+}
+
+func TestSeries_Increase(t *testing.T) {
+	s := NewSeries([]int64{1, 2, 3, 4}, []uint64{100, 200, 50, 150})
+	require.Equal(t, 250.0, s.Increase())
+
+	require.Equal(t, 0.0, Series{}.Increase())
+}
+
+func TestSeries_Window(t *testing.T) {
+	s := NewSeries([]int64{1, 2, 3, 4}, []uint64{10, 20, 30, 40})
+
+	w := s.Window(2, 3)
+	require.Equal(t, []int64{2, 3}, w.Timestamps)
+	require.Equal(t, []float64{20, 30}, w.Values)
+
+	require.Equal(t, 0, s.Window(5, 10).Len())
+}
+
+func TestSeries_Summaries(t *testing.T) {
+	s := NewSeries([]int64{1, 2, 3, 4, 5}, []uint64{5, 1, 4, 2, 3})
+
+	require.Equal(t, 15.0, s.Total())
+	require.Equal(t, 5.0, s.Max())
+	require.Equal(t, 3.0, s.Average())
+	require.InDelta(t, 1.58, s.StdDev(), 0.01)
+	require.Equal(t, 1.0, s.Percentile(0))
+	require.Equal(t, 3.0, s.Percentile(0.5))
+	require.Equal(t, 5.0, s.Percentile(0.95))
+	require.Equal(t, []float64{5, 1, 4, 2, 3}, s.Values)
+
+	empty := Series{}
+	require.Equal(t, 0.0, empty.Max())
+	require.Equal(t, 0.0, empty.Average())
+	require.Equal(t, 0.0, empty.StdDev())
+	require.Equal(t, 0.0, empty.Percentile(0.5))
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
+	"go.dedis.ch/simnet/sim"
+	"golang.org/x/xerrors"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+)
+
+// Modes of aggregation of the lines of the nodes.
+const (
+	// AggregateNone draws one line per node.
+	AggregateNone = ""
+	// AggregateMinMax draws the mean with a band between the minimum and the
+	// maximum.
+	AggregateMinMax = "minmax"
+	// AggregatePercentile draws the mean with a band that contains a
+	// percentage of the values.
+	AggregatePercentile = "percentile"
+	// AggregateStacked draws the totals of the groups stacked on each other.
+	AggregateStacked = "stacked"
+	// AggregateCDF draws the distribution of the values of the nodes.
+	AggregateCDF = "cdf"
+
+	// DefaultBand is the default percentage of the values inside the band of
+	// the percentile mode.
+	DefaultBand = 90.0
+
+	// groupAll is the name of the group when the nodes are not grouped.
+	groupAll = "all"
+	// bandAlpha is the opacity of the bands.
+	bandAlpha = 0x40
+)
+
+// aggregation describes how the lines of the nodes are combined.
+type aggregation struct {
+	mode string
+	// band is the fraction of the values inside the band of the percentile
+	// mode.
+	band float64
+	// groups is the group of each node, or nil when every node is part of
+	// the same group.
+	groups map[string]string
+}
+
+func makeAggregation(mode string, band float64, groups map[string]string) (aggregation, error) {
+	switch mode {
+	case AggregateNone:
+		if groups != nil {
+			return aggregation{}, xerrors.New("the grouping needs an aggregation")
+		}
+	case AggregateMinMax, AggregateStacked, AggregateCDF:
+	case AggregatePercentile:
+		if band <= 0 || band > 100 {
+			return aggregation{}, xerrors.Errorf("invalid band %.1f", band)
+		}
+	default:
+		return aggregation{}, xerrors.Errorf("unknown aggregation '%s'", mode)
+	}
+
+	return aggregation{mode: mode, band: band / 100, groups: groups}, nil
+}
+
+// groupOf returns the name of the group of the node.
+func (a aggregation) groupOf(node string) string {
+	if a.groups == nil {
+		return groupAll
+	}
+
+	group, ok := a.groups[node]
+	if !ok {
+		return "other"
+	}
+
+	return group
+}
+
+// bounds returns the lower and the upper bound of the band of sorted values.
+func (a aggregation) bounds(sorted []float64) (float64, float64) {
+	if a.mode == AggregatePercentile {
+		tail := (1 - a.band) / 2
+
+		return stat.Quantile(tail, stat.Empirical, sorted, nil),
+			stat.Quantile(1-tail, stat.Empirical, sorted, nil)
+	}
+
+	return sorted[0], sorted[len(sorted)-1]
+}
+
+// readAreas returns the name of the area of each node of the topology, which
+// must be made of areas.
+func readAreas(filename string) (map[string]string, error) {
+	topo, err := sim.ReadTopology(filename)
+	if err != nil {
+		return nil, xerrors.New(errNoTopology)
+	}
+
+	at, ok := topo.(*network.AreaTopology)
+	if !ok {
+		return nil, xerrors.New("the topology has no area")
+	}
+
+	groups := make(map[string]string)
+	for _, node := range at.GetNodes() {
+		groups[string(node.Name)] = fmt.Sprintf("area%d", at.AreaOf(node.Name))
+	}
+
+	return groups, nil
+}
+
+// groupedLines are the points of the nodes indexed by the name of the line,
+// then by the group and the node.
+type groupedLines map[string]map[string]map[string]plotter.XYs
+
+func (g groupedLines) add(line, group, node string, points plotter.XYs) {
+	if g[line] == nil {
+		g[line] = make(map[string]map[string]plotter.XYs)
+	}
+
+	if g[line][group] == nil {
+		g[line][group] = make(map[string]plotter.XYs)
+	}
+
+	g[line][group][node] = points
+}
+
+// label returns the name of the line of a group.
+func (a aggregation) label(line, group string) string {
+	if a.groups == nil {
+		return line
+	}
+
+	return group + "-" + line
+}
+
+// processAggregate produces the plot where the lines of the nodes are
+// combined according to the aggregation.
+func (p usagePlot) processAggregate(stats *metrics.Stats, tl timeline) (*plot.Plot, error) {
+	lines := make(groupedLines)
+	counters := make(map[string]bool)
+
+	for node, ns := range stats.Nodes {
+		for prefix, m := range p.mappers {
+			name := prefix[1:]
+			lines.add(name, p.agg.groupOf(node), node, makePoints(ns, m, tl))
+			counters[name] = prefix == "-tx" || prefix == "-rx"
+		}
+	}
+
+	for _, name := range p.metrics {
+		for node, samples := range stats.Metrics[name].Nodes {
+			lines.add(name, p.agg.groupOf(node), node, makeSamplePoints(samples, tl))
+		}
+	}
+
+	plot, err := p.factory()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.agg.mode == AggregateCDF {
+		plot.X.Label.Text = "value"
+		plot.Y.Label.Text = "fraction of the nodes"
+
+		err = p.processor(plot, p.makeCDFs(lines, counters)...)
+		if err != nil {
+			return nil, err
+		}
+
+		return plot, nil
+	}
+
+	tl.decorate(plot, stats)
+
+	if p.agg.mode == AggregateStacked {
+		for _, line := range lines.names() {
+			xs, stacks := p.makeStacks(line, lines[line])
+
+			err = p.stacker(plot, xs, stacks...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return plot, nil
+	}
+
+	means := make([]interface{}, 0)
+	for _, line := range lines.names() {
+		for _, group := range groupNames(lines[line]) {
+			mean, band := p.agg.summarize(lines[line][group])
+
+			if len(band) > 0 {
+				polygon, err := plotter.NewPolygon(band)
+				if err != nil {
+					return nil, err
+				}
+
+				polygon.Color = transparent(plotutil.Color(len(means) / 2))
+				polygon.LineStyle.Width = 0
+				plot.Add(polygon)
+			}
+
+			means = append(means, p.agg.label(line, group), mean)
+		}
+	}
+
+	// The samples of the whole simulation are drawn as they are.
+	for _, name := range p.metrics {
+		samples := stats.Metrics[name].Samples
+		if len(samples) > 0 {
+			means = append(means, name, makeSamplePoints(samples, tl))
+		}
+	}
+
+	err = p.processor(plot, means...)
+	if err != nil {
+		return nil, err
+	}
+
+	return plot, nil
+}
+
+// summarize returns the mean of the lines of the nodes for each second, and
+// the polygon of the band around it.
+func (a aggregation) summarize(nodes map[string]plotter.XYs) (plotter.XYs, plotter.XYs) {
+	buckets := bucketize(nodes)
+
+	keys := make([]int64, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	mean := make(plotter.XYs, len(keys))
+	band := make(plotter.XYs, 2*len(keys))
+
+	for i, key := range keys {
+		values := buckets[key]
+		sort.Float64s(values)
+
+		low, high := a.bounds(values)
+		x := float64(key)
+
+		mean[i] = plotter.XY{X: x, Y: stat.Mean(values, nil)}
+		// The band goes forward on the lower bound and backward on the
+		// upper one.
+		band[i] = plotter.XY{X: x, Y: low}
+		band[len(band)-1-i] = plotter.XY{X: x, Y: high}
+	}
+
+	return mean, band
+}
+
+// makeStacks returns the timestamps and the cumulative totals of the groups
+// from the tallest to the shortest so that each one is painted over the
+// previous.
+func (p usagePlot) makeStacks(line string, groups map[string]map[string]plotter.XYs) (plotter.Values, []interface{}) {
+	totals := make(map[string]map[int64]float64)
+	timestamps := make(map[int64]struct{})
+
+	for group, nodes := range groups {
+		totals[group] = make(map[int64]float64)
+
+		for key, values := range bucketize(nodes) {
+			for _, v := range values {
+				totals[group][key] += v
+			}
+
+			timestamps[key] = struct{}{}
+		}
+	}
+
+	keys := make([]int64, 0, len(timestamps))
+	for key := range timestamps {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	xs := make(plotter.Values, len(keys))
+	for i, key := range keys {
+		xs[i] = float64(key)
+	}
+
+	names := groupNames(groups)
+	cumul := make(plotter.Values, len(keys))
+	stacks := make([]interface{}, 0, 2*len(names))
+
+	for _, group := range names {
+		values := make(plotter.Values, len(keys))
+		for i, key := range keys {
+			cumul[i] += totals[group][key]
+			values[i] = cumul[i]
+		}
+
+		stacks = append([]interface{}{p.agg.label(line, group), values}, stacks...)
+	}
+
+	return xs, stacks
+}
+
+// makeCDFs returns the cumulative distribution of the nodes of each group,
+// where the value of a node is its average over the run, or the last value
+// of the counters.
+func (p usagePlot) makeCDFs(lines groupedLines, counters map[string]bool) []interface{} {
+	cdfs := make([]interface{}, 0)
+
+	for _, line := range lines.names() {
+		for _, group := range groupNames(lines[line]) {
+			values := make([]float64, 0, len(lines[line][group]))
+
+			for _, points := range lines[line][group] {
+				if len(points) == 0 {
+					continue
+				}
+
+				if counters[line] {
+					values = append(values, points[len(points)-1].Y)
+				} else {
+					sum := 0.0
+					for _, point := range points {
+						sum += point.Y
+					}
+
+					values = append(values, sum/float64(len(points)))
+				}
+			}
+
+			sort.Float64s(values)
+
+			cdf := make(plotter.XYs, len(values))
+			for i, v := range values {
+				cdf[i] = plotter.XY{X: v, Y: float64(i+1) / float64(len(values))}
+			}
+
+			cdfs = append(cdfs, p.agg.label(line, group), cdf)
+		}
+	}
+
+	return cdfs
+}
+
+// bucketize gathers the values of the nodes for each second.
+func bucketize(nodes map[string]plotter.XYs) map[int64][]float64 {
+	buckets := make(map[int64][]float64)
+	for _, points := range nodes {
+		for _, point := range points {
+			key := int64(math.Floor(point.X))
+			buckets[key] = append(buckets[key], point.Y)
+		}
+	}
+
+	return buckets
+}
+
+// transparent returns the color with the opacity of the bands.
+func transparent(c color.Color) color.Color {
+	r, g, b, _ := c.RGBA()
+
+	return color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: bandAlpha}
+}
+
+// names returns the sorted names of the lines.
+func (g groupedLines) names() []string {
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// groupNames returns the sorted names of the groups of a line.
+func groupNames(groups map[string]map[string]plotter.XYs) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
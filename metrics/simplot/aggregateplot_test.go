@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestAggregation_Make(t *testing.T) {
+	agg, err := makeAggregation(AggregatePercentile, 90, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0.9, agg.band)
+
+	_, err = makeAggregation(AggregatePercentile, 0, nil)
+	require.EqualError(t, err, "invalid band 0.0")
+
+	_, err = makeAggregation(AggregateNone, 0, map[string]string{})
+	require.EqualError(t, err, "the grouping needs an aggregation")
+
+	_, err = makeAggregation("abc", 0, nil)
+	require.EqualError(t, err, "unknown aggregation 'abc'")
+}
+
+func TestAggregatePlot_MinMax(t *testing.T) {
+	agg, err := makeAggregation(AggregateMinMax, 0, nil)
+	require.NoError(t, err)
+
+	values := processAggregateTest(t, agg)
+	require.Len(t, values, 2)
+	require.Equal(t, "cpu", values[0])
+	require.Equal(t, plotter.XYs{{X: 1, Y: 30}, {X: 2, Y: 40}}, values[1])
+
+	_, band := agg.summarize(map[string]plotter.XYs{
+		"node0": {{X: 1, Y: 10}},
+		"node1": {{X: 1.5, Y: 30}},
+	})
+	require.Equal(t, plotter.XYs{{X: 1, Y: 10}, {X: 1, Y: 30}}, band)
+}
+
+func TestAggregatePlot_Percentile(t *testing.T) {
+	agg, err := makeAggregation(AggregatePercentile, 50, map[string]string{"node1": "area1", "node2": "area1"})
+	require.NoError(t, err)
+
+	values := processAggregateTest(t, agg)
+	require.Len(t, values, 4)
+	require.Equal(t, "area1-cpu", values[0])
+	require.Equal(t, plotter.XYs{{X: 1, Y: 40}, {X: 2, Y: 50}}, values[1])
+	require.Equal(t, "other-cpu", values[2])
+
+	_, band := agg.summarize(map[string]plotter.XYs{
+		"node0": {{X: 1, Y: 10}},
+		"node1": {{X: 1, Y: 20}},
+		"node2": {{X: 1, Y: 30}},
+		"node3": {{X: 1, Y: 40}},
+	})
+	require.Equal(t, plotter.XYs{{X: 1, Y: 10}, {X: 1, Y: 30}}, band)
+}
+
+func TestAggregatePlot_Stacked(t *testing.T) {
+	agg, err := makeAggregation(AggregateStacked, 0, map[string]string{"node0": "area0", "node1": "area1", "node2": "area1"})
+	require.NoError(t, err)
+
+	up := newUsagePlot(false, false, true, false).withAggregation(agg)
+
+	var values []interface{}
+	up.stacker = func(p *plot.Plot, xs plotter.Valuer, vv ...interface{}) error {
+		require.Equal(t, plotter.Values{1, 2}, xs)
+		values = vv
+		return nil
+	}
+
+	_, err = up.Process(makeAggregateStats())
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		"area1-cpu", plotter.Values{90, 120},
+		"area0-cpu", plotter.Values{10, 20},
+	}, values)
+
+	up.stacker = func(*plot.Plot, plotter.Valuer, ...interface{}) error {
+		return errors.New("oops")
+	}
+
+	_, err = up.Process(makeAggregateStats())
+	require.EqualError(t, err, "oops")
+}
+
+func TestAggregatePlot_CDF(t *testing.T) {
+	agg, err := makeAggregation(AggregateCDF, 0, nil)
+	require.NoError(t, err)
+
+	up := newUsagePlot(true, false, true, false).withAggregation(agg)
+
+	var values []interface{}
+	up.processor = func(p *plot.Plot, vv ...interface{}) error {
+		values = vv
+		return nil
+	}
+
+	_, err = up.Process(makeAggregateStats())
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		"cpu", plotter.XYs{{X: 15, Y: 1.0 / 3}, {X: 35, Y: 2.0 / 3}, {X: 55, Y: 1}},
+		// The counters are compared on their last value.
+		"tx", plotter.XYs{{X: 20, Y: 1.0 / 3}, {X: 40, Y: 2.0 / 3}, {X: 60, Y: 1}},
+	}, values)
+}
+
+func TestAggregatePlot_Metrics(t *testing.T) {
+	agg, err := makeAggregation(AggregateMinMax, 0, nil)
+	require.NoError(t, err)
+
+	stats := makeAggregateStats()
+	stats.Metrics = map[string]metrics.Metric{
+		"height": {
+			Samples: []metrics.Sample{{Timestamp: 2e9, Value: 5}},
+			Nodes: map[string][]metrics.Sample{
+				"node0": {{Timestamp: 1e9, Value: 1}},
+				"node1": {{Timestamp: 1.5e9, Value: 3}},
+			},
+		},
+	}
+
+	var values []interface{}
+	up := newUsagePlot(false, false, false, false).withMetrics([]string{"height"}).withAggregation(agg)
+	up.processor = func(p *plot.Plot, vv ...interface{}) error {
+		values = vv
+		return nil
+	}
+
+	_, err = up.Process(stats)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		"height", plotter.XYs{{X: 1, Y: 2}},
+		"height", plotter.XYs{{X: 2, Y: 5}},
+	}, values)
+}
+
+func TestAggregatePlot_Failures(t *testing.T) {
+	agg, err := makeAggregation(AggregateMinMax, 0, nil)
+	require.NoError(t, err)
+
+	up := newUsagePlot(false, false, true, false).withAggregation(agg)
+	up.processor = func(*plot.Plot, ...interface{}) error {
+		return errors.New("oops")
+	}
+
+	_, err = up.Process(makeAggregateStats())
+	require.EqualError(t, err, "oops")
+
+	up.agg.mode = AggregateCDF
+	_, err = up.Process(makeAggregateStats())
+	require.EqualError(t, err, "oops")
+
+	up.factory = func() (*plot.Plot, error) {
+		return nil, errors.New("factory error")
+	}
+
+	_, err = up.Process(makeAggregateStats())
+	require.EqualError(t, err, "factory error")
+}
+
+func processAggregateTest(t *testing.T, agg aggregation) []interface{} {
+	up := newUsagePlot(false, false, true, false).withAggregation(agg)
+
+	var values []interface{}
+	up.processor = func(p *plot.Plot, vv ...interface{}) error {
+		values = vv
+		return nil
+	}
+
+	p, err := up.Process(makeAggregateStats())
+	require.NoError(t, err)
+	require.NotNil(t, p)
+
+	return values
+}
+
+func makeAggregateStats() *metrics.Stats {
+	makeNode := func(base uint64) metrics.NodeStats {
+		return metrics.NodeStats{
+			Timestamps: []int64{1, 2},
+			CPU:        []uint64{base, base + 10},
+			TxBytes:    []uint64{base, base + 10},
+		}
+	}
+
+	return &metrics.Stats{
+		Nodes: map[string]metrics.NodeStats{
+			"node0": makeNode(10),
+			"node1": makeNode(30),
+			"node2": makeNode(50),
+		},
+	}
+}
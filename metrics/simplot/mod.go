@@ -4,12 +4,17 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
+	"go.dedis.ch/simnet/sim"
 	"golang.org/x/xerrors"
 	"gonum.org/v1/plot/vg"
 )
@@ -21,6 +26,19 @@ var (
 
 var usagePlotFactory = newUsagePlot
 
+// windowFlags are the flags of the summaries to restrict the values to a range
+// of time relative to the beginning of the statistics.
+var windowFlags = []cli.Flag{
+	&cli.DurationFlag{
+		Name:  "from",
+		Usage: "ignore the values before this time since the beginning",
+	},
+	&cli.DurationFlag{
+		Name:  "to",
+		Usage: "ignore the values after this time since the beginning",
+	},
+}
+
 const (
 	// DefaultImageWidth is the default width for the image of the plot
 	DefaultImageWidth = 12 * vg.Inch
@@ -29,11 +47,25 @@ const (
 	// DefaultInputFilePath is the default file path that will be read to find
 	// the statistics.
 	DefaultInputFilePath = "result.json"
+	// DefaultTopologyFilePath is the default file path that will be read to
+	// find the topology.
+	DefaultTopologyFilePath = sim.TopologyFileName
+	// DefaultPercentile is the default percentile of the values displayed.
+	DefaultPercentile = 95.0
+	// DefaultConfidence is the default level of the confidence intervals when
+	// comparing runs.
+	DefaultConfidence = 0.95
 
 	errNoInput        = "couldn't open the input file"
 	errInputMalformed = "couldn't read the statistics"
 	errMakePlot       = "couldn't create the plot"
 	errMakeImage      = "couldn't save the plot image"
+	errNoTopology     = "couldn't read the topology"
+	errWriteOutput    = "couldn't write the output file"
+	errBadPercentile  = "invalid percentile %.1f"
+	errUnknownMetric  = "unknown metric '%s'"
+	errUnknownPhase   = "unknown phase '%s'"
+	errCompare        = "couldn't compare the runs"
 )
 
 func main() {
@@ -43,6 +75,7 @@ func main() {
 	}
 
 	defaultInput := filepath.Join(homeDir, ".config", "simnet", DefaultInputFilePath)
+	defaultTopology := filepath.Join(homeDir, ".config", "simnet", DefaultTopologyFilePath)
 
 	app := &cli.App{
 		Name:  "Simplot",
@@ -62,62 +95,277 @@ func main() {
 						Name:  "output",
 						Value: "example.png",
 					},
+					&cli.StringSliceFlag{
+						Name:  "metric",
+						Usage: "custom metric drawn alongside the resources",
+					},
+					&cli.StringFlag{
+						Name:  "aggregate",
+						Usage: "combine the nodes with minmax, percentile, stacked or cdf",
+					},
+					&cli.Float64Flag{
+						Name:  "band",
+						Usage: "percentage of the values inside the band of the percentile aggregation",
+						Value: DefaultBand,
+					},
+					&cli.StringFlag{
+						Name:  "group",
+						Usage: "aggregate the nodes per area of the topology with 'area'",
+					},
+					&cli.PathFlag{
+						Name:  "topology",
+						Usage: "topology used to group the nodes",
+						Value: defaultTopology,
+					},
+					&cli.StringFlag{
+						Name:  "phase",
+						Usage: "crop the graph to the phase delimited by the tags",
+					},
 				},
 				Subcommands: []*cli.Command{
 					{
 						Name:  "cpu",
 						Usage: "generate a graph of the CPU utilization",
 						Action: func(c *cli.Context) error {
-							input := c.Path("input")
-							output := c.Path("output")
-							return generateGraph(input, output, false, false, true, false)
+							return graph(c, false, false, true, false)
 						},
 					},
 					{
 						Name:  "mem",
 						Usage: "generate a graph of the memory utilization",
 						Action: func(c *cli.Context) error {
-							input := c.Path("input")
-							output := c.Path("output")
-							return generateGraph(input, output, false, false, false, true)
+							return graph(c, false, false, false, true)
 						},
 					},
 					{
 						Name:  "tx",
 						Usage: "generate a graph of the transmission bandwidth",
 						Action: func(c *cli.Context) error {
-							input := c.Path("input")
-							output := c.Path("output")
-							return generateGraph(input, output, true, false, false, false)
+							return graph(c, true, false, false, false)
 						},
 					},
 					{
 						Name:  "rx",
 						Usage: "generate a graph of the reception bandwidth",
 						Action: func(c *cli.Context) error {
-							input := c.Path("input")
+							return graph(c, false, true, false, false)
+						},
+					},
+					{
+						Name:  "metric",
+						Usage: "generate a graph of the custom metrics only",
+						Action: func(c *cli.Context) error {
+							return graph(c, false, false, false, false)
+						},
+					},
+				},
+			},
+			{
+				Name:  "topology",
+				Usage: "visualize the topology of the simulation",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:  "topology",
+						Value: defaultTopology,
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "dot",
+						Usage: "write the Graphviz representation of the topology",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:  "output",
+								Value: "topology.dot",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							topology := c.Path("topology")
 							output := c.Path("output")
-							return generateGraph(input, output, false, true, false, false)
+							return writeTopologyDOT(topology, output)
+						},
+					},
+					{
+						Name:  "heatmap",
+						Usage: "generate a heat map of the latencies between the nodes",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:  "output",
+								Value: "topology.png",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							topology := c.Path("topology")
+							output := c.Path("output")
+							return generateHeatMap(topology, output)
 						},
 					},
 				},
 			},
+			{
+				Name:  "export",
+				Usage: "export the statistics to other formats",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "csv",
+						Usage: "write one row per node and timestamp",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:  "output",
+								Value: "result.csv",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return exportStats(c.Path("input"), c.Path("output"), metrics.WriteCSV)
+						},
+					},
+					{
+						Name:  "openmetrics",
+						Usage: "write the OpenMetrics text format with timestamps",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:  "output",
+								Value: "result.om",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return exportStats(c.Path("input"), c.Path("output"), metrics.WriteOpenMetrics)
+						},
+					},
+					{
+						Name:  "json",
+						Usage: "write a JSON object with an array per column",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:  "output",
+								Value: "result-columns.json",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return exportStats(c.Path("input"), c.Path("output"), metrics.WriteColumnarJSON)
+						},
+					},
+				},
+			},
+			{
+				Name:      "compare",
+				Usage:     "compare the runs to the first one which is the baseline",
+				ArgsUsage: "BASELINE RUN [RUN...]",
+				Description: "The consecutive samples of a node are correlated, so the Welch's t-test\n" +
+					"   compares the means of batches of about the square root of the number of\n" +
+					"   samples for each node, and the mean of each node over the run for every\n" +
+					"   node together. The batches and the nodes are assumed independent, and a\n" +
+					"   comparison needs at least two of them in each run.",
+				Flags: []cli.Flag{
+					&cli.Float64Flag{
+						Name:  "confidence",
+						Usage: "level of the confidence intervals",
+						Value: DefaultConfidence,
+					},
+					&cli.BoolFlag{
+						Name:  "aggregated",
+						Usage: "only compare the values of every node together",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return compareRuns(c.Args().Slice(), c.Float64("confidence"), c.Bool("aggregated"))
+				},
+			},
+			{
+				Name:  "report",
+				Usage: "write a self-contained HTML report of the run",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:  "output",
+						Usage: "path of the report, next to the statistics by default",
+					},
+					&cli.PathFlag{
+						Name:  "topology",
+						Value: defaultTopology,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return generateReport(c.Path("input"), c.Path("topology"), c.Path("output"))
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "convert the statistics of an older version to the current one",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "cpu-unit",
+						Usage:    "unit of the CPU in the input, either % (Kubernetes) or 0.01% (Docker)",
+						Required: true,
+					},
+					&cli.PathFlag{
+						Name:  "output",
+						Value: "result-migrated.json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return migrateStats(c.Path("input"), c.Path("output"), c.String("cpu-unit"))
+				},
+			},
 			{
 				Name:  "max",
 				Usage: "retrieve the maximum values of each node",
+				Flags: windowFlags,
 				Action: func(c *cli.Context) error {
 					input := c.Path("input")
 
-					return printMax(input)
+					return printMax(input, c.Duration("from"), c.Duration("to"))
 				},
 			},
 			{
 				Name:  "average",
 				Usage: "retrieve the average values of each node",
+				Flags: windowFlags,
+				Action: func(c *cli.Context) error {
+					input := c.Path("input")
+
+					return printAverage(input, c.Duration("from"), c.Duration("to"))
+				},
+			},
+			{
+				Name:  "percentile",
+				Usage: "retrieve a percentile of the values of each node",
+				Flags: append([]cli.Flag{
+					&cli.Float64Flag{
+						Name:  "value",
+						Usage: "percentile between 0 and 100",
+						Value: DefaultPercentile,
+					},
+				}, windowFlags...),
+				Action: func(c *cli.Context) error {
+					input := c.Path("input")
+
+					return printPercentile(input, c.Float64("value"), c.Duration("from"), c.Duration("to"))
+				},
+			},
+			{
+				Name:  "histogram",
+				Usage: "retrieve the distribution of a custom histogram",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "name of the histogram",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					input := c.Path("input")
+
+					return printHistogram(input, c.String("name"))
+				},
+			},
+			{
+				Name:  "total",
+				Usage: "retrieve the total traffic of each node",
+				Flags: windowFlags,
 				Action: func(c *cli.Context) error {
 					input := c.Path("input")
 
-					return printAverage(input)
+					return printTotal(input, c.Duration("from"), c.Duration("to"))
 				},
 			},
 		},
@@ -129,14 +377,51 @@ func main() {
 	}
 }
 
-func generateGraph(input, output string, withTx, withRx, withCPU, withMem bool) error {
+// graph generates the graph of the resources with the flags of the command.
+func graph(c *cli.Context, withTx, withRx, withCPU, withMem bool) error {
+	var groups map[string]string
+
+	switch c.String("group") {
+	case "":
+	case "area":
+		var err error
+		groups, err = readAreas(c.Path("topology"))
+		if err != nil {
+			return err
+		}
+	default:
+		return xerrors.Errorf("unknown group '%s'", c.String("group"))
+	}
+
+	agg, err := makeAggregation(c.String("aggregate"), c.Float64("band"), groups)
+	if err != nil {
+		return err
+	}
+
+	return generateGraph(c.Path("input"), c.Path("output"), c.StringSlice("metric"), agg, c.String("phase"),
+		withTx, withRx, withCPU, withMem)
+}
+
+func generateGraph(input, output string, names []string, agg aggregation, phase string, withTx, withRx, withCPU, withMem bool) error {
 	stats, err := readStats(input)
 	if err != nil {
 		return err
 	}
 
+	for _, name := range names {
+		if _, ok := stats.Metrics[name]; !ok {
+			return xerrors.Errorf(errUnknownMetric, name)
+		}
+	}
+
+	if phase != "" {
+		if _, ok := stats.Phase(phase); !ok {
+			return xerrors.Errorf(errUnknownPhase, phase)
+		}
+	}
+
 	// Create the plot with the requested data.
-	up := usagePlotFactory(withTx, withRx, withCPU, withMem)
+	up := usagePlotFactory(withTx, withRx, withCPU, withMem).withMetrics(names).withAggregation(agg).withPhase(phase)
 	plot, err := up.Process(stats)
 	if err != nil {
 		return xerrors.New(errMakePlot)
@@ -155,47 +440,340 @@ func generateGraph(input, output string, withTx, withRx, withCPU, withMem bool)
 	return nil
 }
 
-func printMax(input string) error {
+func writeTopologyDOT(input, output string) error {
+	topo, err := sim.ReadTopology(input)
+	if err != nil {
+		return xerrors.New(errNoTopology)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return xerrors.New(errWriteOutput)
+	}
+
+	defer f.Close()
+
+	err = network.WriteDOT(f, topo)
+	if err != nil {
+		return xerrors.New(errWriteOutput)
+	}
+
+	return nil
+}
+
+func generateHeatMap(input, output string) error {
+	topo, err := sim.ReadTopology(input)
+	if err != nil {
+		return xerrors.New(errNoTopology)
+	}
+
+	plot, err := makeLatencyHeatMap(topo)
+	if err != nil {
+		return xerrors.New(errMakePlot)
+	}
+
+	err = plot.Save(DefaultImageWidth, DefaultImageHeight, output)
+	if err != nil {
+		os.Remove(output)
+
+		return xerrors.New(errMakeImage)
+	}
+
+	return nil
+}
+
+func exportStats(input, output string, export func(io.Writer, metrics.Stats) error) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return xerrors.New(errWriteOutput)
+	}
+
+	defer f.Close()
+
+	err = export(f, *stats)
+	if err != nil {
+		return xerrors.Errorf("%s: %v", errWriteOutput, err)
+	}
+
+	return nil
+}
+
+// migrateStats writes the statistics of the input converted to the current
+// version, where the unit of the CPU of the input must be given as it is not
+// recorded by older versions.
+func migrateStats(input, output, cpuUnit string) error {
 	stats, err := readStats(input)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Maximum of [CPU Memory Rx Tx]:")
+	err = stats.Upgrade(cpuUnit)
+	if err != nil {
+		return xerrors.Errorf("couldn't upgrade the statistics: %v", err)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return xerrors.New(errWriteOutput)
+	}
+
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(stats)
+	if err != nil {
+		return xerrors.Errorf("%s: %v", errWriteOutput, err)
+	}
+
+	return nil
+}
+
+// window is a range of time in seconds.
+type window struct {
+	start int64
+	end   int64
+}
+
+// makeWindow returns the window between the two durations since the beginning
+// of the statistics. A zero duration leaves the window open on this side.
+func makeWindow(stats *metrics.Stats, from, to time.Duration) window {
+	w := window{start: math.MinInt64, end: math.MaxInt64}
+
+	if from > 0 {
+		w.start = stats.Timestamp + int64(from.Seconds())
+	}
+
+	if to > 0 {
+		w.end = stats.Timestamp + int64(to.Seconds())
+	}
+
+	return w
+}
+
+func (w window) apply(s metrics.Series) metrics.Series {
+	return s.Window(w.start, w.end)
+}
+
+func printMax(input string, from, to time.Duration) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	w := makeWindow(stats, from, to)
+
+	fmt.Println("Maximum of [CPU Memory Rx/s Tx/s]:")
 
 	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
-		cpu, mem, tx, rx := ns.Max()
+		cpu := w.apply(ns.CPUUsage()).Max()
+		mem := w.apply(ns.MemoryUsage()).Max()
+		rx := w.apply(ns.RxRate()).Max()
+		tx := w.apply(ns.TxRate()).Max()
 
-		fmt.Printf("Node <%s>\t: %8.2f%%\t%s\t%s\t%s\n", node, float64(cpu)/100.0,
-			int2human(float64(mem)), int2human(float64(rx)), int2human(float64(tx)))
+		fmt.Printf("Node <%s>\t: %8.2f%%\t%s\t%s/s\t%s/s\n", node, cpu/metrics.CPUScale,
+			int2human(mem), int2human(rx), int2human(tx))
 	})
 
 	return nil
 }
 
-func printAverage(input string) error {
+func printAverage(input string, from, to time.Duration) error {
 	stats, err := readStats(input)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Average of [CPU Memory Rx Tx] (Standard deviation):")
+	w := makeWindow(stats, from, to)
+
+	fmt.Println("Average of [CPU Memory Rx/s Tx/s] (Standard deviation):")
 
 	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
-		cpu, mem, tx, rx := ns.Average()
-		stddevcpu, stddevmem, stddevtx, stddevrx := ns.StdDev()
+		cpu := w.apply(ns.CPUUsage())
+		mem := w.apply(ns.MemoryUsage())
+		rx := w.apply(ns.RxRate())
+		tx := w.apply(ns.TxRate())
 
-		fmt.Printf("Node <%s>\t: %8.2f%% (%.2f%%)\t%s (%s)\t%s (%s)\t%s (%s)\n",
+		fmt.Printf("Node <%s>\t: %8.2f%% (%.2f%%)\t%s (%s)\t%s/s (%s/s)\t%s/s (%s/s)\n",
 			node,
-			cpu/100, stddevcpu/100,
-			int2human(mem), int2human(stddevmem),
-			int2human(rx), int2human(stddevrx),
-			int2human(tx), int2human(stddevtx))
+			cpu.Average()/metrics.CPUScale, cpu.StdDev()/metrics.CPUScale,
+			int2human(mem.Average()), int2human(mem.StdDev()),
+			int2human(rx.Average()), int2human(rx.StdDev()),
+			int2human(tx.Average()), int2human(tx.StdDev()))
 	})
 
 	return nil
 }
 
+func printPercentile(input string, p float64, from, to time.Duration) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	if p < 0 || p > 100 {
+		return xerrors.Errorf(errBadPercentile, p)
+	}
+
+	w := makeWindow(stats, from, to)
+	q := p / 100
+
+	fmt.Printf("Percentile %.1f of [CPU Memory Rx/s Tx/s]:\n", p)
+
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		cpu := w.apply(ns.CPUUsage()).Percentile(q)
+		mem := w.apply(ns.MemoryUsage()).Percentile(q)
+		rx := w.apply(ns.RxRate()).Percentile(q)
+		tx := w.apply(ns.TxRate()).Percentile(q)
+
+		fmt.Printf("Node <%s>\t: %8.2f%%\t%s\t%s/s\t%s/s\n", node, cpu/metrics.CPUScale,
+			int2human(mem), int2human(rx), int2human(tx))
+	})
+
+	return nil
+}
+
+func printTotal(input string, from, to time.Duration) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	w := makeWindow(stats, from, to)
+
+	fmt.Println("Total of [Rx Tx]:")
+
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		rx := w.apply(metrics.NewSeries(ns.Timestamps, ns.RxBytes)).Increase()
+		tx := w.apply(metrics.NewSeries(ns.Timestamps, ns.TxBytes)).Increase()
+
+		fmt.Printf("Node <%s>\t: %s\t%s\n", node, int2human(rx), int2human(tx))
+	})
+
+	return nil
+}
+
+func printHistogram(input, name string) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	hm, ok := stats.Histograms[name]
+	if !ok {
+		return xerrors.Errorf(errUnknownMetric, name)
+	}
+
+	fmt.Printf("Distribution of <%s> [Count Average Min P50 P95 P99 Max]:\n", name)
+
+	printLine := func(label string, h metrics.Histogram) {
+		fmt.Printf("%s\t: %d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", label, h.Count,
+			h.Average(), h.Min, h.Quantile(0.5), h.Quantile(0.95), h.Quantile(0.99), h.Max)
+	}
+
+	if hm.Histogram.Count > 0 {
+		printLine("Global", hm.Histogram)
+	}
+
+	nodes := make([]string, 0, len(hm.Nodes))
+	for node := range hm.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		printLine(fmt.Sprintf("Node <%s>", node), hm.Nodes[node])
+	}
+
+	return nil
+}
+
+func compareRuns(inputs []string, confidence float64, aggregated bool) error {
+	runs := make([]metrics.Stats, len(inputs))
+	for i, input := range inputs {
+		stats, err := readStats(input)
+		if err != nil {
+			return xerrors.Errorf("%s: %v", input, err)
+		}
+
+		runs[i] = *stats
+	}
+
+	diffs, err := metrics.Compare(runs, confidence)
+	if err != nil {
+		return xerrors.Errorf("%s: %v", errCompare, err)
+	}
+
+	run := 0
+	for _, d := range diffs {
+		if aggregated && d.Node != "" {
+			continue
+		}
+
+		if d.Run != run {
+			run = d.Run
+			fmt.Printf("Comparison of <%s> with <%s> [Baseline Run Difference (CI %.0f%%) p-value]:\n",
+				inputs[run], inputs[0], confidence*100)
+		}
+
+		node := "All"
+		if d.Node != "" {
+			node = fmt.Sprintf("Node <%s>", d.Node)
+		}
+
+		mark := ""
+		if d.Significant(confidence) {
+			mark = " *"
+		}
+
+		relative := "n/a"
+		if !math.IsNaN(d.Relative()) {
+			relative = fmt.Sprintf("%+.1f%%", d.Relative()*100)
+		}
+
+		pvalue := "n/a"
+		if !math.IsNaN(d.PValue) {
+			pvalue = fmt.Sprintf("%.3f", d.PValue)
+		}
+
+		fmt.Printf("%s\t%s\t: %s\t%s\t%s (%s, %s) %s\t%s%s\n", d.Metric, node,
+			formatCompared(d.Metric, d.Base.Mean), formatCompared(d.Metric, d.Other.Mean),
+			formatCompared(d.Metric, d.Delta), formatCompared(d.Metric, d.Lower),
+			formatCompared(d.Metric, d.Upper), relative, pvalue, mark)
+	}
+
+	return nil
+}
+
+// formatCompared returns the value of a compared metric in a human-readable
+// format.
+func formatCompared(metric string, value float64) string {
+	if math.IsNaN(value) {
+		return "n/a"
+	}
+
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	switch metric {
+	case metrics.CompareCPU:
+		return fmt.Sprintf("%s%.2f%%", sign, value/metrics.CPUScale)
+	case metrics.CompareMemory:
+		return sign + int2human(value)
+	case metrics.CompareRxRate, metrics.CompareTxRate:
+		return sign + int2human(value) + "/s"
+	default:
+		return fmt.Sprintf("%s%.3f", sign, value)
+	}
+}
+
 func forEachOrdered(stats *metrics.Stats, fn func(string, metrics.NodeStats)) {
 	keys := make(sort.StringSlice, 0, len(stats.Nodes))
 	for node := range stats.Nodes {
@@ -236,5 +814,10 @@ func readStats(input string) (*metrics.Stats, error) {
 		return nil, xerrors.New(errInputMalformed)
 	}
 
+	if stats.Version < metrics.StatsVersion {
+		fmt.Fprintln(os.Stderr, "Warning: the statistics are written by an older version "+
+			"and the unit of the CPU might differ, see the migrate command.")
+	}
+
 	return stats, nil
 }
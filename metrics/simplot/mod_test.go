@@ -3,14 +3,20 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
+	"go.dedis.ch/simnet/sim"
 	"gonum.org/v1/plot"
 )
 
@@ -37,8 +43,281 @@ func TestPlotter_Main(t *testing.T) {
 	os.Args = []string{os.Args[0], "-input", input.Name(), "max"}
 	main()
 
-	os.Args = []string{os.Args[0], "-input", input.Name(), "average"}
+	os.Args = []string{os.Args[0], "-input", input.Name(), "average", "-from", "1s", "-to", "1m"}
 	main()
+
+	os.Args = []string{os.Args[0], "-input", input.Name(), "percentile", "-value", "99"}
+	main()
+
+	os.Args = []string{os.Args[0], "-input", input.Name(), "total"}
+	main()
+}
+
+func TestPlotter_MainMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorder()
+	recorder.Observe("latency", "", 10)
+	recorder.Observe("latency", "node0", 20)
+
+	stats := makeStats(5)
+	recorder.Fill(stats)
+
+	// The samples are close to the timestamps of the resources.
+	stats.Metrics["height"] = metrics.Metric{
+		Nodes: map[string][]metrics.Sample{
+			"node0": {{Timestamp: 1e9, Value: 1}, {Timestamp: 2e9, Value: 2}},
+		},
+	}
+
+	input := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	output := filepath.Join(dir, "example.png")
+
+	os.Args = []string{os.Args[0], "-input", input, "graph", "-output", output, "-metric", "height", "cpu"}
+	main()
+	require.FileExists(t, output)
+
+	os.Args = []string{os.Args[0], "-input", input, "graph", "-output", output, "-metric", "height", "metric"}
+	main()
+
+	os.Args = []string{os.Args[0], "-input", input, "histogram", "-name", "latency"}
+	main()
+
+	err = generateGraph(input, output, []string{"abc"}, aggregation{}, "", false, false, false, false)
+	require.EqualError(t, err, "unknown metric 'abc'")
+
+	err = printHistogram(input, "abc")
+	require.EqualError(t, err, "unknown metric 'abc'")
+
+	err = printHistogram("invalid_name", "latency")
+	require.EqualError(t, err, errNoInput)
+}
+
+func TestPlotter_MainPhase(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stats := makeStats(5)
+	stats.Timestamp = 0
+	for node, ns := range stats.Nodes {
+		ns.Timestamps = []int64{0, 1, 2, 3, 4}
+		stats.Nodes[node] = ns
+	}
+
+	stats.Tags = map[int64]string{
+		1e9: metrics.PhaseStart("run"),
+		3e9: metrics.PhaseEnd("run"),
+	}
+
+	input := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	output := filepath.Join(dir, "example.png")
+
+	os.Args = []string{os.Args[0], "-input", input, "graph", "-output", output, "-phase", "run", "cpu"}
+	main()
+	require.FileExists(t, output)
+
+	agg, err := makeAggregation(AggregateMinMax, 0, nil)
+	require.NoError(t, err)
+
+	err = generateGraph(input, output, nil, agg, "run", false, false, true, false)
+	require.NoError(t, err)
+
+	err = generateGraph(input, output, nil, aggregation{}, "abc", false, false, true, false)
+	require.EqualError(t, err, "unknown phase 'abc'")
+}
+
+func TestPlotter_MainAggregate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(makeStats(5))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	err = sim.WriteTopology(dir, network.NewAreaTopology(&network.Area{N: 1}, &network.Area{N: 2}))
+	require.NoError(t, err)
+
+	topology := filepath.Join(dir, sim.TopologyFileName)
+	output := filepath.Join(dir, "example.png")
+
+	for _, mode := range []string{"minmax", "percentile", "stacked", "cdf"} {
+		os.Args = []string{os.Args[0], "-input", input, "graph", "-output", output,
+			"-aggregate", mode, "-group", "area", "-topology", topology, "cpu"}
+		main()
+		require.FileExists(t, output)
+		require.NoError(t, os.Remove(output))
+	}
+
+	app := &cli.App{
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "aggregate"},
+			&cli.Float64Flag{Name: "band"},
+			&cli.StringFlag{Name: "group"},
+			&cli.PathFlag{Name: "topology"},
+		},
+	}
+
+	run := func(args ...string) error {
+		app.Action = func(c *cli.Context) error {
+			return graph(c, false, false, true, false)
+		}
+
+		return app.Run(append([]string{"simplot"}, args...))
+	}
+
+	require.EqualError(t, run("-group", "abc"), "unknown group 'abc'")
+	require.EqualError(t, run("-group", "area", "-topology", "invalid_name"), errNoTopology)
+	require.EqualError(t, run("-aggregate", "abc"), "unknown aggregation 'abc'")
+
+	err = sim.WriteTopology(dir, network.NewSimpleTopology(3, 0))
+	require.NoError(t, err)
+	require.EqualError(t, run("-group", "area", "-topology", topology), "the topology has no area")
+}
+
+func TestPlotter_MainExport(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(makeStats(2))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	for format, name := range map[string]string{"csv": "out.csv", "openmetrics": "out.om", "json": "out.json"} {
+		output := filepath.Join(dir, name)
+
+		os.Args = []string{os.Args[0], "-input", input, "export", format, "-output", output}
+		main()
+
+		content, err := ioutil.ReadFile(output)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "node2")
+	}
+
+	err = exportStats("invalid_name", "", metrics.WriteCSV)
+	require.EqualError(t, err, errNoInput)
+
+	err = exportStats(input, filepath.Join(dir, "unknown", "out.csv"), metrics.WriteCSV)
+	require.EqualError(t, err, errWriteOutput)
+
+	err = exportStats(input, filepath.Join(dir, "out.csv"), func(io.Writer, metrics.Stats) error {
+		return errors.New("oops")
+	})
+	require.EqualError(t, err, errWriteOutput+": oops")
+}
+
+func TestPlotter_MainMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(makeStats(2))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	output := filepath.Join(dir, "output.json")
+	os.Args = []string{os.Args[0], "-input", input, "migrate", "-cpu-unit", "%", "-output", output}
+	main()
+
+	stats, err := readStats(output)
+	require.NoError(t, err)
+	require.Equal(t, metrics.StatsVersion, stats.Version)
+	require.Equal(t, metrics.UnitCentiPercent, stats.Units[metrics.ColumnCPU])
+	require.Equal(t, makeStats(2).Nodes["node0"].CPU[1]*metrics.CPUScale, stats.Nodes["node0"].CPU[1])
+
+	err = migrateStats("invalid_name", "", metrics.UnitPercent)
+	require.EqualError(t, err, errNoInput)
+
+	err = migrateStats(input, output, "abc")
+	require.EqualError(t, err, "couldn't upgrade the statistics: unknown unit 'abc'")
+
+	err = migrateStats(input, filepath.Join(dir, "unknown", "out.json"), metrics.UnitPercent)
+	require.EqualError(t, err, errWriteOutput)
+}
+
+func TestPlotter_MainCompare(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inputs := make([]string, 3)
+	for i := range inputs {
+		inputs[i] = filepath.Join(dir, fmt.Sprintf("run%d.json", i))
+
+		data, err := json.Marshal(makeStats(5 + i))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(inputs[i], data, 0644))
+	}
+
+	os.Args = append([]string{os.Args[0], "compare", "-confidence", "0.9"}, inputs...)
+	main()
+
+	require.NoError(t, compareRuns(inputs, 0.95, true))
+
+	err = compareRuns(inputs[:1], 0.95, false)
+	require.EqualError(t, err, errCompare+": need at least two runs to compare")
+
+	err = compareRuns([]string{inputs[0], "invalid_name"}, 0.95, false)
+	require.EqualError(t, err, "invalid_name: "+errNoInput)
+
+	require.Equal(t, "-1.50%", formatCompared(metrics.CompareCPU, -150))
+	require.Equal(t, "  2.00 KiB/s", formatCompared(metrics.CompareRxRate, 2048))
+	require.Equal(t, "  2.00 KiB", formatCompared(metrics.CompareMemory, 2048))
+	require.Equal(t, "0.500", formatCompared("height", 0.5))
+	require.Equal(t, "n/a", formatCompared("height", math.NaN()))
+}
+
+func TestPlotter_Summaries(t *testing.T) {
+	err := printMax("invalid_name", 0, 0)
+	require.EqualError(t, err, errNoInput)
+
+	err = printAverage("invalid_name", 0, 0)
+	require.EqualError(t, err, errNoInput)
+
+	err = printPercentile("invalid_name", 50, 0, 0)
+	require.EqualError(t, err, errNoInput)
+
+	err = printTotal("invalid_name", 0, 0)
+	require.EqualError(t, err, errNoInput)
+
+	input, err := ioutil.TempFile(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.Remove(input.Name())
+
+	require.NoError(t, json.NewEncoder(input).Encode(makeStats(5)))
+	input.Close()
+
+	err = printPercentile(input.Name(), 101, 0, 0)
+	require.EqualError(t, err, "invalid percentile 101.0")
+}
+
+func TestPlotter_MakeWindow(t *testing.T) {
+	stats := &metrics.Stats{Timestamp: 100}
+
+	w := makeWindow(stats, 0, 0)
+	require.Equal(t, int64(math.MinInt64), w.start)
+	require.Equal(t, int64(math.MaxInt64), w.end)
+
+	w = makeWindow(stats, 2*time.Second, time.Minute)
+	require.Equal(t, window{start: 102, end: 160}, w)
+
+	s := metrics.NewSeries([]int64{101, 102, 160, 161}, []uint64{1, 2, 3, 4})
+	require.Equal(t, []float64{2, 3}, w.apply(s).Values)
 }
 
 func TestPlotter_MainMissingInput(t *testing.T) {
@@ -127,6 +406,55 @@ func TestPlotter_MainBadOutput(t *testing.T) {
 	main()
 }
 
+func TestPlotter_MainTopology(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = sim.WriteTopology(dir, network.NewSimpleTopology(3, 20*time.Millisecond))
+	require.NoError(t, err)
+
+	topology := filepath.Join(dir, sim.TopologyFileName)
+	dot := filepath.Join(dir, "topology.dot")
+	heatmap := filepath.Join(dir, "topology.png")
+
+	os.Args = []string{os.Args[0], "topology", "-topology", topology, "dot", "-output", dot}
+	main()
+
+	content, err := ioutil.ReadFile(dot)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "\"node1\" -> \"node0\"")
+
+	os.Args = []string{os.Args[0], "topology", "-topology", topology, "heatmap", "-output", heatmap}
+	main()
+
+	require.FileExists(t, heatmap)
+}
+
+func TestPlotter_TopologyFailures(t *testing.T) {
+	err := writeTopologyDOT("invalid_name", "")
+	require.EqualError(t, err, errNoTopology)
+
+	err = generateHeatMap("invalid_name", "")
+	require.EqualError(t, err, errNoTopology)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = sim.WriteTopology(dir, network.NewSimpleTopology(3, 0))
+	require.NoError(t, err)
+
+	topology := filepath.Join(dir, sim.TopologyFileName)
+
+	err = writeTopologyDOT(topology, filepath.Join(dir, "unknown", "topology.dot"))
+	require.EqualError(t, err, errWriteOutput)
+
+	// Trigger a unsupported format.
+	err = generateHeatMap(topology, filepath.Join(dir, "noname"))
+	require.EqualError(t, err, errMakeImage)
+}
+
 func testFactory() (*plot.Plot, error) {
 	return nil, errors.New("factory error")
 }
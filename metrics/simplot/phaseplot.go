@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"go.dedis.ch/simnet/metrics"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// phaseRegion is the range of a phase in seconds since the beginning of the
+// statistics.
+type phaseRegion struct {
+	name  string
+	start float64
+	end   float64
+	index int
+}
+
+// Thumbnail implements plot.Thumbnailer. It fills the entry of the legend with
+// the color of the region.
+func (r phaseRegion) Thumbnail(c *draw.Canvas) {
+	c.FillPolygon(r.color(), []vg.Point{
+		{X: c.Min.X, Y: c.Min.Y},
+		{X: c.Min.X, Y: c.Max.Y},
+		{X: c.Max.X, Y: c.Max.Y},
+		{X: c.Max.X, Y: c.Min.Y},
+	})
+}
+
+func (r phaseRegion) color() color.Color {
+	return transparent(plotutil.SoftColors[r.index%len(plotutil.SoftColors)])
+}
+
+// phaseRegions is a plotter that shades the phases over the whole height of
+// the plot.
+type phaseRegions []phaseRegion
+
+// makePhaseRegions returns the regions of the phases that overlap the range of
+// the timeline.
+func makePhaseRegions(phases []metrics.Phase, tl timeline) phaseRegions {
+	regions := make(phaseRegions, 0, len(phases))
+	for i, phase := range phases {
+		start := math.Max(tl.seconds(phase.Start), tl.min)
+		end := math.Min(tl.seconds(phase.End), tl.max)
+
+		if start < end {
+			regions = append(regions, phaseRegion{
+				name:  phase.Name,
+				start: start,
+				end:   end,
+				index: i,
+			})
+		}
+	}
+
+	return regions
+}
+
+// Plot implements plot.Plotter. The regions are drawn with the range of the
+// axes known at that moment so that they do not change it.
+func (rr phaseRegions) Plot(c draw.Canvas, p *plot.Plot) {
+	trX, trY := p.Transforms(&c)
+
+	for _, r := range rr {
+		start := math.Max(r.start, p.X.Min)
+		end := math.Min(r.end, p.X.Max)
+		if start >= end {
+			continue
+		}
+
+		c.FillPolygon(r.color(), []vg.Point{
+			{X: trX(start), Y: trY(p.Y.Min)},
+			{X: trX(start), Y: trY(p.Y.Max)},
+			{X: trX(end), Y: trY(p.Y.Max)},
+			{X: trX(end), Y: trY(p.Y.Min)},
+		})
+	}
+}
@@ -0,0 +1,669 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
+	"go.dedis.ch/simnet/sim"
+	"golang.org/x/xerrors"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	// DefaultReportFileName is the name of the report written next to the
+	// statistics when no output is given.
+	DefaultReportFileName = "report.html"
+	// ReportLineLimit is the number of nodes above which the charts of the
+	// report draw the mean and the range of the nodes instead of one line per
+	// node.
+	ReportLineLimit = 10
+	// ReportEdgeLimit is the number of links above which the links of the
+	// topology are not listed.
+	ReportEdgeLimit = 200
+
+	reportChartWidth  = 10 * vg.Inch
+	reportChartHeight = 4 * vg.Inch
+)
+
+type reportEntry struct {
+	Key   string
+	Value string
+}
+
+type reportTag struct {
+	Offset string
+	Name   string
+	ts     int64
+}
+
+type reportNode struct {
+	Name    string
+	CPUAvg  string
+	CPUMax  string
+	MemAvg  string
+	MemMax  string
+	RxRate  string
+	TxRate  string
+	RxTotal string
+	TxTotal string
+	Samples int
+}
+
+// reportPlot is a plot of the report before it is rendered.
+type reportPlot struct {
+	title string
+	up    usagePlot
+}
+
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+// reportTable is a table of the report of which the columns depend on the
+// metrics found in the statistics.
+type reportTable struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+	// Truncated is true when the table has too many rows to be listed.
+	Truncated bool
+}
+
+// reportColumn is a column of a table of the nodes that summarizes the values
+// of a metric.
+type reportColumn struct {
+	header  string
+	values  func(metrics.NodeStats) []uint64
+	summary func(metrics.Series) string
+}
+
+var reportNetworkColumns = []reportColumn{
+	{"Rx packets", func(ns metrics.NodeStats) []uint64 { return ns.RxPackets }, formatIncrease},
+	{"Tx packets", func(ns metrics.NodeStats) []uint64 { return ns.TxPackets }, formatIncrease},
+	{"Rx errors", func(ns metrics.NodeStats) []uint64 { return ns.RxErrors }, formatIncrease},
+	{"Tx errors", func(ns metrics.NodeStats) []uint64 { return ns.TxErrors }, formatIncrease},
+	{"Rx drops", func(ns metrics.NodeStats) []uint64 { return ns.RxDropped }, formatIncrease},
+	{"Tx drops", func(ns metrics.NodeStats) []uint64 { return ns.TxDropped }, formatIncrease},
+	{"Retransmissions", func(ns metrics.NodeStats) []uint64 { return ns.Retransmissions }, formatIncrease},
+}
+
+var reportResourceColumns = []reportColumn{
+	{"Block read", func(ns metrics.NodeStats) []uint64 { return ns.BlockRead }, formatBytesIncrease},
+	{"Block write", func(ns metrics.NodeStats) []uint64 { return ns.BlockWrite }, formatBytesIncrease},
+	{"PIDs avg", func(ns metrics.NodeStats) []uint64 { return ns.PIDs }, formatAverage},
+	{"PIDs max", func(ns metrics.NodeStats) []uint64 { return ns.PIDs }, formatMax},
+	{"Cache avg", func(ns metrics.NodeStats) []uint64 { return ns.MemoryCache }, formatBytesAverage},
+	{"Cache max", func(ns metrics.NodeStats) []uint64 { return ns.MemoryCache }, formatBytesMax},
+	{"RSS avg", func(ns metrics.NodeStats) []uint64 { return ns.MemoryRSS }, formatBytesAverage},
+	{"RSS max", func(ns metrics.NodeStats) []uint64 { return ns.MemoryRSS }, formatBytesMax},
+}
+
+type reportHistogram struct {
+	Name    string
+	Node    string
+	Count   uint64
+	Average string
+	P50     string
+	P95     string
+	P99     string
+	Max     string
+}
+
+type reportTopology struct {
+	Nodes   int
+	HeatMap template.HTML
+	Edges   []network.Edge
+	// Truncated is true when the topology has too many links to be listed.
+	Truncated bool
+}
+
+// report is the content of the HTML report of a run.
+type report struct {
+	Start      string
+	Duration   string
+	Version    int
+	Units      []reportEntry
+	Parameters []reportEntry
+	Tags       []reportTag
+	Nodes      []reportNode
+	Tables     []reportTable
+	Charts     []reportChart
+	Histograms []reportHistogram
+	Topology   *reportTopology
+}
+
+// generateReport writes a self-contained HTML file with the charts and the
+// summaries of the statistics, and the topology when it can be read. The
+// report is written next to the statistics when the output is empty.
+func generateReport(input, topology, output string) error {
+	stats, err := readStats(input)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = filepath.Join(filepath.Dir(input), DefaultReportFileName)
+	}
+
+	r, err := makeReport(stats)
+	if err != nil {
+		return xerrors.Errorf("%s: %v", errMakePlot, err)
+	}
+
+	topo, err := sim.ReadTopology(topology)
+	if err == nil {
+		// Custom topologies might not have a representation so the report
+		// is written without.
+		r.Topology, err = makeReportTopology(topo)
+		if err != nil {
+			return xerrors.Errorf("%s: %v", errMakePlot, err)
+		}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return xerrors.New(errWriteOutput)
+	}
+
+	defer f.Close()
+
+	err = reportTemplate.Execute(f, r)
+	if err != nil {
+		return xerrors.Errorf("%s: %v", errWriteOutput, err)
+	}
+
+	return nil
+}
+
+func makeReport(stats *metrics.Stats) (report, error) {
+	r := report{
+		Start:   time.Unix(stats.Timestamp, 0).UTC().Format(time.RFC3339),
+		Version: stats.Version,
+	}
+
+	end := stats.Timestamp
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		if len(ns.Timestamps) > 0 && ns.Timestamps[len(ns.Timestamps)-1] > end {
+			end = ns.Timestamps[len(ns.Timestamps)-1]
+		}
+
+		r.Nodes = append(r.Nodes, makeReportNode(node, ns))
+	})
+
+	r.Duration = (time.Duration(end-stats.Timestamp) * time.Second).String()
+
+	tables := []reportTable{
+		makeColumnTable("Network", stats, reportNetworkColumns),
+		makeColumnTable("Resources", stats, reportResourceColumns),
+		makeApplicationTable(stats),
+		makePeerTable(stats),
+	}
+
+	for _, table := range tables {
+		if len(table.Rows) > 0 || table.Truncated {
+			r.Tables = append(r.Tables, table)
+		}
+	}
+
+	r.Units = sortedEntries(stats.Units)
+	r.Parameters = sortedEntries(stats.Parameters)
+
+	for ts, name := range stats.Tags {
+		offset := time.Duration(ts - stats.Timestamp*int64(time.Second))
+		r.Tags = append(r.Tags, reportTag{
+			Offset: offset.Round(time.Millisecond).String(),
+			Name:   name,
+			ts:     ts,
+		})
+	}
+
+	sort.Slice(r.Tags, func(i, j int) bool {
+		return r.Tags[i].ts < r.Tags[j].ts
+	})
+
+	var agg aggregation
+	if len(stats.Nodes) > ReportLineLimit {
+		agg.mode = AggregateMinMax
+	}
+
+	plots := []reportPlot{
+		{title: cpuPlotTitle(stats), up: newUsagePlot(false, false, true, false)},
+		{title: "Memory (bytes)", up: newUsagePlot(false, false, false, true)},
+		{title: "Received (bytes)", up: newUsagePlot(false, true, false, false)},
+		{title: "Transmitted (bytes)", up: newUsagePlot(true, false, false, false)},
+	}
+
+	for _, name := range sortedMetricNames(stats) {
+		plots = append(plots, reportPlot{
+			title: name,
+			up:    newUsagePlot(false, false, false, false).withMetrics([]string{name}),
+		})
+	}
+
+	for _, p := range plots {
+		if len(stats.Nodes) == 0 && len(p.up.metrics) == 0 {
+			continue
+		}
+
+		plot, err := p.up.withAggregation(agg).Process(stats)
+		if err != nil {
+			return r, err
+		}
+
+		svg, err := renderSVG(plot)
+		if err != nil {
+			return r, err
+		}
+
+		r.Charts = append(r.Charts, reportChart{Title: p.title, SVG: svg})
+	}
+
+	r.Histograms = makeReportHistograms(stats)
+
+	return r, nil
+}
+
+func makeReportNode(name string, ns metrics.NodeStats) reportNode {
+	cpu := ns.CPUUsage()
+	mem := ns.MemoryUsage()
+
+	return reportNode{
+		Name:    name,
+		CPUAvg:  fmt.Sprintf("%.2f%%", cpu.Average()/metrics.CPUScale),
+		CPUMax:  fmt.Sprintf("%.2f%%", cpu.Max()/metrics.CPUScale),
+		MemAvg:  int2human(mem.Average()),
+		MemMax:  int2human(mem.Max()),
+		RxRate:  int2human(ns.RxRate().Average()) + "/s",
+		TxRate:  int2human(ns.TxRate().Average()) + "/s",
+		RxTotal: int2human(metrics.NewSeries(ns.Timestamps, ns.RxBytes).Increase()),
+		TxTotal: int2human(metrics.NewSeries(ns.Timestamps, ns.TxBytes).Increase()),
+		Samples: len(ns.Timestamps),
+	}
+}
+
+// makeColumnTable returns the table with a row per node and the columns of
+// the metrics that at least one node has. The cell of a node without the
+// metric is not available.
+func makeColumnTable(title string, stats *metrics.Stats, columns []reportColumn) reportTable {
+	table := reportTable{Title: title, Headers: []string{"Node"}}
+
+	present := make([]reportColumn, 0, len(columns))
+	for _, col := range columns {
+		for _, ns := range stats.Nodes {
+			if len(col.values(ns)) > 0 {
+				present = append(present, col)
+				table.Headers = append(table.Headers, col.header)
+				break
+			}
+		}
+	}
+
+	if len(present) == 0 {
+		return table
+	}
+
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		row := []string{node}
+		for _, col := range present {
+			values := col.values(ns)
+			if len(values) == 0 {
+				row = append(row, "n/a")
+			} else {
+				row = append(row, col.summary(metrics.NewSeries(ns.Timestamps, values)))
+			}
+		}
+
+		table.Rows = append(table.Rows, row)
+	})
+
+	return table
+}
+
+// makeApplicationTable returns the table of the series scraped from the
+// application with a row per node and series.
+func makeApplicationTable(stats *metrics.Stats) reportTable {
+	table := reportTable{
+		Title:   "Application",
+		Headers: []string{"Node", "Series", "Samples", "Average", "Max", "Last"},
+	}
+
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		keys := make([]string, 0, len(ns.Application))
+		for key := range ns.Application {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			series := ns.Application[key]
+			if series.Len() == 0 {
+				continue
+			}
+
+			table.Rows = append(table.Rows, []string{
+				node,
+				key,
+				fmt.Sprintf("%d", series.Len()),
+				fmt.Sprintf("%.3f", series.Average()),
+				fmt.Sprintf("%.3f", series.Max()),
+				fmt.Sprintf("%.3f", series.Values[series.Len()-1]),
+			})
+		}
+	})
+
+	return table
+}
+
+// makePeerTable returns the table of the traffic exchanged by each node with
+// the other ones over the run. The rows are not listed when there are too
+// many of them.
+func makePeerTable(stats *metrics.Stats) reportTable {
+	table := reportTable{
+		Title:   "Traffic between the nodes",
+		Headers: []string{"Node", "Peer", "Received", "Transmitted"},
+	}
+
+	forEachOrdered(stats, func(node string, ns metrics.NodeStats) {
+		peers := make([]string, 0, len(ns.Peers))
+		for peer := range ns.Peers {
+			peers = append(peers, peer)
+		}
+
+		sort.Strings(peers)
+
+		for _, peer := range peers {
+			ps := ns.Peers[peer]
+
+			table.Rows = append(table.Rows, []string{
+				node,
+				peer,
+				formatBytesIncrease(metrics.NewSeries(ns.Timestamps, ps.RxBytes)),
+				formatBytesIncrease(metrics.NewSeries(ns.Timestamps, ps.TxBytes)),
+			})
+		}
+	})
+
+	if len(table.Rows) > ReportEdgeLimit {
+		table.Rows = nil
+		table.Truncated = true
+	}
+
+	return table
+}
+
+func formatIncrease(s metrics.Series) string {
+	return fmt.Sprintf("%.0f", s.Increase())
+}
+
+func formatBytesIncrease(s metrics.Series) string {
+	return int2human(s.Increase())
+}
+
+func formatAverage(s metrics.Series) string {
+	return fmt.Sprintf("%.1f", s.Average())
+}
+
+func formatMax(s metrics.Series) string {
+	return fmt.Sprintf("%.0f", s.Max())
+}
+
+func formatBytesAverage(s metrics.Series) string {
+	return int2human(s.Average())
+}
+
+func formatBytesMax(s metrics.Series) string {
+	return int2human(s.Max())
+}
+
+func makeReportHistograms(stats *metrics.Stats) []reportHistogram {
+	names := make([]string, 0, len(stats.Histograms))
+	for name := range stats.Histograms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	rows := []reportHistogram{}
+	add := func(name, node string, h metrics.Histogram) {
+		rows = append(rows, reportHistogram{
+			Name:    name,
+			Node:    node,
+			Count:   h.Count,
+			Average: fmt.Sprintf("%.3f", h.Average()),
+			P50:     fmt.Sprintf("%.3f", h.Quantile(0.5)),
+			P95:     fmt.Sprintf("%.3f", h.Quantile(0.95)),
+			P99:     fmt.Sprintf("%.3f", h.Quantile(0.99)),
+			Max:     fmt.Sprintf("%.3f", h.Max),
+		})
+	}
+
+	for _, name := range names {
+		hm := stats.Histograms[name]
+
+		if hm.Histogram.Count > 0 {
+			add(name, "Global", hm.Histogram)
+		}
+
+		nodes := make([]string, 0, len(hm.Nodes))
+		for node := range hm.Nodes {
+			nodes = append(nodes, node)
+		}
+
+		sort.Strings(nodes)
+
+		for _, node := range nodes {
+			add(name, node, hm.Nodes[node])
+		}
+	}
+
+	return rows
+}
+
+func makeReportTopology(topo network.Topology) (*reportTopology, error) {
+	plot, err := makeLatencyHeatMap(topo)
+	if err != nil {
+		return nil, err
+	}
+
+	svg, err := renderSVG(plot)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &reportTopology{
+		Nodes:   topo.Len(),
+		HeatMap: svg,
+	}
+
+	edges := network.Edges(topo)
+	if len(edges) > ReportEdgeLimit {
+		rt.Truncated = true
+	} else {
+		rt.Edges = edges
+	}
+
+	return rt, nil
+}
+
+// renderSVG returns the SVG element of the plot that can be embedded in the
+// HTML document.
+func renderSVG(p *plot.Plot) (template.HTML, error) {
+	wt, err := p.WriterTo(reportChartWidth, reportChartHeight, "svg")
+	if err != nil {
+		return "", err
+	}
+
+	buffer := new(bytes.Buffer)
+	_, err = wt.WriteTo(buffer)
+	if err != nil {
+		return "", err
+	}
+
+	// The XML declaration is not allowed inside the document.
+	svg := buffer.String()
+	svg = svg[strings.Index(svg, "<svg"):]
+
+	return template.HTML(svg), nil
+}
+
+func sortedEntries(m map[string]string) []reportEntry {
+	entries := make([]reportEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, reportEntry{Key: key, Value: value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries
+}
+
+// cpuPlotTitle returns the title of the CPU plot with the unit of the values
+// stored in the statistics, which is the current one when it is not recorded.
+func cpuPlotTitle(stats *metrics.Stats) string {
+	unit := stats.Units[metrics.ColumnCPU]
+	if unit == "" {
+		unit = metrics.DefaultUnits()[metrics.ColumnCPU]
+	}
+
+	return fmt.Sprintf("CPU (%s)", unit)
+}
+
+func sortedMetricNames(stats *metrics.Stats) []string {
+	names := make([]string, 0, len(stats.Metrics))
+	for name := range stats.Metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SimNet report - {{.Start}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f0f0f0; cursor: pointer; }
+svg { max-width: 100%; height: auto; }
+details { margin: 1em 0; }
+summary { font-size: 1.2em; font-weight: bold; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>SimNet report</h1>
+<p>Run started at {{.Start}} and lasted {{.Duration}} (statistics version {{.Version}}).</p>
+
+<details open>
+<summary>Parameters</summary>
+{{if .Parameters}}<table>
+{{range .Parameters}}<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>{{else}}<p>No parameter recorded.</p>{{end}}
+</details>
+
+<details open>
+<summary>Nodes</summary>
+<input id="filter" type="search" placeholder="Filter the nodes" oninput="filterNodes(this.value)">
+<table id="nodes">
+<thead><tr><th>Node</th><th>Samples</th><th>CPU avg</th><th>CPU max</th><th>Memory avg</th><th>Memory max</th>
+<th>Rx avg</th><th>Tx avg</th><th>Rx total</th><th>Tx total</th></tr></thead>
+<tbody>
+{{range .Nodes}}<tr><td>{{.Name}}</td><td>{{.Samples}}</td><td>{{.CPUAvg}}</td><td>{{.CPUMax}}</td><td>{{.MemAvg}}</td><td>{{.MemMax}}</td>
+<td>{{.RxRate}}</td><td>{{.TxRate}}</td><td>{{.RxTotal}}</td><td>{{.TxTotal}}</td></tr>
+{{end}}</tbody>
+</table>
+</details>
+
+{{range .Tables}}<details open>
+<summary>{{.Title}}</summary>
+{{if .Truncated}}<p>There are too many rows to be listed.</p>{{else}}<table>
+<thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+</details>
+{{end}}
+
+<details open>
+<summary>Tags</summary>
+{{if .Tags}}<table>
+<thead><tr><th>Since the start</th><th>Tag</th></tr></thead>
+{{range .Tags}}<tr><td>{{.Offset}}</td><td>{{.Name}}</td></tr>
+{{end}}</table>{{else}}<p>No tag recorded.</p>{{end}}
+</details>
+
+{{range .Charts}}<details open>
+<summary>{{.Title}}</summary>
+{{.SVG}}
+</details>
+{{end}}
+
+{{if .Histograms}}<details open>
+<summary>Histograms</summary>
+<table>
+<thead><tr><th>Name</th><th>Node</th><th>Count</th><th>Average</th><th>P50</th><th>P95</th><th>P99</th><th>Max</th></tr></thead>
+{{range .Histograms}}<tr><td>{{.Name}}</td><td>{{.Node}}</td><td>{{.Count}}</td><td>{{.Average}}</td><td>{{.P50}}</td><td>{{.P95}}</td><td>{{.P99}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+</details>{{end}}
+
+<details open>
+<summary>Topology</summary>
+{{with .Topology}}<p>{{.Nodes}} nodes. The heat map shows the latency in milliseconds from the sources (rows) to the destinations (columns).</p>
+{{.HeatMap}}
+{{if .Truncated}}<p>The topology has too many links to be listed.</p>{{else}}<table>
+<thead><tr><th>From</th><th>To</th><th>Delay</th><th>Loss</th><th>Upload</th><th>Download</th></tr></thead>
+{{range .Edges}}<tr><td>{{.From}}</td><td>{{.To}}</td><td>{{.Rule.Delay}}</td><td>{{.Rule.Loss}}</td><td>{{.Rule.Upload}}</td><td>{{.Rule.Download}}</td></tr>
+{{end}}</table>{{end}}
+{{else}}<p>The topology is not available.</p>{{end}}
+</details>
+
+{{if .Units}}<details>
+<summary>Units</summary>
+<table>
+{{range .Units}}<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+</details>{{end}}
+
+<script>
+function filterNodes(value) {
+	var rows = document.querySelectorAll("#nodes tbody tr");
+	for (var i = 0; i < rows.length; i++) {
+		rows[i].style.display = rows[i].cells[0].textContent.indexOf(value) >= 0 ? "" : "none";
+	}
+}
+
+// Sort the rows of the nodes when clicking on a header.
+document.querySelectorAll("#nodes th").forEach(function (th, index) {
+	th.addEventListener("click", function () {
+		var body = document.querySelector("#nodes tbody");
+		var rows = Array.prototype.slice.call(body.rows);
+		var asc = th.dataset.order !== "asc";
+		th.dataset.order = asc ? "asc" : "desc";
+		rows.sort(function (a, b) {
+			var x = a.cells[index].textContent, y = b.cells[index].textContent;
+			var cmp = x.localeCompare(y, undefined, {numeric: true});
+			return asc ? cmp : -cmp;
+		});
+		rows.forEach(function (row) { body.appendChild(row); });
+	});
+});
+</script>
+</body>
+</html>
+`))
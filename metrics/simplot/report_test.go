@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
+	"go.dedis.ch/simnet/sim"
+)
+
+func TestReport_Generate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorder()
+	recorder.Observe("latency", "", 10)
+	recorder.Observe("latency", "node0", 20)
+	recorder.Record("height", "node1", 3)
+
+	stats := makeStats(5)
+	stats.Timestamp = 0
+	stats.Parameters = map[string]string{"image": "nginx", "strategy": "docker"}
+	stats.Units = metrics.DefaultUnits()
+	stats.Tags = map[int64]string{2e9: "<round>"}
+	recorder.Fill(stats)
+
+	node0 := stats.Nodes["node0"]
+	node0.Retransmissions = []uint64{0, 1, 2, 3, 4}
+	node0.Peers = map[string]metrics.PeerStats{
+		"node1": {RxBytes: []uint64{0, 0, 0, 0, 2048}, TxBytes: []uint64{0, 0, 0, 0, 1024}},
+	}
+	node0.Application = map[string]metrics.Series{
+		"sql_count": {Timestamps: []int64{1, 2}, Values: []float64{1, 3}},
+	}
+	stats.Nodes["node0"] = node0
+
+	input := filepath.Join(dir, "result.json")
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	require.NoError(t, sim.WriteTopology(dir, network.NewSimpleTopology(3, 20*time.Millisecond)))
+
+	os.Args = []string{os.Args[0], "-input", input, "report", "-topology", filepath.Join(dir, sim.TopologyFileName)}
+	main()
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, DefaultReportFileName))
+	require.NoError(t, err)
+
+	html := string(content)
+	require.Contains(t, html, "<th>image</th><td>nginx</td>")
+	require.Contains(t, html, "<td>2s</td><td>&lt;round&gt;</td>")
+	require.Contains(t, html, "<td>node2</td><td>5</td>")
+	require.Contains(t, html, "<summary>CPU (0.01%)</summary>\n<svg")
+	require.Contains(t, html, "<summary>height</summary>")
+	require.Contains(t, html, "<td>latency</td><td>Global</td><td>1</td>")
+	require.Contains(t, html, "<td>node1</td><td>node0</td><td>delay 20ms</td>")
+	require.Contains(t, html, "<th>cpu</th><td>0.01%</td>")
+	require.Contains(t, html, "<summary>Network</summary>")
+	require.Contains(t, html, "<th>Retransmissions</th>")
+	require.Contains(t, html, "<tr><td>node0</td><td>4</td></tr>")
+	require.Contains(t, html, "<tr><td>node1</td><td>n/a</td></tr>")
+	require.Contains(t, html, "<td>node0</td><td>sql_count</td><td>2</td><td>2.000</td><td>3.000</td><td>3.000</td>")
+	require.Contains(t, html, "<td>node0</td><td>node1</td><td>  2.00 KiB</td><td>1024.00 B</td>")
+	require.NotContains(t, html, "<summary>Resources</summary>")
+	require.NotContains(t, html, "<?xml")
+
+	// Without a topology and with many nodes.
+	for i := 3; i <= ReportLineLimit; i++ {
+		stats.Nodes[fmt.Sprintf("node%d", i)] = makeNodeStats(5)
+	}
+
+	data, err = json.Marshal(stats)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	output := filepath.Join(dir, "other.html")
+	require.NoError(t, generateReport(input, "invalid_name", output))
+
+	content, err = ioutil.ReadFile(output)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "The topology is not available.")
+}
+
+func TestReport_Topology(t *testing.T) {
+	rt, err := makeReportTopology(network.NewSimpleTopology(3, 0))
+	require.NoError(t, err)
+	require.Equal(t, 3, rt.Nodes)
+	require.Len(t, rt.Edges, 2)
+	require.False(t, rt.Truncated)
+
+	rt, err = makeReportTopology(network.NewSimpleTopology(ReportEdgeLimit+2, 0))
+	require.NoError(t, err)
+	require.Empty(t, rt.Edges)
+	require.True(t, rt.Truncated)
+}
+
+func TestReport_Tables(t *testing.T) {
+	stats := makeStats(3)
+	stats.Nodes["node1"] = metrics.NodeStats{
+		Timestamps:  []int64{1, 2, 3},
+		RxPackets:   []uint64{10, 20, 30},
+		BlockRead:   []uint64{0, 1024, 4096},
+		PIDs:        []uint64{2, 4, 3},
+		MemoryCache: []uint64{2048, 2048, 2048},
+	}
+
+	table := makeColumnTable("Resources", stats, reportResourceColumns)
+	require.Equal(t, []string{"Node", "Block read", "PIDs avg", "PIDs max", "Cache avg", "Cache max"}, table.Headers)
+	require.Len(t, table.Rows, 3)
+	require.Equal(t, []string{"node0", "n/a", "n/a", "n/a", "n/a", "n/a"}, table.Rows[0])
+	require.Equal(t, []string{"node1", "  4.00 KiB", "3.0", "4", "  2.00 KiB", "  2.00 KiB"}, table.Rows[1])
+
+	table = makeColumnTable("Network", stats, reportNetworkColumns)
+	require.Equal(t, []string{"Node", "Rx packets"}, table.Headers)
+	require.Equal(t, []string{"node1", "20"}, table.Rows[1])
+
+	table = makeColumnTable("Empty", &metrics.Stats{}, reportNetworkColumns)
+	require.Empty(t, table.Rows)
+
+	peers := make(map[string]metrics.PeerStats)
+	for i := 0; i <= ReportEdgeLimit; i++ {
+		peers[fmt.Sprintf("peer%d", i)] = metrics.PeerStats{}
+	}
+
+	stats.Nodes["node1"] = metrics.NodeStats{Peers: peers}
+
+	table = makePeerTable(stats)
+	require.Empty(t, table.Rows)
+	require.True(t, table.Truncated)
+}
+
+func TestReport_CPUPlotTitle(t *testing.T) {
+	stats := &metrics.Stats{}
+	require.Equal(t, "CPU (0.01%)", cpuPlotTitle(stats))
+
+	stats.Units = map[string]string{metrics.ColumnCPU: metrics.UnitPercent}
+	require.Equal(t, "CPU (%)", cpuPlotTitle(stats))
+}
+
+func TestReport_GenerateFailures(t *testing.T) {
+	err := generateReport("invalid_name", "", "")
+	require.EqualError(t, err, errNoInput)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "result.json")
+	data, err := json.Marshal(makeStats(2))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(input, data, 0644))
+
+	err = generateReport(input, "", filepath.Join(dir, "unknown", "report.html"))
+	require.EqualError(t, err, errWriteOutput)
+}
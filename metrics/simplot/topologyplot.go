@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"go.dedis.ch/simnet/network"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+)
+
+// HeatMapColors is the number of colors used to draw the latency heat map.
+const HeatMapColors = 12
+
+// latencyGrid is the matrix of the latencies in milliseconds between the nodes
+// of a topology. Columns are the destinations and rows the sources.
+type latencyGrid struct {
+	nodes  []network.NodeID
+	values [][]float64
+}
+
+func newLatencyGrid(topo network.Topology) latencyGrid {
+	nodes := make([]network.NodeID, 0, topo.Len())
+	for _, node := range topo.GetNodes() {
+		nodes = append(nodes, node.Name)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i] < nodes[j]
+	})
+
+	indices := make(map[network.NodeID]int)
+	for i, node := range nodes {
+		indices[node] = i
+	}
+
+	values := make([][]float64, len(nodes))
+	for i := range values {
+		values[i] = make([]float64, len(nodes))
+	}
+
+	for _, edge := range network.Edges(topo) {
+		src, ok := indices[edge.From]
+		dst, ok2 := indices[edge.To]
+		// Only the latency of the traffic as a whole is displayed.
+		if ok && ok2 && !edge.Rule.IsSpecific() {
+			values[dst][src] = float64(edge.Rule.Delay.Value.Microseconds()) / 1000
+		}
+	}
+
+	return latencyGrid{
+		nodes:  nodes,
+		values: values,
+	}
+}
+
+// Dims implements plotter.GridXYZ. It returns the number of nodes as both the
+// number of columns and rows.
+func (g latencyGrid) Dims() (int, int) {
+	return len(g.nodes), len(g.nodes)
+}
+
+// Z implements plotter.GridXYZ. It returns the latency from the node of the
+// row to the node of the column.
+func (g latencyGrid) Z(c, r int) float64 {
+	return g.values[c][r]
+}
+
+// X implements plotter.GridXYZ.
+func (g latencyGrid) X(c int) float64 {
+	return float64(c)
+}
+
+// Y implements plotter.GridXYZ.
+func (g latencyGrid) Y(r int) float64 {
+	return float64(r)
+}
+
+func (g latencyGrid) ticks() plot.ConstantTicks {
+	ticks := make(plot.ConstantTicks, len(g.nodes))
+	for i, node := range g.nodes {
+		ticks[i] = plot.Tick{Value: float64(i), Label: string(node)}
+	}
+
+	return ticks
+}
+
+// makeLatencyHeatMap creates a plot of the latencies between every pair of
+// nodes of the topology.
+func makeLatencyHeatMap(topo network.Topology) (*plot.Plot, error) {
+	grid := newLatencyGrid(topo)
+
+	p, err := plot.New()
+	if err != nil {
+		return nil, err
+	}
+
+	p.X.Label.Text = "Destination"
+	p.Y.Label.Text = "Source"
+	p.X.Tick.Marker = grid.ticks()
+	p.Y.Tick.Marker = grid.ticks()
+
+	heat := plotter.NewHeatMap(grid, palette.Heat(HeatMapColors, 1))
+	if heat.Max <= heat.Min {
+		// The palette cannot be scaled over an empty range.
+		heat.Max = heat.Min + 1
+	}
+
+	// The title gives the range of latencies that the palette covers, from
+	// the darkest to the lightest color.
+	p.Title.Text = fmt.Sprintf("Latency from %.2fms to %.2fms", heat.Min, heat.Max)
+
+	p.Add(heat)
+
+	return p, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
+)
+
+func TestLatencyGrid_New(t *testing.T) {
+	topo := network.NewFullTopology(
+		network.FullInput{From: "A", To: "B", Latency: 1500 * time.Microsecond},
+		network.FullInput{From: "C", To: "A", Latency: 3 * time.Millisecond},
+	)
+
+	grid := newLatencyGrid(topo)
+
+	c, r := grid.Dims()
+	require.Equal(t, 3, c)
+	require.Equal(t, 3, r)
+	require.Equal(t, 1.5, grid.Z(1, 0))
+	require.Equal(t, 3.0, grid.Z(0, 2))
+	require.Equal(t, 0.0, grid.Z(0, 1))
+	require.Equal(t, 2.0, grid.X(2))
+	require.Equal(t, 1.0, grid.Y(1))
+
+	ticks := grid.ticks()
+	require.Len(t, ticks, 3)
+	require.Equal(t, "C", ticks[2].Label)
+}
+
+func TestLatencyGrid_HeatMap(t *testing.T) {
+	p, err := makeLatencyHeatMap(network.NewSimpleTopology(3, 20*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, "Latency from 0.00ms to 20.00ms", p.Title.Text)
+
+	// Same latency everywhere should not prevent the plot.
+	p, err = makeLatencyHeatMap(network.NewSimpleTopology(1, 0))
+	require.NoError(t, err)
+	require.Equal(t, "Latency from 0.00ms to 1.00ms", p.Title.Text)
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"sort"
+	"time"
 
 	"go.dedis.ch/simnet/metrics"
+	"golang.org/x/xerrors"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
@@ -17,9 +20,15 @@ type mapper func(index int, ns metrics.NodeStats) float64
 // usagePlot is a plot factory that can create plot from usage statistics.
 type usagePlot struct {
 	mappers map[string]mapper
+	metrics []string
+	agg     aggregation
+	// phase is the name of the phase the plot is cropped to, or empty to
+	// draw the whole run.
+	phase string
 
 	factory   func() (*plot.Plot, error)
 	processor func(*plot.Plot, ...interface{}) error
+	stacker   func(*plot.Plot, plotter.Valuer, ...interface{}) error
 }
 
 func newUsagePlot(tx, rx, cpu, mem bool) usagePlot {
@@ -49,12 +58,63 @@ func newUsagePlot(tx, rx, cpu, mem bool) usagePlot {
 		mappers:   mappers,
 		factory:   plot.New,
 		processor: plotutil.AddLinePoints,
+		stacker:   plotutil.AddStackedAreaPlots,
 	}
 }
 
+// withMetrics returns a copy of the plot that also draws the lines of the
+// custom metrics.
+func (p usagePlot) withMetrics(names []string) usagePlot {
+	p.metrics = names
+	return p
+}
+
+// withAggregation returns a copy of the plot that combines the lines of the
+// nodes.
+func (p usagePlot) withAggregation(agg aggregation) usagePlot {
+	p.agg = agg
+	return p
+}
+
+// withPhase returns a copy of the plot that only draws the values inside the
+// phase.
+func (p usagePlot) withPhase(name string) usagePlot {
+	p.phase = name
+	return p
+}
+
+// timelineOf returns the timeline of the statistics cropped to the phase of
+// the plot if any.
+func (p usagePlot) timelineOf(stats *metrics.Stats) (timeline, error) {
+	tl := newTimeline(stats)
+
+	if p.phase == "" {
+		return tl, nil
+	}
+
+	phase, ok := stats.Phase(p.phase)
+	if !ok {
+		return tl, xerrors.Errorf(errUnknownPhase, p.phase)
+	}
+
+	tl.min = tl.seconds(phase.Start)
+	tl.max = tl.seconds(phase.End)
+
+	return tl, nil
+}
+
 // Process takes the statistics and produce the plot that will contain only the
 // lines defined by the list of mappers.
 func (p usagePlot) Process(stats *metrics.Stats) (*plot.Plot, error) {
+	tl, err := p.timelineOf(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.agg.mode != AggregateNone {
+		return p.processAggregate(stats, tl)
+	}
+
 	keys := make([]string, 0, len(stats.Nodes))
 	for key := range stats.Nodes {
 		keys = append(keys, key)
@@ -67,19 +127,36 @@ func (p usagePlot) Process(stats *metrics.Stats) (*plot.Plot, error) {
 		ns := stats.Nodes[node]
 
 		for prefix, m := range p.mappers {
-			points := makePoints(ns, m)
+			points := makePoints(ns, m, tl)
 			lines = append(lines, node+prefix, points)
 		}
 	}
 
+	for _, name := range p.metrics {
+		m := stats.Metrics[name]
+
+		if len(m.Samples) > 0 {
+			lines = append(lines, name, makeSamplePoints(m.Samples, tl))
+		}
+
+		nodes := make([]string, 0, len(m.Nodes))
+		for node := range m.Nodes {
+			nodes = append(nodes, node)
+		}
+
+		sort.Strings(nodes)
+
+		for _, node := range nodes {
+			lines = append(lines, node+"-"+name, makeSamplePoints(m.Nodes[node], tl))
+		}
+	}
+
 	plot, err := p.factory()
 	if err != nil {
 		return nil, err
 	}
 
-	plot.X.Tick.Marker = tagTicks{
-		tags: processTags(stats.Tags),
-	}
+	tl.decorate(plot, stats)
 
 	err = p.processor(plot, lines...)
 	if err != nil {
@@ -89,10 +166,76 @@ func (p usagePlot) Process(stats *metrics.Stats) (*plot.Plot, error) {
 	return plot, nil
 }
 
-func processTags(raw map[int64]string) map[int]string {
+// timeline converts the timestamps into seconds since the beginning of the
+// statistics, and drops the points outside of its range.
+type timeline struct {
+	// origin is the beginning of the statistics in nanoseconds.
+	origin int64
+	min    float64
+	max    float64
+}
+
+func newTimeline(stats *metrics.Stats) timeline {
+	return timeline{
+		origin: stats.Timestamp * int64(time.Second),
+		min:    math.Inf(-1),
+		max:    math.Inf(1),
+	}
+}
+
+// seconds returns the number of seconds between the beginning of the
+// statistics and the timestamp in nanoseconds.
+func (tl timeline) seconds(ts int64) float64 {
+	return float64(ts-tl.origin) / float64(time.Second)
+}
+
+// crop returns the points inside the range of the timeline.
+func (tl timeline) crop(points plotter.XYs) plotter.XYs {
+	cropped := make(plotter.XYs, 0, len(points))
+	for _, point := range points {
+		if point.X >= tl.min && point.X <= tl.max {
+			cropped = append(cropped, point)
+		}
+	}
+
+	return cropped
+}
+
+// decorate labels the ticks of the axis with the tags and shades the phases
+// of the statistics.
+func (tl timeline) decorate(p *plot.Plot, stats *metrics.Stats) {
+	p.X.Label.Text = "time since the beginning (s)"
+	p.X.Tick.Marker = tagTicks{
+		tags: processTags(stats.Tags, tl),
+	}
+
+	regions := makePhaseRegions(stats.Phases(), tl)
+	if len(regions) > 0 {
+		// The regions are added first so that they are drawn behind the
+		// lines.
+		p.Add(regions)
+
+		for _, region := range regions {
+			p.Legend.Add(region.name, region)
+		}
+	}
+}
+
+// processTags returns the tags indexed by the second since the beginning of
+// the timeline. The tags of the same second are joined.
+func processTags(raw map[int64]string, tl timeline) map[int]string {
+	keys := make([]int64, 0, len(raw))
+	for ts := range raw {
+		keys = append(keys, ts)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
 	tags := make(map[int]string)
-	for ts, tag := range raw {
-		key := int(ts / (1000 * 1000 * 1000)) // ns to sec
+	for _, ts := range keys {
+		tag := raw[ts]
+
+		key := int(math.Floor(tl.seconds(ts)))
 		if _, ok := tags[key]; ok {
 			tags[key] += ", " + tag
 		} else {
@@ -103,14 +246,26 @@ func processTags(raw map[int64]string) map[int]string {
 }
 
 // makePoints is a helper function to create an array of points using a mapper.
-func makePoints(ns metrics.NodeStats, m mapper) plotter.XYs {
+func makePoints(ns metrics.NodeStats, m mapper, tl timeline) plotter.XYs {
 	points := make(plotter.XYs, len(ns.Timestamps))
-	for i, ts := range ns.Timestamps {
-		points[i].X = float64(ts)
+	for i, ts := range ns.Seconds() {
+		points[i].X = ts - float64(tl.origin)/float64(time.Second)
 		points[i].Y = m(i, ns)
 	}
 
-	return points
+	return tl.crop(points)
+}
+
+// makeSamplePoints creates the points of the samples of a custom metric with
+// the timestamps in seconds.
+func makeSamplePoints(samples []metrics.Sample, tl timeline) plotter.XYs {
+	points := make(plotter.XYs, len(samples))
+	for i, sample := range samples {
+		points[i].X = tl.seconds(sample.Timestamp)
+		points[i].Y = sample.Value
+	}
+
+	return tl.crop(points)
 }
 
 type tagTicks struct {
@@ -118,19 +273,20 @@ type tagTicks struct {
 }
 
 func (t tagTicks) Ticks(min, max float64) []plot.Tick {
-	tks := make([]plot.Tick, int(max-min+1))
+	first := int(math.Floor(min))
+	tks := make([]plot.Tick, int(max)-first+1)
 
 	for i := range tks {
-		value := float64(int(min) + i)
+		value := first + i
 
 		tks[i] = plot.Tick{
-			Value: value,
-			Label: fmt.Sprintf("+%ds", i),
+			Value: float64(value),
+			Label: fmt.Sprintf("%+ds", value),
 		}
 	}
 
 	for key, tag := range t.tags {
-		index := key - int(min)
+		index := key - first
 		if index >= 0 && index < len(tks) {
 			tks[index].Label = tag
 		}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -27,7 +30,10 @@ func TestUsagePlot_Process(t *testing.T) {
 		return nil
 	}
 
-	p, err = up.Process(makeStats(n))
+	stats := makeStats(n)
+	stats.Timestamp = 0
+
+	p, err = up.Process(stats)
 	require.NoError(t, err)
 	require.NotNil(t, p)
 	require.Equal(t, 24, len(values))
@@ -39,6 +45,123 @@ func TestUsagePlot_Process(t *testing.T) {
 	require.Contains(t, ticks[0].Label, "B")
 }
 
+func TestUsagePlot_ProcessMetrics(t *testing.T) {
+	stats := makeStats(5)
+	stats.Timestamp = 0
+	stats.Metrics = map[string]metrics.Metric{
+		"height": {
+			Samples: []metrics.Sample{{Timestamp: 1500000000, Value: 1}},
+			Nodes: map[string][]metrics.Sample{
+				"node1": {{Timestamp: 2000000000, Value: 2}},
+				"node0": {{Timestamp: 3000000000, Value: 3}},
+			},
+		},
+	}
+
+	var values []interface{}
+	up := newUsagePlot(false, false, false, false).withMetrics([]string{"height"})
+	up.processor = func(p *plot.Plot, vv ...interface{}) error {
+		values = vv
+		return nil
+	}
+
+	_, err := up.Process(stats)
+	require.NoError(t, err)
+	require.Len(t, values, 6)
+	require.Equal(t, "height", values[0])
+	require.Equal(t, 1.5, values[1].(plotter.XYs)[0].X)
+	require.Equal(t, "node0-height", values[2])
+	require.Equal(t, "node1-height", values[4])
+}
+
+func TestUsagePlot_ProcessRelative(t *testing.T) {
+	stats := &metrics.Stats{
+		Timestamp: 100,
+		Tags: map[int64]string{
+			101e9: metrics.PhaseStart("init"),
+			102e9: metrics.PhaseEnd("init"),
+			103e9: "checkpoint",
+		},
+		Nodes: map[string]metrics.NodeStats{
+			"node0": {Timestamps: []int64{100, 101, 102, 103}, CPU: []uint64{1, 2, 3, 4}},
+		},
+	}
+
+	var values []interface{}
+	up := newUsagePlot(false, false, true, false)
+	up.processor = func(p *plot.Plot, vv ...interface{}) error {
+		values = vv
+		return nil
+	}
+
+	p, err := up.Process(stats)
+	require.NoError(t, err)
+	require.Equal(t, plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 2}, {X: 2, Y: 3}, {X: 3, Y: 4}}, values[1])
+
+	ticks := p.X.Tick.Marker.Ticks(0, 3)
+	require.Len(t, ticks, 4)
+	require.Equal(t, "+0s", ticks[0].Label)
+	require.Equal(t, "init:start", ticks[1].Label)
+	require.Equal(t, "checkpoint", ticks[3].Label)
+
+	ticks = p.X.Tick.Marker.Ticks(-1.5, 0)
+	require.Equal(t, "-2s", ticks[0].Label)
+
+	// The plot is cropped to the phase.
+	up = up.withPhase("init")
+
+	p, err = up.Process(stats)
+	require.NoError(t, err)
+	require.Equal(t, plotter.XYs{{X: 1, Y: 2}, {X: 2, Y: 3}}, values[1])
+
+	// The regions are drawn with the lines.
+	dir, err := ioutil.TempDir(os.TempDir(), "plotter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, p.Save(DefaultImageWidth, DefaultImageHeight, filepath.Join(dir, "phase.png")))
+
+	up = up.withPhase("unknown")
+	_, err = up.Process(stats)
+	require.EqualError(t, err, "unknown phase 'unknown'")
+}
+
+func TestUsagePlot_MakePointsMs(t *testing.T) {
+	ns := metrics.NodeStats{
+		Timestamps:   []int64{10, 10, 11},
+		TimestampsMs: []int64{10000, 10500, 11000},
+		CPU:          []uint64{1, 2, 3},
+	}
+
+	up := newUsagePlot(false, false, true, false)
+	tl := newTimeline(&metrics.Stats{Timestamp: 10})
+
+	points := makePoints(ns, up.mappers["-cpu"], tl)
+	require.Equal(t, plotter.XYs{{X: 0, Y: 1}, {X: 0.5, Y: 2}, {X: 1, Y: 3}}, points)
+}
+
+func TestPhaseRegions_Make(t *testing.T) {
+	stats := &metrics.Stats{Timestamp: 10}
+	tl := newTimeline(stats)
+
+	phases := []metrics.Phase{
+		{Name: "init", Start: 11e9, End: 12e9},
+		{Name: "run", Start: 12e9, End: 15e9},
+	}
+
+	regions := makePhaseRegions(phases, tl)
+	require.Equal(t, phaseRegions{
+		{name: "init", start: 1, end: 2, index: 0},
+		{name: "run", start: 2, end: 5, index: 1},
+	}, regions)
+
+	tl.min = 2.5
+	tl.max = 4
+
+	regions = makePhaseRegions(phases, tl)
+	require.Equal(t, phaseRegions{{name: "run", start: 2.5, end: 4, index: 1}}, regions)
+}
+
 func TestUsagePlot_ProcessFailure(t *testing.T) {
 	n := 5
 	e := errors.New("processor error")
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// StatsVersion is the version of the statistics written by the strategies.
+// The statistics without a version were written before the units are
+// recorded.
+const StatsVersion = 1
+
+// CPUScale is the factor applied to the percentage of the CPU usage to store
+// it as an integer, which gives the hundredth of a percent.
+const CPUScale = 100
+
+// Units of the values of the statistics.
+const (
+	UnitSeconds      = "s"
+	UnitMilliseconds = "ms"
+	UnitNanoseconds  = "ns"
+	UnitBytes        = "B"
+	UnitCount        = "1"
+	UnitPercent      = "%"
+	UnitCentiPercent = "0.01%"
+)
+
+// Keys of the units that are not a column of the monitor.
+const (
+	UnitKeyTags    = "tags"
+	UnitKeyMetrics = "metrics"
+)
+
+// DefaultUnits returns the units of the current version of the statistics
+// indexed by the name of the column, and by the tags and the custom metrics
+// for their timestamps.
+func DefaultUnits() map[string]string {
+	return map[string]string{
+		ColumnTimestamp:       UnitSeconds,
+		ColumnTimestampMs:     UnitMilliseconds,
+		ColumnRxBytes:         UnitBytes,
+		ColumnTxBytes:         UnitBytes,
+		ColumnCPU:             UnitCentiPercent,
+		ColumnMemory:          UnitBytes,
+		ColumnRxPackets:       UnitCount,
+		ColumnTxPackets:       UnitCount,
+		ColumnRxErrors:        UnitCount,
+		ColumnTxErrors:        UnitCount,
+		ColumnRxDropped:       UnitCount,
+		ColumnTxDropped:       UnitCount,
+		ColumnRetransmissions: UnitCount,
+		ColumnBlockRead:       UnitBytes,
+		ColumnBlockWrite:      UnitBytes,
+		ColumnPIDs:            UnitCount,
+		ColumnMemoryCache:     UnitBytes,
+		ColumnMemoryRSS:       UnitBytes,
+		UnitKeyTags:           UnitNanoseconds,
+		UnitKeyMetrics:        UnitNanoseconds,
+	}
+}
+
+// Upgrade converts statistics written by an older version to the current
+// one. The statistics without a version do not record the unit of the CPU,
+// which depends on the strategy that wrote them: Kubernetes used the percent
+// and Docker the hundredth of a percent, so it must be given.
+func (s *Stats) Upgrade(cpuUnit string) error {
+	if s.Version >= StatsVersion {
+		return nil
+	}
+
+	switch cpuUnit {
+	case UnitPercent:
+		for name, ns := range s.Nodes {
+			for i := range ns.CPU {
+				ns.CPU[i] *= CPUScale
+			}
+
+			s.Nodes[name] = ns
+		}
+	case UnitCentiPercent:
+	default:
+		return xerrors.Errorf("unknown unit '%s'", cpuUnit)
+	}
+
+	s.Version = StatsVersion
+	s.Units = DefaultUnits()
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnits_New(t *testing.T) {
+	stats := NewStats()
+	require.Equal(t, StatsVersion, stats.Version)
+	require.Equal(t, UnitCentiPercent, stats.Units[ColumnCPU])
+	require.Equal(t, UnitNanoseconds, stats.Units[UnitKeyTags])
+
+	for _, col := range DefaultColumns {
+		require.Contains(t, stats.Units, col)
+	}
+
+	for _, extra := range ExtraColumns {
+		for _, col := range extra {
+			require.Contains(t, stats.Units, col)
+		}
+	}
+}
+
+func TestUnits_Upgrade(t *testing.T) {
+	stats := Stats{
+		Nodes: map[string]NodeStats{
+			"node0": {CPU: []uint64{1, 20}},
+		},
+	}
+
+	require.NoError(t, stats.Upgrade(UnitPercent))
+	require.Equal(t, []uint64{100, 2000}, stats.Nodes["node0"].CPU)
+	require.Equal(t, StatsVersion, stats.Version)
+	require.Equal(t, DefaultUnits(), stats.Units)
+
+	// Already up-to-date.
+	require.NoError(t, stats.Upgrade(UnitPercent))
+	require.Equal(t, []uint64{100, 2000}, stats.Nodes["node0"].CPU)
+
+	stats = Stats{Nodes: map[string]NodeStats{"node0": {CPU: []uint64{1}}}}
+	require.NoError(t, stats.Upgrade(UnitCentiPercent))
+	require.Equal(t, []uint64{1}, stats.Nodes["node0"].CPU)
+
+	stats = Stats{}
+	require.EqualError(t, stats.Upgrade("abc"), "unknown unit 'abc'")
+	require.Equal(t, 0, stats.Version)
+}
@@ -4,18 +4,41 @@ import (
 	"fmt"
 	"math"
 	"time"
+
+	"golang.org/x/xerrors"
 )
 
+// AreaLink is the set of properties of the links between the members of two
+// areas. When the delay value is not set, it is computed from the distance
+// between the areas and the leeway is used as a jitter.
+type AreaLink struct {
+	Delay    Delay
+	Loss     Loss
+	Upload   Bandwidth
+	Download Bandwidth
+}
+
 // Area is a subset of the topology that have a given number of nodes inside it
-// and a global location. A latency can be set for the members of the area and
-// the latency for outsiders is computed based on the distance to other areas.
+// and a global location. A latency, a loss and the upload and download
+// bandwidths can be set for the members of the area and the latency for
+// outsiders is computed based on the distance to other areas.
 type Area struct {
-	N       int
-	Latency Delay
-	X       float64
-	Y       float64
+	N        int
+	Latency  Delay
+	Loss     Loss
+	Upload   Bandwidth
+	Download Bandwidth
+	X        float64
+	Y        float64
+
+	// Outbound defines the properties of the links from the members of the
+	// area to the members of the other areas.
+	Outbound AreaLink
 
 	nodes map[Node][]Link
+	// members keeps the nodes in the order of creation so that the links, and
+	// thus the rules, are always in the same order.
+	members []Node
 }
 
 // AreaTopology is a network topology with multiple areas. Each members of an
@@ -24,53 +47,54 @@ type Area struct {
 // A unit of distance is 1ms thus coordinates can be adapted to fit a given
 // latency.
 type AreaTopology struct {
-	areas []*Area
+	areas     []*Area
+	overrides map[[2]int]AreaLink
 }
 
 // NewAreaTopology creates a topology based on multiple areas.
 func NewAreaTopology(areas ...*Area) *AreaTopology {
-	t := &AreaTopology{areas: areas}
+	t := &AreaTopology{
+		areas:     areas,
+		overrides: make(map[[2]int]AreaLink),
+	}
 
 	counter := 0
 
-	// Initialization phase to create the topology for each individual area.
+	// Initialization phase to create the nodes for each individual area.
 	for _, area := range t.areas {
 		area.nodes = make(map[Node][]Link)
-		nodes := make([]Node, area.N)
+		area.members = make([]Node, 0, area.N)
 
-		// 1. Create the nodes of the area
-		for i := range nodes {
-			nodes[i] = Node{Name: NodeID(fmt.Sprintf("node%d", counter))}
+		for i := 0; i < area.N; i++ {
+			node := Node{Name: NodeID(fmt.Sprintf("node%d", counter))}
+			area.nodes[node] = nil
+			area.members = append(area.members, node)
 			counter++
 		}
+	}
 
-		// 2. Create the links to the area members with the given latency.
-		for i, node := range nodes {
-			links := make([]Link, 0, area.N-1)
+	t.makeLinks()
 
-			for j, peer := range nodes {
-				if i != j {
-					links = append(links, Link{
-						Distant: peer,
-						Delay:   area.Latency,
-					})
-				}
-			}
+	return t
+}
 
-			area.nodes[node] = links
-		}
+// Override replaces the properties of the links going from the members of an
+// area to the members of another one. The areas must be part of the topology.
+func (t *AreaTopology) Override(from, to *Area, link AreaLink) error {
+	i := t.indexOf(from)
+	j := t.indexOf(to)
+	if i < 0 || j < 0 {
+		return xerrors.New("unknown area")
 	}
 
-	// Then the links between the areas are calculated.
-	for i, from := range t.areas {
-		for j, to := range areas {
-			if i != j {
-				t.makeAreaLinks(from, to)
-			}
-		}
+	if i == j {
+		return xerrors.New("cannot override links inside an area")
 	}
 
-	return t
+	t.overrides[[2]int{i, j}] = link
+	t.makeLinks()
+
+	return nil
 }
 
 // Len returns the number of nodes inside the topology.
@@ -86,12 +110,24 @@ func (t *AreaTopology) Len() int {
 func (t *AreaTopology) GetNodes() []Node {
 	nodes := make([]Node, 0)
 	for _, area := range t.areas {
+		nodes = append(nodes, area.members...)
+	}
+
+	return nodes
+}
+
+// AreaOf returns the index of the area of the node, or -1 if the node is not
+// part of the topology.
+func (t *AreaTopology) AreaOf(target NodeID) int {
+	for i, area := range t.areas {
 		for node := range area.nodes {
-			nodes = append(nodes, node)
+			if node.Name == target {
+				return i
+			}
 		}
 	}
 
-	return nodes
+	return -1
 }
 
 // Rules returns the list of rules for a given node in the topology.
@@ -101,11 +137,7 @@ func (t *AreaTopology) Rules(target NodeID, mapping map[NodeID]string) []Rule {
 			if node.Name == target {
 				rules := make([]Rule, 0, len(links))
 				for _, link := range links {
-					rules = append(rules, Rule{
-						IP:    mapping[link.Distant.Name],
-						Delay: link.Delay,
-						Loss:  link.Loss,
-					})
+					rules = append(rules, link.makeRule(mapping[link.Distant.Name]))
 				}
 
 				return rules
@@ -116,13 +148,68 @@ func (t *AreaTopology) Rules(target NodeID, mapping map[NodeID]string) []Rule {
 	return nil
 }
 
-func (t *AreaTopology) makeAreaLinks(from, to *Area) {
-	for node := range from.nodes {
+func (t *AreaTopology) indexOf(area *Area) int {
+	for i, a := range t.areas {
+		if a == area {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// makeLinks (re)creates the links of every node of the topology, first to the
+// members of the same area, then to the members of the other areas.
+func (t *AreaTopology) makeLinks() {
+	for _, area := range t.areas {
+		for _, node := range area.members {
+			links := make([]Link, 0, len(area.nodes)-1)
+
+			for _, peer := range area.members {
+				if peer != node {
+					links = append(links, Link{
+						Distant:  peer,
+						Delay:    area.Latency,
+						Loss:     area.Loss,
+						Upload:   area.Upload,
+						Download: area.Download,
+					})
+				}
+			}
+
+			area.nodes[node] = links
+		}
+	}
+
+	for i, from := range t.areas {
+		for j, to := range t.areas {
+			if i != j {
+				props, ok := t.overrides[[2]int{i, j}]
+				if !ok {
+					props = from.Outbound
+				}
+
+				t.makeAreaLinks(from, to, props)
+			}
+		}
+	}
+}
+
+func (t *AreaTopology) makeAreaLinks(from, to *Area, props AreaLink) {
+	delay := props.Delay
+	if delay.Value <= 0 {
+		delay.Value = calculateLatency(from.X, from.Y, to.X, to.Y).Value
+	}
+
+	for _, node := range from.members {
 		links := make([]Link, 0)
-		for dst := range to.nodes {
+		for _, dst := range to.members {
 			links = append(links, Link{
-				Distant: dst,
-				Delay:   calculateLatency(from.X, from.Y, to.X, to.Y),
+				Distant:  dst,
+				Delay:    delay,
+				Loss:     props.Loss,
+				Upload:   props.Upload,
+				Download: props.Download,
 			})
 		}
 
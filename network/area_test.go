@@ -38,6 +38,14 @@ func TestArea_GetNodes(t *testing.T) {
 	require.Len(t, ta.GetNodes(), 3)
 }
 
+func TestArea_AreaOf(t *testing.T) {
+	ta := NewAreaTopology(&Area{N: 1}, &Area{N: 2})
+
+	require.Equal(t, 0, ta.AreaOf("node0"))
+	require.Equal(t, 1, ta.AreaOf("node2"))
+	require.Equal(t, -1, ta.AreaOf("node3"))
+}
+
 func TestArea_Rules(t *testing.T) {
 	ta := NewAreaTopology(&Area{N: 2}, &Area{N: 3, X: 10})
 	mapping := map[NodeID]string{
@@ -50,11 +58,89 @@ func TestArea_Rules(t *testing.T) {
 
 	rules := ta.Rules(NodeID("node1"), mapping)
 
-	require.Equal(t, rules[0], Rule{IP: "127.0.0.1"})
-	require.Contains(t, rules, Rule{IP: "127.0.0.3", Delay: Delay{Value: 10 * time.Millisecond}})
-	require.Contains(t, rules, Rule{IP: "127.0.0.4", Delay: Delay{Value: 10 * time.Millisecond}})
-	require.Contains(t, rules, Rule{IP: "127.0.0.5", Delay: Delay{Value: 10 * time.Millisecond}})
+	require.Equal(t, []Rule{
+		{IP: "127.0.0.1"},
+		{IP: "127.0.0.3", Delay: Delay{Value: 10 * time.Millisecond}},
+		{IP: "127.0.0.4", Delay: Delay{Value: 10 * time.Millisecond}},
+		{IP: "127.0.0.5", Delay: Delay{Value: 10 * time.Millisecond}},
+	}, rules)
 
 	rules = ta.Rules(NodeID("abc"), nil)
 	require.Nil(t, rules)
 }
+
+func TestArea_LinkProperties(t *testing.T) {
+	europe := &Area{
+		N:        2,
+		Latency:  Delay{Value: time.Millisecond},
+		Loss:     Loss{Value: 0.05},
+		Upload:   Bandwidth{Value: 10 * Mbps},
+		Download: Bandwidth{Value: 20 * Mbps},
+		Outbound: AreaLink{
+			Delay:  Delay{Leeway: 2 * time.Millisecond},
+			Upload: Bandwidth{Value: Gbps},
+		},
+	}
+	asia := &Area{N: 1, X: 100}
+
+	ta := NewAreaTopology(europe, asia)
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+		NodeID("node2"): "127.0.0.3",
+	}
+
+	rules := ta.Rules(NodeID("node0"), mapping)
+	require.Len(t, rules, 2)
+	require.Equal(t, Rule{
+		IP:       "127.0.0.2",
+		Delay:    Delay{Value: time.Millisecond},
+		Loss:     Loss{Value: 0.05},
+		Upload:   Bandwidth{Value: 10 * Mbps},
+		Download: Bandwidth{Value: 20 * Mbps},
+	}, rules[0])
+	require.Equal(t, Rule{
+		IP:     "127.0.0.3",
+		Delay:  Delay{Value: 100 * time.Millisecond, Leeway: 2 * time.Millisecond},
+		Upload: Bandwidth{Value: Gbps},
+	}, rules[1])
+
+	rules = ta.Rules(NodeID("node2"), mapping)
+	require.Len(t, rules, 2)
+	require.Equal(t, Rule{IP: "127.0.0.1", Delay: Delay{Value: 100 * time.Millisecond}}, rules[0])
+	require.Equal(t, rules, ta.Rules(NodeID("node2"), mapping))
+}
+
+func TestArea_Override(t *testing.T) {
+	europe := &Area{N: 1}
+	asia := &Area{N: 1, X: 100}
+
+	ta := NewAreaTopology(europe, asia)
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+	}
+
+	err := ta.Override(europe, asia, AreaLink{
+		Delay: Delay{Value: 150 * time.Millisecond},
+		Loss:  Loss{Value: 0.01},
+	})
+	require.NoError(t, err)
+
+	rules := ta.Rules(NodeID("node0"), mapping)
+	require.Equal(t, []Rule{{
+		IP:    "127.0.0.2",
+		Delay: Delay{Value: 150 * time.Millisecond},
+		Loss:  Loss{Value: 0.01},
+	}}, rules)
+
+	// The opposite direction must be untouched.
+	rules = ta.Rules(NodeID("node1"), mapping)
+	require.Equal(t, []Rule{{IP: "127.0.0.1", Delay: Delay{Value: 100 * time.Millisecond}}}, rules)
+
+	err = ta.Override(europe, &Area{}, AreaLink{})
+	require.EqualError(t, err, "unknown area")
+
+	err = ta.Override(europe, europe, AreaLink{})
+	require.EqualError(t, err, "cannot override links inside an area")
+}
@@ -2,27 +2,86 @@ package network
 
 import "fmt"
 
-// CloudTopology is a topology that will create a node on each of the regions
+// Region is a location of the cloud that will host a given number of nodes.
+type Region struct {
+	Value string
+	N     int
+}
+
+// CloudLink defines additional properties emulated on top of the real world
+// latency for the links going from the nodes of a region to the nodes of
+// another one, or to the nodes of the same region.
+type CloudLink struct {
+	From     string
+	To       string
+	Delay    Delay
+	Loss     Loss
+	Upload   Bandwidth
+	Download Bandwidth
+}
+
+// CloudTopology is a topology that will create nodes on each of the regions
 // provided so that the topology will have latencies from the real world.
 type CloudTopology struct {
 	NodeSelectorKey string
 	nodes           []Node
+	links           []CloudLink
 }
 
-// NewCloudTopology returns a new cloud topology from the list of regions.
-func NewCloudTopology(key string, values []string) CloudTopology {
-	nodes := make([]Node, len(values))
-	for i, region := range values {
-		nodes[i] = Node{
-			Name:         NodeID(fmt.Sprintf("node%d", i)),
-			NodeSelector: region,
+// NewCloudTopology returns a new cloud topology with a node on each of the
+// regions. Optional links can be provided to emulate additional properties.
+func NewCloudTopology(key string, values []string, links ...CloudLink) CloudTopology {
+	regions := make([]Region, len(values))
+	for i, value := range values {
+		regions[i] = Region{Value: value, N: 1}
+	}
+
+	return NewCloudTopologyFromRegions(key, regions, links...)
+}
+
+// NewCloudTopologyFromRegions returns a new cloud topology with the given
+// number of nodes on each region. Optional links can be provided to emulate
+// additional properties. When several links are given for the same pair of
+// regions, the last one replaces the others so that a node never gets
+// conflicting rules for the same peer.
+func NewCloudTopologyFromRegions(key string, regions []Region, links ...CloudLink) CloudTopology {
+	nodes := make([]Node, 0)
+	for _, region := range regions {
+		for i := 0; i < region.N; i++ {
+			nodes = append(nodes, Node{
+				Name:         NodeID(fmt.Sprintf("node%d", len(nodes))),
+				NodeSelector: region.Value,
+			})
 		}
 	}
 
 	return CloudTopology{
 		NodeSelectorKey: key,
 		nodes:           nodes,
+		links:           mergeLinks(links),
+	}
+}
+
+// mergeLinks returns the links with a single one per pair of regions. The
+// last link of a pair is kept at the position of the first one.
+func mergeLinks(links []CloudLink) []CloudLink {
+	indices := make(map[[2]string]int)
+	merged := make([]CloudLink, 0, len(links))
+
+	for _, link := range links {
+		key := [2]string{link.From, link.To}
+
+		index, ok := indices[key]
+		if ok {
+			merged[index] = link
+			continue
+		}
+
+		indices[key] = len(merged)
+		merged = append(merged, link)
 	}
+
+	return merged
 }
 
 // Len returns the length of the topology.
@@ -35,7 +94,38 @@ func (t CloudTopology) GetNodes() []Node {
 	return t.nodes
 }
 
-// Rules always returns nil as there is no rule in a cloud topology.
-func (t CloudTopology) Rules(NodeID, map[NodeID]string) []Rule {
-	return nil
+// Rules returns the rules emulated on top of the real world latency for the
+// links of the node, or nil if there is none.
+func (t CloudTopology) Rules(target NodeID, mapping map[NodeID]string) []Rule {
+	var src *Node
+	for i := range t.nodes {
+		if t.nodes[i].Name == target {
+			src = &t.nodes[i]
+		}
+	}
+
+	if src == nil {
+		return nil
+	}
+
+	var rules []Rule
+	for _, link := range t.links {
+		if link.From != src.NodeSelector {
+			continue
+		}
+
+		for _, dst := range t.nodes {
+			if dst.NodeSelector == link.To && dst.Name != src.Name {
+				rules = append(rules, Rule{
+					IP:       mapping[dst.Name],
+					Delay:    link.Delay,
+					Loss:     link.Loss,
+					Upload:   link.Upload,
+					Download: link.Download,
+				})
+			}
+		}
+	}
+
+	return rules
 }
@@ -2,6 +2,7 @@ package network
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -27,3 +28,54 @@ func TestCloudTopology_Rules(t *testing.T) {
 	cloud := NewCloudTopology("key", []string{"A", "B", "C"})
 	require.Nil(t, cloud.Rules(NodeID("node0"), nil))
 }
+
+func TestCloudTopology_Regions(t *testing.T) {
+	cloud := NewCloudTopologyFromRegions("key", []Region{{Value: "A", N: 2}, {Value: "B", N: 3}})
+	require.Equal(t, 5, cloud.Len())
+
+	nodes := cloud.GetNodes()
+	require.Equal(t, NodeID("node1"), nodes[1].Name)
+	require.Equal(t, "A", nodes[1].NodeSelector)
+	require.Equal(t, NodeID("node4"), nodes[4].Name)
+	require.Equal(t, "B", nodes[4].NodeSelector)
+}
+
+func TestCloudTopology_RulesWithLinks(t *testing.T) {
+	cloud := NewCloudTopologyFromRegions("key",
+		[]Region{{Value: "A", N: 2}, {Value: "B", N: 1}},
+		CloudLink{From: "A", To: "B", Delay: Delay{Value: time.Millisecond}},
+		CloudLink{From: "A", To: "A", Loss: Loss{Value: 0.05}},
+	)
+
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+		NodeID("node2"): "127.0.0.3",
+	}
+
+	rules := cloud.Rules(NodeID("node0"), mapping)
+	require.Equal(t, []Rule{
+		{IP: "127.0.0.3", Delay: Delay{Value: time.Millisecond}},
+		{IP: "127.0.0.2", Loss: Loss{Value: 0.05}},
+	}, rules)
+
+	require.Nil(t, cloud.Rules(NodeID("node2"), mapping))
+	require.Nil(t, cloud.Rules(NodeID("unknown"), mapping))
+}
+
+func TestCloudTopology_DuplicateLinks(t *testing.T) {
+	cloud := NewCloudTopologyFromRegions("key",
+		[]Region{{Value: "A", N: 1}, {Value: "B", N: 1}},
+		CloudLink{From: "A", To: "B", Delay: Delay{Value: time.Millisecond}},
+		CloudLink{From: "A", To: "A", Loss: Loss{Value: 0.05}},
+		CloudLink{From: "A", To: "B", Delay: Delay{Value: 2 * time.Millisecond}},
+	)
+
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+	}
+
+	rules := cloud.Rules(NodeID("node0"), mapping)
+	require.Equal(t, []Rule{{IP: "127.0.0.2", Delay: Delay{Value: 2 * time.Millisecond}}}, rules)
+}
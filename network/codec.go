@@ -0,0 +1,193 @@
+package network
+
+import (
+	"encoding/json"
+	"sort"
+
+	"golang.org/x/xerrors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	kindSimple   = "simple"
+	kindFull     = "full"
+	kindArea     = "area"
+	kindCloud    = "cloud"
+	kindModified = "modified"
+)
+
+// areaOverride is the representation of the properties of the links between
+// two areas identified by their index in the topology.
+type areaOverride struct {
+	From int
+	To   int
+	Link AreaLink
+}
+
+// encodedTopology is the canonical representation of the built-in topologies.
+// Only the fields relevant to the kind of topology are filled.
+type encodedTopology struct {
+	Kind            string
+	Nodes           []Node            `json:",omitempty"`
+	Links           map[NodeID][]Link `json:",omitempty"`
+	Areas           []*Area           `json:",omitempty"`
+	Overrides       []areaOverride    `json:",omitempty"`
+	NodeSelectorKey string            `json:",omitempty"`
+	CloudLinks      []CloudLink       `json:",omitempty"`
+	Inner           *encodedTopology  `json:",omitempty"`
+	Modifiers       []Modifier        `json:",omitempty"`
+}
+
+// Marshal returns the canonical JSON representation of a built-in topology so
+// that it can be stored and replayed later on.
+func Marshal(t Topology) ([]byte, error) {
+	enc, err := encodeTopology(t)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(enc)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't marshal: %v", err)
+	}
+
+	return data, nil
+}
+
+// MarshalYAML returns the canonical YAML representation of a built-in
+// topology.
+func MarshalYAML(t Topology) ([]byte, error) {
+	data, err := Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = yaml.JSONToYAML(data)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't convert to yaml: %v", err)
+	}
+
+	return data, nil
+}
+
+// Unmarshal creates a topology from either its JSON or its YAML canonical
+// representation.
+func Unmarshal(data []byte) (Topology, error) {
+	// JSON being a subset of YAML, the conversion works for both formats.
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't convert to json: %v", err)
+	}
+
+	enc := encodedTopology{}
+	err = json.Unmarshal(data, &enc)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal: %v", err)
+	}
+
+	return decodeTopology(enc)
+}
+
+func encodeTopology(t Topology) (encodedTopology, error) {
+	switch topo := t.(type) {
+	case SimpleTopology:
+		return encodedTopology{Kind: kindSimple, Nodes: topo.nodes, Links: topo.links}, nil
+	case FullTopology:
+		return encodedTopology{Kind: kindFull, Nodes: topo.nodes, Links: topo.links}, nil
+	case *AreaTopology:
+		enc := encodedTopology{Kind: kindArea, Areas: topo.areas}
+		for key, link := range topo.overrides {
+			enc.Overrides = append(enc.Overrides, areaOverride{
+				From: key[0],
+				To:   key[1],
+				Link: link,
+			})
+		}
+
+		// The order is fixed so that the representation is canonical.
+		sort.Slice(enc.Overrides, func(i, j int) bool {
+			a, b := enc.Overrides[i], enc.Overrides[j]
+			return a.From < b.From || (a.From == b.From && a.To < b.To)
+		})
+
+		return enc, nil
+	case CloudTopology:
+		return encodedTopology{
+			Kind:            kindCloud,
+			Nodes:           topo.nodes,
+			NodeSelectorKey: topo.NodeSelectorKey,
+			CloudLinks:      topo.links,
+		}, nil
+	case ModifiedTopology:
+		inner, err := encodeTopology(topo.inner)
+		if err != nil {
+			return encodedTopology{}, err
+		}
+
+		return encodedTopology{
+			Kind:      kindModified,
+			Inner:     &inner,
+			Modifiers: topo.modifiers,
+		}, nil
+	default:
+		return encodedTopology{}, xerrors.Errorf("unsupported topology '%T'", t)
+	}
+}
+
+func decodeTopology(enc encodedTopology) (Topology, error) {
+	switch enc.Kind {
+	case kindSimple:
+		return SimpleTopology{nodes: enc.Nodes, links: makeLinks(enc)}, nil
+	case kindFull:
+		return FullTopology{
+			SimpleTopology: SimpleTopology{nodes: enc.Nodes, links: makeLinks(enc)},
+		}, nil
+	case kindArea:
+		t := NewAreaTopology(enc.Areas...)
+		for _, o := range enc.Overrides {
+			if o.From < 0 || o.From >= len(t.areas) || o.To < 0 || o.To >= len(t.areas) {
+				return nil, xerrors.Errorf("invalid override from %d to %d", o.From, o.To)
+			}
+
+			t.overrides[[2]int{o.From, o.To}] = o.Link
+		}
+
+		t.makeLinks()
+
+		return t, nil
+	case kindCloud:
+		return CloudTopology{
+			NodeSelectorKey: enc.NodeSelectorKey,
+			nodes:           enc.Nodes,
+			links:           enc.CloudLinks,
+		}, nil
+	case kindModified:
+		if enc.Inner == nil {
+			return nil, xerrors.New("missing inner topology")
+		}
+
+		inner, err := decodeTopology(*enc.Inner)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewModifiedTopology(inner, enc.Modifiers...), nil
+	default:
+		return nil, xerrors.Errorf("unknown topology kind '%s'", enc.Kind)
+	}
+}
+
+// makeLinks makes sure every node has an entry in the map of links so that
+// the decoded topology is identical to a freshly created one.
+func makeLinks(enc encodedTopology) map[NodeID][]Link {
+	links := make(map[NodeID][]Link)
+	for _, node := range enc.Nodes {
+		links[node.Name] = []Link{}
+	}
+
+	for key, value := range enc.Links {
+		links[key] = value
+	}
+
+	return links
+}
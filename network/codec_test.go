@@ -0,0 +1,125 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Simple(t *testing.T) {
+	topo := NewSimpleTopology(3, 20*time.Millisecond)
+
+	data, err := Marshal(topo)
+	require.NoError(t, err)
+
+	res, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, topo, res)
+}
+
+func TestCodec_Full(t *testing.T) {
+	topo := NewFullTopology(
+		FullInput{From: "A", To: "B", Latency: time.Millisecond},
+		FullInput{From: "B", To: "C", Latency: 2 * time.Millisecond},
+	)
+
+	data, err := MarshalYAML(topo)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Kind: full")
+
+	res, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, topo, res)
+}
+
+func TestCodec_Area(t *testing.T) {
+	europe := &Area{N: 2, Latency: Delay{Value: time.Millisecond}}
+	asia := &Area{N: 3, X: 50, Loss: Loss{Value: 0.1}}
+	america := &Area{N: 1, Y: 80, Outbound: AreaLink{Upload: Bandwidth{Value: Mbps}}}
+
+	topo := NewAreaTopology(europe, asia, america)
+	require.NoError(t, topo.Override(asia, america, AreaLink{Delay: Delay{Value: time.Second}}))
+	require.NoError(t, topo.Override(europe, asia, AreaLink{Loss: Loss{Value: 0.5}}))
+
+	data, err := Marshal(topo)
+	require.NoError(t, err)
+
+	// The representation must be stable.
+	data2, err := Marshal(topo)
+	require.NoError(t, err)
+	require.Equal(t, data, data2)
+
+	res, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, topo.Len(), res.Len())
+
+	mapping := make(map[NodeID]string)
+	for _, node := range topo.GetNodes() {
+		mapping[node.Name] = string(node.Name)
+	}
+
+	for _, node := range topo.GetNodes() {
+		require.ElementsMatch(t, topo.Rules(node.Name, mapping), res.Rules(node.Name, mapping))
+	}
+}
+
+func TestCodec_Cloud(t *testing.T) {
+	topo := NewCloudTopologyFromRegions("zone",
+		[]Region{{Value: "A", N: 2}, {Value: "B", N: 1}},
+		CloudLink{From: "A", To: "B", Loss: Loss{Value: 0.1}},
+	)
+
+	data, err := Marshal(topo)
+	require.NoError(t, err)
+
+	res, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, topo, res)
+}
+
+func TestCodec_Modified(t *testing.T) {
+	topo := NewModifiedTopology(
+		NewSimpleTopology(3, 20*time.Millisecond),
+		AddLoss(NodeLinks("node2"), 0.05),
+		ExcludeLinks(PairLinks("node0", "node1")),
+	)
+
+	data, err := Marshal(topo)
+	require.NoError(t, err)
+
+	res, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, topo, res)
+}
+
+func TestCodec_Failures(t *testing.T) {
+	_, err := Marshal(nil)
+	require.EqualError(t, err, "unsupported topology '<nil>'")
+
+	_, err = MarshalYAML(nil)
+	require.Error(t, err)
+
+	_, err = Unmarshal([]byte("\t"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't convert to json: ")
+
+	_, err = Unmarshal([]byte("[]"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't unmarshal: ")
+
+	_, err = Unmarshal([]byte(`{"Kind":"abc"}`))
+	require.EqualError(t, err, "unknown topology kind 'abc'")
+
+	_, err = Unmarshal([]byte(`{"Kind":"area","Areas":[{"N":1}],"Overrides":[{"From":0,"To":1}]}`))
+	require.EqualError(t, err, "invalid override from 0 to 1")
+
+	_, err = Marshal(NewModifiedTopology(nil))
+	require.EqualError(t, err, "unsupported topology '<nil>'")
+
+	_, err = Unmarshal([]byte(`{"Kind":"modified"}`))
+	require.EqualError(t, err, "missing inner topology")
+
+	_, err = Unmarshal([]byte(`{"Kind":"modified","Inner":{"Kind":"abc"}}`))
+	require.EqualError(t, err, "unknown topology kind 'abc'")
+}
@@ -2,11 +2,15 @@ package network
 
 import "time"
 
-// FullInput is a wrapper for parameters to create a full topology.
+// FullInput is a wrapper for parameters to create a full topology. When the
+// protocol or the port is set, the latency only applies to the matching
+// traffic so that several inputs can define the same pair of nodes.
 type FullInput struct {
-	From    string
-	To      string
-	Latency time.Duration
+	From     string
+	To       string
+	Protocol string
+	Port     uint16
+	Latency  time.Duration
 }
 
 // FullTopology is a topology where the links are defined one by one.
@@ -36,8 +40,10 @@ func NewFullTopology(inputs ...FullInput) FullTopology {
 		}
 
 		links[src.Name] = append(links[src.Name], Link{
-			Distant: dst,
-			Delay:   Delay{Value: input.Latency},
+			Distant:  dst,
+			Protocol: input.Protocol,
+			Port:     input.Port,
+			Delay:    Delay{Value: input.Latency},
 		})
 	}
 
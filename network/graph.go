@@ -0,0 +1,123 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Edge is a directed link of a topology alongside the properties emulated
+// for the traffic going from the source to the destination.
+type Edge struct {
+	From NodeID
+	To   NodeID
+	Rule Rule
+}
+
+// Edges returns the directed links of the topology ordered by source and then
+// by destination. Nodes are used as their own address to generate the rules
+// so that the destination can be found back.
+func Edges(t Topology) []Edge {
+	nodes := sortedNodes(t)
+
+	mapping := make(map[NodeID]string)
+	for _, node := range nodes {
+		mapping[node] = string(node)
+	}
+
+	edges := make([]Edge, 0)
+	for _, node := range nodes {
+		rules := t.Rules(node, mapping)
+
+		sort.SliceStable(rules, func(i, j int) bool {
+			return rules[i].IP < rules[j].IP
+		})
+
+		for _, rule := range rules {
+			edges = append(edges, Edge{
+				From: node,
+				To:   NodeID(rule.IP),
+				Rule: rule,
+			})
+		}
+	}
+
+	return edges
+}
+
+// WriteDOT writes the Graphviz representation of the topology. Each link is
+// an edge labelled with the emulated properties.
+func WriteDOT(w io.Writer, t Topology) error {
+	_, err := fmt.Fprintln(w, "digraph topology {")
+	if err != nil {
+		return err
+	}
+
+	for _, node := range sortedNodes(t) {
+		_, err = fmt.Fprintf(w, "\t%q;\n", node)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, edge := range Edges(t) {
+		_, err = fmt.Fprintf(w, "\t%q -> %q [label=%q];\n", edge.From, edge.To, makeLabel(edge.Rule))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+func makeLabel(rule Rule) string {
+	params := make([]string, 0, 5)
+
+	if rule.IsSpecific() {
+		params = append(params, makePortLabel(rule))
+	}
+
+	for _, param := range []fmt.Stringer{rule.Delay, rule.Loss} {
+		if str := param.String(); str != "" {
+			params = append(params, str)
+		}
+	}
+
+	if str := rule.Upload.String(); str != "" {
+		params = append(params, "upload "+str)
+	}
+
+	if str := rule.Download.String(); str != "" {
+		params = append(params, "download "+str)
+	}
+
+	return strings.Join(params, "\n")
+}
+
+func sortedNodes(t Topology) []NodeID {
+	nodes := make([]NodeID, 0, t.Len())
+	for _, node := range t.GetNodes() {
+		nodes = append(nodes, node.Name)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i] < nodes[j]
+	})
+
+	return nodes
+}
+
+func makePortLabel(rule Rule) string {
+	proto := rule.Protocol
+	if proto == "" {
+		proto = "any"
+	}
+
+	if rule.Port == 0 {
+		return proto
+	}
+
+	return fmt.Sprintf("%s/%d", proto, rule.Port)
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Edges(t *testing.T) {
+	topo := NewFullTopology(
+		FullInput{From: "B", To: "C", Latency: time.Millisecond},
+		FullInput{From: "A", To: "C", Latency: 2 * time.Millisecond},
+		FullInput{From: "A", To: "B", Latency: 3 * time.Millisecond},
+	)
+
+	edges := Edges(topo)
+	require.Equal(t, []Edge{
+		{From: "A", To: "B", Rule: NewDelayRule("B", 3*time.Millisecond)},
+		{From: "A", To: "C", Rule: NewDelayRule("C", 2*time.Millisecond)},
+		{From: "B", To: "C", Rule: NewDelayRule("C", time.Millisecond)},
+	}, edges)
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	topo := NewSimpleTopology(2, 20*time.Millisecond)
+
+	out := new(bytes.Buffer)
+	err := WriteDOT(out, topo)
+	require.NoError(t, err)
+
+	expected := "digraph topology {\n" +
+		"\t\"node0\";\n" +
+		"\t\"node1\";\n" +
+		"\t\"node1\" -> \"node0\" [label=\"delay 20ms\"];\n" +
+		"}\n"
+	require.Equal(t, expected, out.String())
+}
+
+func TestGraph_MakeLabel(t *testing.T) {
+	rule := Rule{
+		Delay:    Delay{Value: time.Millisecond},
+		Loss:     Loss{Value: 0.1},
+		Upload:   Bandwidth{Value: Kbps},
+		Download: Bandwidth{Value: Mbps},
+	}
+
+	require.Equal(t, "delay 1ms\nloss 10.00%\nupload rate 1000bit\ndownload rate 1000000bit", makeLabel(rule))
+	require.Equal(t, "", makeLabel(Rule{}))
+
+	rule = Rule{Protocol: UDP, Port: 7000, Delay: Delay{Value: time.Millisecond}}
+	require.Equal(t, "udp/7000\ndelay 1ms", makeLabel(rule))
+	require.Equal(t, "tcp", makeLabel(Rule{Protocol: TCP}))
+}
+
+func TestGraph_WriteDOTFailure(t *testing.T) {
+	err := WriteDOT(badWriter{}, NewSimpleTopology(2, 0))
+	require.EqualError(t, err, "write error")
+}
+
+type badWriter struct{}
+
+func (badWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write error")
+}
@@ -0,0 +1,321 @@
+package network
+
+import (
+	"net"
+	"time"
+)
+
+// LinkSelector selects a subset of the links of a topology. The zero value
+// selects every link. When only the node is set, the links from and to this
+// node are selected and when the peer is also set, only the links between the
+// two nodes, in both directions, are selected. The protocol and the port
+// further restrict the selection to the matching traffic of the links.
+type LinkSelector struct {
+	Node     NodeID
+	Peer     NodeID
+	Protocol string
+	Port     uint16
+}
+
+// AllLinks returns a selector for every link of a topology.
+func AllLinks() LinkSelector {
+	return LinkSelector{}
+}
+
+// NodeLinks returns a selector for the links from and to the node.
+func NodeLinks(node NodeID) LinkSelector {
+	return LinkSelector{Node: node}
+}
+
+// PairLinks returns a selector for the links between the two nodes.
+func PairLinks(node, peer NodeID) LinkSelector {
+	return LinkSelector{Node: node, Peer: peer}
+}
+
+// PortLinks returns a selector for the traffic of the selected links that
+// uses the protocol and, when it is not zero, the port of the distant node.
+func PortLinks(links LinkSelector, protocol string, port uint16) LinkSelector {
+	links.Protocol = protocol
+	links.Port = port
+	return links
+}
+
+// Match returns true when the link from the source to the destination is
+// selected.
+func (s LinkSelector) Match(from, to NodeID) bool {
+	if s.Node == "" {
+		return true
+	}
+
+	if s.Peer == "" {
+		return from == s.Node || to == s.Node
+	}
+
+	return (from == s.Node && to == s.Peer) || (from == s.Peer && to == s.Node)
+}
+
+// matchEvery returns true when every link from the node is selected.
+func (s LinkSelector) matchEvery(from NodeID) bool {
+	return s.Node == "" || (s.Node == from && s.Peer == "")
+}
+
+// matchTraffic returns true when the traffic of the rule is selected.
+func (s LinkSelector) matchTraffic(rule Rule) bool {
+	return (s.Protocol == "" || s.Protocol == rule.Protocol) && (s.Port == 0 || s.Port == rule.Port)
+}
+
+// isSpecific returns true when only a part of the traffic is selected.
+func (s LinkSelector) isSpecific() bool {
+	return s.Protocol != "" || s.Port != 0
+}
+
+// Modifier is a change applied to the properties of the selected links. The
+// delay is first scaled when the factor is positive, then the additional
+// delay and loss are applied. An excluded link is removed from the emulation.
+type Modifier struct {
+	Links   LinkSelector
+	Scale   float64
+	Delay   time.Duration
+	Loss    float64
+	Exclude bool
+}
+
+// ScaleDelay returns a modifier that multiplies the delay of the selected
+// links by the factor.
+func ScaleDelay(links LinkSelector, factor float64) Modifier {
+	return Modifier{Links: links, Scale: factor}
+}
+
+// AddDelay returns a modifier that adds a delay to the selected links.
+func AddDelay(links LinkSelector, delay time.Duration) Modifier {
+	return Modifier{Links: links, Delay: delay}
+}
+
+// AddLoss returns a modifier that adds a percentage of loss to the selected
+// links. The loss is independent of the existing one, which means 10% added
+// to a link with 10% of loss will end up at 19%.
+func AddLoss(links LinkSelector, loss float64) Modifier {
+	return Modifier{Links: links, Loss: loss}
+}
+
+// ExcludeLinks returns a modifier that removes the selected links from the
+// emulation.
+func ExcludeLinks(links LinkSelector) Modifier {
+	return Modifier{Links: links, Exclude: true}
+}
+
+// apply returns the rule with the modifications and false if the link is
+// excluded.
+func (m Modifier) apply(rule Rule) (Rule, bool) {
+	if m.Exclude {
+		return rule, false
+	}
+
+	if m.Scale > 0 {
+		rule.Delay.Value = time.Duration(float64(rule.Delay.Value) * m.Scale)
+		rule.Delay.Leeway = time.Duration(float64(rule.Delay.Leeway) * m.Scale)
+	}
+
+	rule.Delay.Value += m.Delay
+
+	if m.Loss > 0 {
+		rule.Loss.Value += m.Loss - rule.Loss.Value*m.Loss
+	}
+
+	return rule, true
+}
+
+// ModifiedTopology is a topology that applies a list of modifiers on top of
+// the links of an inner topology. The modifiers are applied in order and the
+// inner topology can itself be a modified one.
+type ModifiedTopology struct {
+	inner     Topology
+	modifiers []Modifier
+}
+
+// NewModifiedTopology creates a topology that modifies the inner one.
+func NewModifiedTopology(inner Topology, modifiers ...Modifier) ModifiedTopology {
+	return ModifiedTopology{
+		inner:     inner,
+		modifiers: modifiers,
+	}
+}
+
+// Unwrap returns the inner topology.
+func (t ModifiedTopology) Unwrap() Topology {
+	return t.inner
+}
+
+// Len returns the number of nodes of the inner topology.
+func (t ModifiedTopology) Len() int {
+	return t.inner.Len()
+}
+
+// GetNodes returns the nodes of the inner topology.
+func (t ModifiedTopology) GetNodes() []Node {
+	return t.inner.GetNodes()
+}
+
+// Rules returns the rules of the inner topology after the modifiers have been
+// applied. Links that do not have a rule in the inner topology are also
+// considered so that a delay can be added to any of them, including the
+// generic traffic of a node that only has rules for a protocol or a port. A
+// modifier restricted to a protocol or a port creates the rules for this
+// traffic from the properties of the link. The rules of addresses that are not
+// nodes, like subnets, are only changed by the modifiers that select every
+// link of the target, and a node inside a subnet starts from the properties
+// of the subnet.
+func (t ModifiedTopology) Rules(target NodeID, mapping map[NodeID]string) []Rule {
+	reverse := make(map[string]NodeID)
+	for node, addr := range mapping {
+		reverse[addr] = node
+	}
+
+	rules := t.inner.Rules(target, mapping)
+
+	covered := make(map[linkKey]struct{})
+	for _, rule := range rules {
+		if node, ok := reverse[rule.IP]; ok {
+			covered[linkKey{node: node, protocol: rule.Protocol, port: rule.Port}] = struct{}{}
+		}
+	}
+
+	res := make([]Rule, 0, len(rules))
+	modified := make([]Rule, len(rules))
+	for i, rule := range rules {
+		dst, known := reverse[rule.IP]
+
+		rule, kept := t.apply(rule, target, dst, known)
+		if kept {
+			res = append(res, rule)
+			modified[i] = rule
+		}
+	}
+
+	// The generic traffic of the nodes is always considered, and the traffic
+	// of the protocols and the ports selected by the modifiers.
+	keys := []linkKey{{}}
+	for _, m := range t.modifiers {
+		key := linkKey{protocol: m.Links.Protocol, port: m.Links.Port}
+		if m.Links.isSpecific() && !containsKey(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	// Links without a rule are only kept if a modifier changes them.
+	for _, node := range t.inner.GetNodes() {
+		addr, known := mapping[node.Name]
+		if node.Name == target || !known {
+			continue
+		}
+
+		for _, key := range keys {
+			key.node = node.Name
+			if _, found := covered[key]; found {
+				continue
+			}
+
+			base, current := lookupRule(rules, modified, addr, key)
+
+			rule, kept := t.apply(base, target, node.Name, true)
+			if kept && rule != current {
+				res = append(res, rule)
+			}
+		}
+	}
+
+	return res
+}
+
+// apply returns the rule after the modifiers that select the link from the
+// target to the destination, and false if the link is excluded. When the
+// destination is not a node, only the modifiers that select every link of the
+// target are applied.
+func (t ModifiedTopology) apply(rule Rule, target, dst NodeID, known bool) (Rule, bool) {
+	kept := true
+
+	for _, m := range t.modifiers {
+		if !kept {
+			break
+		}
+
+		selected := (known && m.Links.Match(target, dst)) || (!known && m.Links.matchEvery(target))
+
+		if selected && m.Links.matchTraffic(rule) {
+			rule, kept = m.apply(rule)
+		}
+	}
+
+	return rule, kept
+}
+
+// linkKey identifies the traffic to a node that a rule applies to.
+type linkKey struct {
+	node     NodeID
+	protocol string
+	port     uint16
+}
+
+func containsKey(keys []linkKey, key linkKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupRule returns the rule that applies to the traffic of the key to the
+// address, before and after the modifiers, with the address, the protocol and
+// the port of the key. A specific rule of the address comes first, then the
+// generic rule of the address and finally the generic rule of a subnet that
+// contains it, as in the emulation.
+func lookupRule(rules, modified []Rule, addr string, key linkKey) (Rule, Rule) {
+	index := -1
+	for i, rule := range rules {
+		covers := (rule.Protocol == "" || rule.Protocol == key.protocol) &&
+			(rule.Port == 0 || rule.Port == key.port)
+
+		if rule.IP == addr && covers && (index < 0 || rule.IsSpecific()) {
+			index = i
+			if rule.IsSpecific() {
+				break
+			}
+		}
+	}
+
+	if index < 0 {
+		index = indexOfSubnet(rules, addr)
+	}
+
+	base := Rule{}
+	current := Rule{}
+	if index >= 0 {
+		base = rules[index]
+		current = modified[index]
+	}
+
+	for _, rule := range []*Rule{&base, &current} {
+		rule.IP = addr
+		rule.Protocol = key.protocol
+		rule.Port = key.port
+	}
+
+	return base, current
+}
+
+// indexOfSubnet returns the index of the first generic rule of a subnet that
+// contains the address, or -1 if there is none.
+func indexOfSubnet(rules []Rule, addr string) int {
+	ip := net.ParseIP(addr)
+
+	for i, rule := range rules {
+		_, subnet, err := net.ParseCIDR(rule.IP)
+		if err == nil && !rule.IsSpecific() && ip != nil && subnet.Contains(ip) {
+			return i
+		}
+	}
+
+	return -1
+}
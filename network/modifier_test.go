@@ -0,0 +1,215 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkSelector_Match(t *testing.T) {
+	require.True(t, AllLinks().Match("A", "B"))
+
+	sel := NodeLinks("A")
+	require.True(t, sel.Match("A", "B"))
+	require.True(t, sel.Match("B", "A"))
+	require.False(t, sel.Match("B", "C"))
+
+	sel = PairLinks("A", "B")
+	require.True(t, sel.Match("A", "B"))
+	require.True(t, sel.Match("B", "A"))
+	require.False(t, sel.Match("A", "C"))
+}
+
+func TestModifier_Apply(t *testing.T) {
+	rule := Rule{
+		IP:    "A",
+		Delay: Delay{Value: 10 * time.Millisecond, Leeway: time.Millisecond},
+		Loss:  Loss{Value: 0.1},
+	}
+
+	res, kept := ScaleDelay(AllLinks(), 2).apply(rule)
+	require.True(t, kept)
+	require.Equal(t, Delay{Value: 20 * time.Millisecond, Leeway: 2 * time.Millisecond}, res.Delay)
+
+	res, kept = AddDelay(AllLinks(), time.Millisecond).apply(rule)
+	require.True(t, kept)
+	require.Equal(t, 11*time.Millisecond, res.Delay.Value)
+
+	res, kept = AddLoss(AllLinks(), 0.1).apply(rule)
+	require.True(t, kept)
+	require.InDelta(t, 0.19, res.Loss.Value, 1e-9)
+
+	_, kept = ExcludeLinks(AllLinks()).apply(rule)
+	require.False(t, kept)
+}
+
+func TestModifiedTopology_Rules(t *testing.T) {
+	inner := NewSimpleTopology(3, 20*time.Millisecond)
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+		NodeID("node2"): "127.0.0.3",
+	}
+
+	topo := NewModifiedTopology(inner, AddDelay(AllLinks(), 5*time.Millisecond))
+	require.Equal(t, 3, topo.Len())
+	require.Len(t, topo.GetNodes(), 3)
+	require.Equal(t, inner, topo.Unwrap())
+
+	// Links without rules in the inner topology are also delayed.
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.2", 5*time.Millisecond),
+		NewDelayRule("127.0.0.3", 5*time.Millisecond),
+	}, topo.Rules(NodeID("node0"), mapping))
+
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.1", 25*time.Millisecond),
+		NewDelayRule("127.0.0.3", 5*time.Millisecond),
+	}, topo.Rules(NodeID("node1"), mapping))
+
+	topo = NewModifiedTopology(inner,
+		AddLoss(NodeLinks("node2"), 0.05),
+		ExcludeLinks(PairLinks("node1", "node0")),
+	)
+
+	require.Equal(t, []Rule{
+		NewLossRule("127.0.0.3", 0.05),
+	}, topo.Rules(NodeID("node1"), mapping))
+
+	require.Equal(t, []Rule{
+		{IP: "127.0.0.1", Delay: Delay{Value: 20 * time.Millisecond}, Loss: Loss{Value: 0.05}},
+		NewLossRule("127.0.0.2", 0.05),
+	}, topo.Rules(NodeID("node2"), mapping))
+
+	// Rules of the inner topology are kept even when they have no effect.
+	topo = NewModifiedTopology(NewSimpleTopology(2, 0))
+	require.Equal(t, []Rule{{IP: "127.0.0.1"}}, topo.Rules(NodeID("node1"), mapping))
+}
+
+func TestModifiedTopology_Nested(t *testing.T) {
+	topo := NewModifiedTopology(
+		NewModifiedTopology(NewSimpleTopology(2, 10*time.Millisecond), ScaleDelay(AllLinks(), 3)),
+		AddDelay(AllLinks(), time.Millisecond),
+	)
+
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+	}
+
+	require.Equal(t, []Rule{NewDelayRule("127.0.0.1", 31*time.Millisecond)},
+		topo.Rules(NodeID("node1"), mapping))
+}
+
+func TestModifiedTopology_SpecificRules(t *testing.T) {
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+	}
+
+	inner := fakeTopology{
+		nodes: []Node{{Name: "node0"}, {Name: "node1"}},
+		rules: []Rule{{IP: "127.0.0.1", Protocol: UDP, Port: 7000, Delay: Delay{Value: 50 * time.Millisecond}}},
+	}
+
+	// The generic traffic of the node is delayed as well as its port.
+	topo := NewModifiedTopology(inner, AddDelay(AllLinks(), 5*time.Millisecond))
+	require.Equal(t, []Rule{
+		{IP: "127.0.0.1", Protocol: UDP, Port: 7000, Delay: Delay{Value: 55 * time.Millisecond}},
+		NewDelayRule("127.0.0.1", 5*time.Millisecond),
+	}, topo.Rules(NodeID("node1"), mapping))
+}
+
+func TestModifiedTopology_SubnetRules(t *testing.T) {
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+		NodeID("node2"): "127.0.0.3",
+	}
+
+	inner := fakeTopology{
+		nodes: []Node{{Name: "node0"}, {Name: "node1"}, {Name: "node2"}},
+		rules: []Rule{NewDelayRule("127.0.0.0/24", 20*time.Millisecond)},
+	}
+
+	topo := NewModifiedTopology(inner,
+		AddDelay(AllLinks(), 5*time.Millisecond),
+		AddLoss(NodeLinks("node2"), 0.05),
+	)
+
+	// The subnet is delayed and the node with more loss starts from the
+	// properties of the subnet.
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.0/24", 25*time.Millisecond),
+		{IP: "127.0.0.3", Delay: Delay{Value: 25 * time.Millisecond}, Loss: Loss{Value: 0.05}},
+	}, topo.Rules(NodeID("node1"), mapping))
+
+	// A pair does not change the subnet but only the node inside it.
+	topo = NewModifiedTopology(inner, AddDelay(PairLinks("node1", "node0"), 5*time.Millisecond))
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.0/24", 20*time.Millisecond),
+		NewDelayRule("127.0.0.1", 25*time.Millisecond),
+	}, topo.Rules(NodeID("node1"), mapping))
+
+	// Excluding every link of the node also excludes the subnet.
+	topo = NewModifiedTopology(inner, ExcludeLinks(NodeLinks("node1")))
+	require.Empty(t, topo.Rules(NodeID("node1"), mapping))
+}
+
+// fakeTopology is a topology that returns the same rules for every node.
+type fakeTopology struct {
+	nodes []Node
+	rules []Rule
+}
+
+func (t fakeTopology) Len() int {
+	return len(t.nodes)
+}
+
+func (t fakeTopology) GetNodes() []Node {
+	return t.nodes
+}
+
+func (t fakeTopology) Rules(NodeID, map[NodeID]string) []Rule {
+	return append([]Rule{}, t.rules...)
+}
+
+func TestModifiedTopology_PortRules(t *testing.T) {
+	mapping := map[NodeID]string{
+		NodeID("node0"): "127.0.0.1",
+		NodeID("node1"): "127.0.0.2",
+		NodeID("node2"): "127.0.0.3",
+	}
+
+	inner := NewFullTopology(
+		FullInput{From: "node1", To: "node0", Latency: 20 * time.Millisecond},
+		FullInput{From: "node1", To: "node0", Protocol: TCP, Port: 2000, Latency: 30 * time.Millisecond},
+		FullInput{From: "node1", To: "node2", Latency: 10 * time.Millisecond},
+	)
+
+	// Only the gossip port is delayed while the RPC port and the other traffic
+	// keep the properties of the links.
+	topo := NewModifiedTopology(inner,
+		AddDelay(PortLinks(AllLinks(), UDP, 7000), 50*time.Millisecond),
+		AddDelay(PortLinks(AllLinks(), TCP, 2000), 5*time.Millisecond),
+	)
+
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.1", 20*time.Millisecond),
+		{IP: "127.0.0.1", Protocol: TCP, Port: 2000, Delay: Delay{Value: 35 * time.Millisecond}},
+		NewDelayRule("127.0.0.3", 10*time.Millisecond),
+		{IP: "127.0.0.1", Protocol: UDP, Port: 7000, Delay: Delay{Value: 70 * time.Millisecond}},
+		{IP: "127.0.0.3", Protocol: UDP, Port: 7000, Delay: Delay{Value: 60 * time.Millisecond}},
+		{IP: "127.0.0.3", Protocol: TCP, Port: 2000, Delay: Delay{Value: 15 * time.Millisecond}},
+	}, topo.Rules(NodeID("node1"), mapping))
+
+	// A selector without port selects the traffic of every port.
+	topo = NewModifiedTopology(inner, ScaleDelay(PortLinks(PairLinks("node1", "node0"), TCP, 0), 2))
+	require.Equal(t, []Rule{
+		NewDelayRule("127.0.0.1", 20*time.Millisecond),
+		{IP: "127.0.0.1", Protocol: TCP, Port: 2000, Delay: Delay{Value: 60 * time.Millisecond}},
+		NewDelayRule("127.0.0.3", 10*time.Millisecond),
+		{IP: "127.0.0.1", Protocol: TCP, Delay: Delay{Value: 40 * time.Millisecond}},
+	}, topo.Rules(NodeID("node1"), mapping))
+}
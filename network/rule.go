@@ -2,6 +2,7 @@ package network
 
 import (
 	"fmt"
+	"net"
 	"time"
 )
 
@@ -17,6 +18,11 @@ func (d Delay) String() string {
 		return ""
 	}
 
+	if d.Leeway > 0 {
+		// The leeway is emulated as a jitter around the delay.
+		return fmt.Sprintf("delay %dms %dms", d.Value.Milliseconds(), d.Leeway.Milliseconds())
+	}
+
 	return fmt.Sprintf("delay %dms", d.Value.Milliseconds())
 }
 
@@ -34,12 +40,51 @@ func (l Loss) String() string {
 	return fmt.Sprintf("loss %.2f%%", l.Value*100)
 }
 
+const (
+	// Kbps is a bandwidth of one thousand bits per second.
+	Kbps uint64 = 1000
+	// Mbps is a bandwidth of one million bits per second.
+	Mbps = 1000 * Kbps
+	// Gbps is a bandwidth of one billion bits per second.
+	Gbps = 1000 * Mbps
+)
+
+// Bandwidth is a parameter of a rule that will limit the rate of the traffic.
+// The value is expressed in bits per second.
+type Bandwidth struct {
+	Value uint64
+}
+
+func (b Bandwidth) String() string {
+	if b.Value == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("rate %dbit", b.Value)
+}
+
+const (
+	// TCP is the name of the protocol to match only the TCP traffic.
+	TCP = "tcp"
+	// UDP is the name of the protocol to match only the UDP traffic.
+	UDP = "udp"
+)
+
 // Rule is a set of parameters to apply to a topology link to change the
-// properties like the RRT, bandwidth and so on.
+// properties like the RRT, bandwidth and so on. The download bandwidth is
+// applied to the incoming traffic from the address whereas the other
+// parameters are applied to the outgoing traffic. The address is either a
+// single IPv4 or IPv6 address, or a subnet using the CIDR notation. The
+// traffic can be further restricted to a protocol and, for TCP and UDP, to a
+// port of the distant address.
 type Rule struct {
-	IP    string
-	Delay Delay
-	Loss  Loss
+	IP       string
+	Protocol string
+	Port     uint16
+	Delay    Delay
+	Loss     Loss
+	Upload   Bandwidth
+	Download Bandwidth
 }
 
 // NewDelayRule creates a rule that only adds delay to the link.
@@ -58,7 +103,43 @@ func NewLossRule(ip string, loss float64) Rule {
 	}
 }
 
+// NewBandwidthRule creates a rule that only limits the upload and download
+// bandwidths of the link. A zero value means no limit.
+func NewBandwidthRule(ip string, upload, download uint64) Rule {
+	return Rule{
+		IP:       ip,
+		Upload:   Bandwidth{Value: upload},
+		Download: Bandwidth{Value: download},
+	}
+}
+
 // MatchAddr returns the match filter for the rule.
 func (r Rule) MatchAddr() string {
+	_, subnet, err := net.ParseCIDR(r.IP)
+	if err == nil {
+		return subnet.String()
+	}
+
+	if r.IsIPv6() {
+		return fmt.Sprintf("%s/128", r.IP)
+	}
+
 	return fmt.Sprintf("%s/32", r.IP)
 }
+
+// IsSpecific returns true when the rule only matches a subset of the traffic
+// going to the address.
+func (r Rule) IsSpecific() bool {
+	return r.Protocol != "" || r.Port != 0
+}
+
+// IsIPv6 returns true when the address of the rule is an IPv6 address or
+// subnet.
+func (r Rule) IsIPv6() bool {
+	ip := net.ParseIP(r.IP)
+	if ip == nil {
+		ip, _, _ = net.ParseCIDR(r.IP)
+	}
+
+	return ip != nil && ip.To4() == nil
+}
@@ -11,6 +11,9 @@ func TestRule_Delay(t *testing.T) {
 	delay := Delay{Value: time.Millisecond}
 	require.Equal(t, "delay 1ms", delay.String())
 
+	delay.Leeway = 2 * time.Millisecond
+	require.Equal(t, "delay 1ms 2ms", delay.String())
+
 	delay.Value = -1
 	require.Equal(t, "", delay.String())
 }
@@ -26,6 +29,14 @@ func TestRule_Loss(t *testing.T) {
 	require.Equal(t, "", loss.String())
 }
 
+func TestRule_Bandwidth(t *testing.T) {
+	bw := Bandwidth{Value: 5 * Mbps}
+	require.Equal(t, "rate 5000000bit", bw.String())
+
+	bw.Value = 0
+	require.Equal(t, "", bw.String())
+}
+
 func TestRule_NewDelay(t *testing.T) {
 	rule := NewDelayRule("1.2.3.4", 10*time.Millisecond)
 	require.Equal(t, "1.2.3.4/32", rule.MatchAddr())
@@ -39,3 +50,40 @@ func TestRule_NewLoss(t *testing.T) {
 	require.Equal(t, "", rule.Delay.String())
 	require.Equal(t, "loss 50.00%", rule.Loss.String())
 }
+
+func TestRule_NewBandwidth(t *testing.T) {
+	rule := NewBandwidthRule("1.2.3.4", 10*Kbps, 20*Kbps)
+	require.Equal(t, "1.2.3.4/32", rule.MatchAddr())
+	require.Equal(t, "", rule.Delay.String())
+	require.Equal(t, "rate 10000bit", rule.Upload.String())
+	require.Equal(t, "rate 20000bit", rule.Download.String())
+}
+
+func TestRule_MatchAddr(t *testing.T) {
+	rule := Rule{IP: "1.2.3.4"}
+	require.Equal(t, "1.2.3.4/32", rule.MatchAddr())
+	require.False(t, rule.IsIPv6())
+
+	rule.IP = "10.1.2.3/16"
+	require.Equal(t, "10.1.0.0/16", rule.MatchAddr())
+	require.False(t, rule.IsIPv6())
+
+	rule.IP = "fd00::1"
+	require.Equal(t, "fd00::1/128", rule.MatchAddr())
+	require.True(t, rule.IsIPv6())
+
+	rule.IP = "fd00::/64"
+	require.Equal(t, "fd00::/64", rule.MatchAddr())
+	require.True(t, rule.IsIPv6())
+}
+
+func TestRule_IsSpecific(t *testing.T) {
+	rule := Rule{IP: "1.2.3.4"}
+	require.False(t, rule.IsSpecific())
+
+	rule.Protocol = UDP
+	require.True(t, rule.IsSpecific())
+
+	rule = Rule{IP: "1.2.3.4", Port: 80}
+	require.True(t, rule.IsSpecific())
+}
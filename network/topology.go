@@ -25,11 +25,33 @@ func (n Node) String() string {
 }
 
 // Link is a network link from the host to the node. It defines the properties
-// of the link like a delay or a percentage of loss.
+// of the link like a delay or a percentage of loss. The upload bandwidth limits
+// the traffic going to the distant node whereas the download bandwidth limits
+// the traffic coming from it. When the protocol or the port is set, the
+// properties only apply to the matching traffic so that several links can
+// target the same node.
 type Link struct {
-	Distant Node
-	Delay   Delay
-	Loss    Loss
+	Distant  Node
+	Protocol string
+	Port     uint16
+	Delay    Delay
+	Loss     Loss
+	Upload   Bandwidth
+	Download Bandwidth
+}
+
+// makeRule returns the rule that emulates the link properties for the given
+// address of the distant node.
+func (l Link) makeRule(ip string) Rule {
+	return Rule{
+		IP:       ip,
+		Protocol: l.Protocol,
+		Port:     l.Port,
+		Delay:    l.Delay,
+		Loss:     l.Loss,
+		Upload:   l.Upload,
+		Download: l.Download,
+	}
 }
 
 // Topology provides the primitive to get information about the mapping of
@@ -92,11 +114,7 @@ func (t SimpleTopology) Rules(node NodeID, mapping map[NodeID]string) []Rule {
 	for _, link := range t.links[node] {
 		ip := mapping[link.Distant.Name]
 
-		rules = append(rules, Rule{
-			IP:    ip,
-			Delay: link.Delay,
-			Loss:  link.Loss,
-		})
+		rules = append(rules, link.makeRule(ip))
 	}
 
 	return rules
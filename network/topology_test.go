@@ -64,3 +64,20 @@ func TestTopology_Rules(t *testing.T) {
 	require.Equal(t, mapping[NodeID("node0")], rules[0].IP)
 	require.Equal(t, int64(25), rules[0].Delay.Value.Milliseconds())
 }
+
+func TestTopology_RulesPort(t *testing.T) {
+	topo := NewFullTopology(
+		FullInput{From: "node1", To: "node0", Latency: 25 * time.Millisecond},
+		FullInput{From: "node1", To: "node0", Protocol: UDP, Port: 7000, Latency: 50 * time.Millisecond},
+	)
+
+	rules := topo.Rules(NodeID("node1"), map[NodeID]string{NodeID("node0"): "127.0.0.1"})
+	require.Len(t, rules, 2)
+	require.False(t, rules[0].IsSpecific())
+	require.Equal(t, Rule{
+		IP:       "127.0.0.1",
+		Protocol: UDP,
+		Port:     7000,
+		Delay:    Delay{Value: 50 * time.Millisecond},
+	}, rules[1])
+}
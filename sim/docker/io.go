@@ -5,32 +5,46 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"go.dedis.ch/simnet/daemon"
 	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/metrics/collect"
 	"go.dedis.ch/simnet/sim"
 	"golang.org/x/xerrors"
 )
 
+// ScrapeTimeout is the maximum amount of time to scrape the application of a
+// container.
+const ScrapeTimeout = 500 * time.Millisecond
+
 type dockerio struct {
-	cli       client.APIClient
-	stats     metrics.Stats
-	statsLock sync.Mutex
+	cli          client.APIClient
+	options      *sim.Options
+	stats        metrics.Stats
+	statsLock    sync.Mutex
+	recorder     *metrics.Recorder
+	scrapeClient *http.Client
 }
 
-func newDockerIO(cli client.APIClient) *dockerio {
+func newDockerIO(cli client.APIClient, options *sim.Options) *dockerio {
 	return &dockerio{
-		cli:   cli,
-		stats: metrics.NewStats(),
+		cli:          cli,
+		options:      options,
+		stats:        metrics.NewStats(),
+		recorder:     metrics.NewRecorder(),
+		scrapeClient: &http.Client{Timeout: ScrapeTimeout},
 	}
 }
 
@@ -43,6 +57,18 @@ func (dio *dockerio) Tag(name string) {
 	dio.statsLock.Unlock()
 }
 
+// Record saves a sample of the custom metric for the node, or for the whole
+// simulation when the node is empty.
+func (dio *dockerio) Record(metric, node string, value float64) {
+	dio.recorder.Record(metric, node, value)
+}
+
+// Observe adds the value to the histogram of the custom metric for the node,
+// or for the whole simulation when the node is empty.
+func (dio *dockerio) Observe(metric, node string, value float64) {
+	dio.recorder.Observe(metric, node, value)
+}
+
 // Read reads a file in the container at the given path. It returns a reader
 // that will eventually deliver the content of the file. The caller is
 // responsible for closing the stream.
@@ -205,6 +231,15 @@ func (dio *dockerio) FetchStats(from, end time.Time, filename string) error {
 		return xerrors.Errorf("couldn't create file: %v", err)
 	}
 
+	dio.recorder.Fill(&dio.stats)
+
+	dio.stats.Parameters = make(map[string]string)
+	if dio.options != nil {
+		dio.stats.Parameters = dio.options.Parameters()
+	}
+
+	dio.stats.Parameters["strategy"] = "docker"
+
 	// TODO: time range
 	enc := json.NewEncoder(file)
 	err = enc.Encode(&dio.stats)
@@ -228,8 +263,21 @@ func (dio *dockerio) monitorContainers(ctx context.Context, containers []types.C
 		}
 	}
 
+	// The network monitor only knows the addresses of the peers.
+	names := make(map[string]string)
+	for _, c := range containers {
+		if c.NetworkSettings == nil {
+			continue
+		}
+
+		netcfg := c.NetworkSettings.Networks[DefaultContainerNetwork]
+		if netcfg != nil && netcfg.IPAddress != "" {
+			names[netcfg.IPAddress] = containerName(c)
+		}
+	}
+
 	for _, container := range containers {
-		closer, err := dio.monitorContainer(ctx, container)
+		closer, err := dio.monitorContainer(ctx, container, names)
 		if err != nil {
 			globalCloser()
 			return nil, xerrors.Errorf("couldn't listen stats: %v", err)
@@ -241,34 +289,54 @@ func (dio *dockerio) monitorContainers(ctx context.Context, containers []types.C
 	return globalCloser, nil
 }
 
-func (dio *dockerio) monitorContainer(ctx context.Context, container types.Container) (func(), error) {
+func (dio *dockerio) monitorContainer(ctx context.Context, container types.Container, names map[string]string) (func(), error) {
+	columns, err := dio.monitorColumns()
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get the columns: %v", err)
+	}
+
+	// The Docker API provides neither the retransmissions nor the traffic of
+	// each peer, so they are read from the network namespace of the
+	// container.
+	networkCloser, err := dio.monitorNetwork(ctx, container)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't monitor the network: %v", err)
+	}
+
 	resp, err := dio.cli.ContainerStats(ctx, container.ID, true)
 	if err != nil {
+		networkCloser()
 		return nil, xerrors.Errorf("couldn't get stats: %v", err)
 	}
 
 	dec := json.NewDecoder(resp.Body)
 	ns := &metrics.NodeStats{}
+	scrapeURL := dio.makeScrapeURL(container)
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 
 	go func() {
 		for {
-			data := &types.StatsJSON{}
-			err := dec.Decode(data)
+			data, err := collect.DecodeStats(dec)
 			if err != nil {
 				wg.Done()
 				return
 			}
 
-			ns.Timestamps = append(ns.Timestamps, time.Now().Unix())
-			ns.RxBytes = append(ns.RxBytes, data.Networks["eth0"].RxBytes)
-			ns.TxBytes = append(ns.TxBytes, data.Networks["eth0"].TxBytes)
+			values := collect.Values(data, "eth0")
+			ts := time.Now().Unix()
 
-			prev := data.PreCPUStats.CPUUsage.TotalUsage
-			prevSys := data.PreCPUStats.SystemUsage
-			ns.CPU = append(ns.CPU, uint64(calculateCPUPercent(prev, prevSys, data)))
-			ns.Memory = append(ns.Memory, data.MemoryStats.Usage)
+			ns.Append(ts, filterColumns(values, columns))
+
+			if scrapeURL != "" {
+				// The application is reached from the host through the
+				// address of the container. A failed scrape only leaves a gap
+				// in the series.
+				values, err := metrics.Scrape(dio.scrapeClient, scrapeURL, dio.options.Scrape.Series)
+				if err == nil {
+					ns.AddApplication(ts, values)
+				}
+			}
 
 			dio.statsLock.Lock()
 			dio.stats.Nodes[containerName(container)] = *ns
@@ -279,23 +347,175 @@ func (dio *dockerio) monitorContainer(ctx context.Context, container types.Conta
 	closer := func() {
 		resp.Body.Close()
 		wg.Wait()
+
+		network := networkCloser()
+		network.ResolvePeers(names)
+
+		dio.statsLock.Lock()
+		mergeNetwork(ns, network)
+		dio.stats.Nodes[containerName(container)] = *ns
+		dio.statsLock.Unlock()
 	}
 
 	return closer, nil
 }
 
-// https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L175-L188
-func calculateCPUPercent(previousCPU, previousSystem uint64, v *types.StatsJSON) int {
-	var (
-		cpuPercent = 0.0
-		// calculate the change for the cpu usage of the container in between readings
-		cpuDelta = float64(v.CPUStats.CPUUsage.TotalUsage) - float64(previousCPU)
-		// calculate the change for the entire system between readings
-		systemDelta = float64(v.CPUStats.SystemUsage) - float64(previousSystem)
-	)
+// monitorNetwork starts the monitor in a container that shares the network
+// namespace of the given container. The closer stops the monitor and returns
+// the statistics written so far.
+func (dio *dockerio) monitorNetwork(ctx context.Context, c types.Container) (func() metrics.NodeStats, error) {
+	cfg := &container.Config{
+		AttachStdout: true,
+		AttachStderr: true,
+		Image:        fmt.Sprintf("%s:%s", ImageMonitor, daemon.Version),
+		Entrypoint:   monitorNetworkCommand,
+	}
+
+	hcfg := &container.HostConfig{
+		AutoRemove: true,
+		// The accounting of the connections is enabled by the monitor.
+		CapAdd:      []string{"NET_ADMIN"},
+		NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", c.ID)),
+	}
 
-	if systemDelta > 0.0 && cpuDelta > 0.0 {
-		cpuPercent = (cpuDelta / systemDelta) * float64(len(v.CPUStats.CPUUsage.PercpuUsage)) * 100.0
+	resp, err := dio.cli.ContainerCreate(ctx, cfg, hcfg, nil, "")
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't create monitor container: %v", err)
+	}
+
+	conn, err := dio.cli.ContainerAttach(ctx, resp.ID, types.ContainerAttachOptions{
+		Stream: true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		err = xerrors.Errorf("failed attaching container: %v", err)
+		return nil, removeContainer(dio.cli, resp.ID, err)
 	}
-	return int(math.Ceil(cpuPercent * 100))
+
+	err = dio.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
+	if err != nil {
+		conn.Close()
+		err = xerrors.Errorf("couldn't start monitor container: %v", err)
+		return nil, removeContainer(dio.cli, resp.ID, err)
+	}
+
+	out := new(bytes.Buffer)
+	done := make(chan struct{})
+
+	go func() {
+		// The stream is closed when the container stops.
+		stdcopy.StdCopy(out, ioutil.Discard, conn.Reader)
+		close(done)
+	}()
+
+	closer := func() metrics.NodeStats {
+		timeout := ContainerStopTimeout
+		// The context of the execution might be done at that point.
+		dio.cli.ContainerStop(context.Background(), resp.ID, &timeout)
+
+		<-done
+		conn.Close()
+
+		// A corrupted output still gives the samples read so far.
+		ns, _ := metrics.NewNodeStats(out, time.Unix(0, 0), time.Now())
+
+		return ns
+	}
+
+	return closer, nil
+}
+
+// mergeNetwork fills the retransmissions and the traffic of the peers of the
+// node with the samples of the network monitor. Each timestamp of the node
+// takes the latest sample of the network at the same second or before, and
+// zero before the first one, as the counters are cumulative.
+func mergeNetwork(ns *metrics.NodeStats, network metrics.NodeStats) {
+	n := len(ns.Timestamps)
+
+	ns.Retransmissions = make([]uint64, n)
+	ns.Peers = make(map[string]metrics.PeerStats, len(network.Peers))
+	for addr := range network.Peers {
+		ns.Peers[addr] = metrics.PeerStats{
+			RxBytes: make([]uint64, n),
+			TxBytes: make([]uint64, n),
+		}
+	}
+
+	j := -1
+	for i, ts := range ns.Timestamps {
+		for j+1 < len(network.Timestamps) && network.Timestamps[j+1] <= ts {
+			j++
+		}
+
+		if j < 0 {
+			continue
+		}
+
+		if j < len(network.Retransmissions) {
+			ns.Retransmissions[i] = network.Retransmissions[j]
+		}
+
+		for addr, peer := range network.Peers {
+			if j < len(peer.RxBytes) {
+				ns.Peers[addr].RxBytes[i] = peer.RxBytes[j]
+				ns.Peers[addr].TxBytes[i] = peer.TxBytes[j]
+			}
+		}
+	}
+}
+
+// snapshots returns the latest resource usage of the containers monitored so
+// far.
+func (dio *dockerio) snapshots() map[string]metrics.Snapshot {
+	dio.statsLock.Lock()
+	defer dio.statsLock.Unlock()
+
+	snapshots := make(map[string]metrics.Snapshot, len(dio.stats.Nodes))
+	for name, ns := range dio.stats.Nodes {
+		snap, ok := ns.Snapshot()
+		if ok {
+			snapshots[name] = snap
+		}
+	}
+
+	return snapshots
+}
+
+// makeScrapeURL returns the address of the metrics endpoint of the
+// application of the container, or an empty string when it is not scraped.
+func (dio *dockerio) makeScrapeURL(container types.Container) string {
+	if dio.options == nil || dio.options.Scrape == nil || container.NetworkSettings == nil {
+		return ""
+	}
+
+	netcfg := container.NetworkSettings.Networks[DefaultContainerNetwork]
+	if netcfg == nil || netcfg.IPAddress == "" {
+		return ""
+	}
+
+	return dio.options.Scrape.URL(netcfg.IPAddress)
+}
+
+// monitorColumns returns the columns recorded for the containers, which are
+// the default ones and the metric sets selected by the options.
+func (dio *dockerio) monitorColumns() ([]string, error) {
+	if dio.options == nil || dio.options.Monitor == nil {
+		return metrics.MonitorColumns(nil)
+	}
+
+	return metrics.MonitorColumns(dio.options.Monitor.Metrics)
+}
+
+// filterColumns returns the values of the given columns only.
+func filterColumns(values map[string]uint64, columns []string) map[string]uint64 {
+	filtered := make(map[string]uint64, len(columns))
+	for _, col := range columns {
+		value, ok := values[col]
+		if ok {
+			filtered[col] = value
+		}
+	}
+
+	return filtered
 }
@@ -7,16 +7,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/simnet/metrics"
 	"go.dedis.ch/simnet/sim"
@@ -30,6 +37,19 @@ func TestIO_Tag(t *testing.T) {
 	require.Len(t, dio.stats.Tags, 2)
 }
 
+func TestIO_Record(t *testing.T) {
+	dio := newTestDockerIO(&testIOClient{})
+
+	dio.Record("height", "node0", 1)
+	dio.Record("throughput", "", 2)
+	dio.Observe("latency", "node0", 3)
+
+	stats := metrics.Stats{}
+	dio.recorder.Fill(&stats)
+	require.Len(t, stats.Metrics, 2)
+	require.Len(t, stats.Histograms, 1)
+}
+
 func TestIO_Read(t *testing.T) {
 	buffer := bytes.NewBufferString("abc")
 	dio := newTestDockerIO(&testIOClient{buffer: buffer})
@@ -187,9 +207,35 @@ func TestIO_FetchStats(t *testing.T) {
 	err := dio.FetchStats(time.Now(), time.Now(), file)
 	require.NoError(t, err)
 
+	stats := metrics.Stats{}
 	buffer, err := ioutil.ReadFile(file)
 	require.NoError(t, err)
-	require.Equal(t, "{\"Timestamp\":0,\"Tags\":{},\"Nodes\":{}}\n", string(buffer))
+	require.NoError(t, json.Unmarshal(buffer, &stats))
+	require.Equal(t, metrics.StatsVersion, stats.Version)
+	require.Equal(t, metrics.UnitCentiPercent, stats.Units[metrics.ColumnCPU])
+	require.Equal(t, "docker", stats.Parameters["strategy"])
+	require.Empty(t, stats.Nodes)
+
+	dio.Record("height", "", 1)
+
+	err = dio.FetchStats(time.Now(), time.Now(), file)
+	require.NoError(t, err)
+
+	stats = metrics.Stats{}
+	buffer, err = ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(buffer, &stats))
+	require.Len(t, stats.Metrics["height"].Samples, 1)
+
+	// The options are not required to write the parameters.
+	dio.options = nil
+	require.NoError(t, dio.FetchStats(time.Now(), time.Now(), file))
+
+	stats = metrics.Stats{}
+	buffer, err = ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(buffer, &stats))
+	require.Equal(t, map[string]string{"strategy": "docker"}, stats.Parameters)
 }
 
 func TestIO_MonitorContainers(t *testing.T) {
@@ -207,13 +253,168 @@ func TestIO_MonitorContainers(t *testing.T) {
 	require.Equal(t, uint64(126), dio.stats.Nodes["node0"].Memory[0])
 	require.Equal(t, uint64(123), dio.stats.Nodes["node0"].TxBytes[0])
 	require.Equal(t, uint64(124), dio.stats.Nodes["node0"].RxBytes[0])
+	require.Equal(t, uint64(127), dio.stats.Nodes["node0"].TxPackets[0])
+	require.Equal(t, uint64(128), dio.stats.Nodes["node0"].RxDropped[0])
+	require.Equal(t, []uint64{250000}, dio.stats.Nodes["node0"].CPU)
+	require.Empty(t, dio.stats.Nodes["node0"].PIDs)
+	require.Equal(t, []uint64{0}, dio.stats.Nodes["node0"].Retransmissions)
+
+	snapshots := dio.snapshots()
+	require.Len(t, snapshots, 1)
+	require.Equal(t, uint64(126), snapshots["node0"].Memory)
+	require.Equal(t, uint64(124), snapshots["node0"].RxBytes)
+
+	dio.stats.Nodes["node1"] = metrics.NodeStats{}
+	require.Len(t, dio.snapshots(), 1)
+}
+
+func TestIO_MonitorContainersNetwork(t *testing.T) {
+	client := &testIOClient{
+		networkOutput: metrics.FormatHeader(metrics.DefaultColumns) +
+			"1000,0,0,0,0,0,0,0,0,0,0,5;172.17.0.3,10,20;10.0.0.1,1,2\n" +
+			"2000,0,0,0,0,0,0,0,0,0,0,7;172.17.0.3,30,40;10.0.0.1,1,2\n",
+	}
+	dio := newTestDockerIO(client)
+
+	containers := []types.Container{
+		makeTestContainer("id:node0"),
+		{
+			Names: []string{"/node1"},
+			NetworkSettings: &types.SummaryNetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					DefaultContainerNetwork: {IPAddress: "172.17.0.3"},
+				},
+			},
+		},
+	}
+
+	cancel, err := dio.monitorContainers(context.Background(), containers)
+	require.NoError(t, err)
+	cancel()
+
+	require.Len(t, client.callsContainerCreate, 2)
+	call := client.callsContainerCreate[0]
+	require.Equal(t, monitorNetworkCommand, []string(call.cfg.Entrypoint))
+	require.Equal(t, container.NetworkMode("container:id:node0"), call.hcfg.NetworkMode)
+	require.Len(t, client.callsContainerStop, 2)
+	require.Empty(t, client.callsContainerRemove)
+
+	ns := dio.stats.Nodes["node0"]
+	require.Equal(t, []uint64{7}, ns.Retransmissions)
+	require.Len(t, ns.Peers, 2)
+	require.Equal(t, []uint64{30}, ns.Peers["node1"].RxBytes)
+	require.Equal(t, []uint64{40}, ns.Peers["node1"].TxBytes)
+	require.Equal(t, []uint64{1}, ns.Peers["10.0.0.1"].RxBytes)
+
+	client.errContainerCreate = errors.New("oops")
+	_, err = dio.monitorContainers(context.Background(), containers)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't monitor the network: ")
+
+	client.errContainerCreate = nil
+	client.errContainerAttach = errors.New("oops")
+	_, err = dio.monitorContainers(context.Background(), containers)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed attaching container: ")
+	// The container is removed as it has never started.
+	require.Equal(t, []string{"monitor"}, client.callsContainerRemove)
+
+	client.errContainerAttach = nil
+	client.errContainerStart = errors.New("oops")
+	_, err = dio.monitorContainers(context.Background(), containers)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't start monitor container: ")
+	require.Len(t, client.callsContainerRemove, 2)
+}
+
+func TestIO_MergeNetwork(t *testing.T) {
+	ns := &metrics.NodeStats{Timestamps: []int64{1, 2, 3, 5}}
+
+	network := metrics.NodeStats{
+		Timestamps:      []int64{2, 3, 3, 4},
+		Retransmissions: []uint64{1, 2, 3, 4},
+		Peers: map[string]metrics.PeerStats{
+			"A": {RxBytes: []uint64{10, 20, 30, 40}, TxBytes: []uint64{1, 2, 3, 4}},
+		},
+	}
+
+	mergeNetwork(ns, network)
+	require.Equal(t, []uint64{0, 1, 3, 4}, ns.Retransmissions)
+	require.Equal(t, []uint64{0, 10, 30, 40}, ns.Peers["A"].RxBytes)
+	require.Equal(t, []uint64{0, 1, 3, 4}, ns.Peers["A"].TxBytes)
+
+	mergeNetwork(ns, metrics.NodeStats{})
+	require.Equal(t, []uint64{0, 0, 0, 0}, ns.Retransmissions)
+	require.Empty(t, ns.Peers)
+}
+
+func TestIO_MonitorContainersMetrics(t *testing.T) {
+	dio := newTestDockerIO(&testIOClient{})
+	sim.WithMonitor(0, "pids", "memory")(dio.options)
+
+	containers := []types.Container{
+		{Names: []string{"/node0"}},
+	}
+
+	cancel, err := dio.monitorContainers(context.Background(), containers)
+	require.NoError(t, err)
+	cancel()
+
+	require.Equal(t, []uint64{0}, dio.stats.Nodes["node0"].PIDs)
+	require.Equal(t, []uint64{0}, dio.stats.Nodes["node0"].MemoryRSS)
+	require.Empty(t, dio.stats.Nodes["node0"].BlockRead)
+
+	sim.WithMonitor(0, "unknown")(dio.options)
+	_, err = dio.monitorContainers(context.Background(), containers)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't get the columns: ")
+}
+
+func TestIO_MonitorContainersScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/metrics", r.URL.Path)
+		fmt.Fprintln(w, "# TYPE sql_count counter")
+		fmt.Fprintln(w, "sql_count{type=\"select\"} 42")
+		fmt.Fprintln(w, "other 1")
+	}))
+	defer srv.Close()
+
+	addr, err := net.ResolveTCPAddr("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	dio := newTestDockerIO(&testIOClient{})
+	sim.WithPrometheus(int32(addr.Port), "/metrics", "sql_count")(dio.options)
+
+	containers := []types.Container{
+		{
+			Names: []string{"/node0"},
+			NetworkSettings: &types.SummaryNetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					DefaultContainerNetwork: {IPAddress: "127.0.0.1"},
+				},
+			},
+		},
+	}
+
+	cancel, err := dio.monitorContainers(context.Background(), containers)
+	require.NoError(t, err)
+	cancel()
+
+	series := dio.stats.Nodes["node0"].Application["sql_count{type=\"select\"}"]
+	require.Equal(t, []float64{42}, series.Values)
+	require.Len(t, dio.stats.Nodes["node0"].Application, 1)
+
+	require.Equal(t, "", dio.makeScrapeURL(types.Container{}))
 }
 
 func newTestDockerIO(client *testIOClient) dockerio {
 	stats := metrics.NewStats()
 	return dockerio{
-		cli:   client,
-		stats: stats,
+		cli:          client,
+		options:      &sim.Options{},
+		stats:        stats,
+		recorder:     metrics.NewRecorder(),
+		scrapeClient: http.DefaultClient,
 	}
 }
 
@@ -231,6 +432,46 @@ type testIOClient struct {
 	errContainerExecAttach error
 	errContainerExecStart  error
 	errContainerStats      error
+	errContainerCreate     error
+	errContainerAttach     error
+	errContainerStart      error
+
+	// networkOutput is the output of the network monitor.
+	networkOutput        string
+	callsContainerCreate []testCallContainerCreate
+	callsContainerStop   []string
+	callsContainerRemove []string
+}
+
+func (c *testIOClient) ContainerCreate(ctx context.Context, cfg *container.Config, hcfg *container.HostConfig, ncfg *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	c.callsContainerCreate = append(c.callsContainerCreate, testCallContainerCreate{ctx, cfg, hcfg, ncfg, name})
+
+	return container.ContainerCreateCreatedBody{ID: "monitor"}, c.errContainerCreate
+}
+
+func (c *testIOClient) ContainerAttach(context.Context, string, types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	buffer := new(bytes.Buffer)
+	stdcopy.NewStdWriter(buffer, stdcopy.Stdout).Write([]byte(c.networkOutput))
+
+	conn := &testConn{buffer: new(bytes.Buffer)}
+
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(buffer)}, c.errContainerAttach
+}
+
+func (c *testIOClient) ContainerStart(context.Context, string, types.ContainerStartOptions) error {
+	return c.errContainerStart
+}
+
+func (c *testIOClient) ContainerStop(ctx context.Context, id string, timeout *time.Duration) error {
+	c.callsContainerStop = append(c.callsContainerStop, id)
+
+	return nil
+}
+
+func (c *testIOClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	c.callsContainerRemove = append(c.callsContainerRemove, id)
+
+	return nil
 }
 
 func (c *testIOClient) CopyFromContainer(context.Context, string, string) (io.ReadCloser, types.ContainerPathStat, error) {
@@ -296,8 +537,10 @@ func (c *testIOClient) ContainerStats(context.Context, string, bool) (types.Cont
 	enc.Encode(&types.StatsJSON{
 		Networks: map[string]types.NetworkStats{
 			"eth0": {
-				TxBytes: testStatBaseValue,
-				RxBytes: testStatBaseValue + 1,
+				TxBytes:   testStatBaseValue,
+				RxBytes:   testStatBaseValue + 1,
+				TxPackets: testStatBaseValue + 4,
+				RxDropped: testStatBaseValue + 5,
 			},
 		},
 		Stats: types.Stats{
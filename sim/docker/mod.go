@@ -1,13 +1,16 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/buger/goterm"
@@ -17,8 +20,10 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	"go.dedis.ch/simnet/daemon"
+	"go.dedis.ch/simnet/metrics"
 	"go.dedis.ch/simnet/network"
 	"go.dedis.ch/simnet/sim"
 	"golang.org/x/xerrors"
@@ -52,6 +57,8 @@ const (
 
 var (
 	monitorNetEmulatorCommand = []string{"./netem", "-log", "/dev/stdout"}
+	monitorPingCommand        = []string{"ping", "-q", "-c"}
+	monitorNetworkCommand     = []string{"./monitor", "-collector", "network", "-output", "/dev/stdout"}
 )
 
 // Event is the json encoded events sent when pulling an image.
@@ -70,6 +77,7 @@ type IO interface {
 	sim.IO
 
 	monitorContainers(context.Context, []types.Container) (func(), error)
+	snapshots() map[string]metrics.Snapshot
 }
 
 // Strategy implements the strategy interface for running simulations inside a
@@ -104,7 +112,7 @@ func NewStrategy(opts ...sim.Option) (*Strategy, error) {
 		out:        os.Stdout,
 		cli:        cli,
 		vpn:        newDockerOpenVPN(cli, os.Stdout, options),
-		dio:        newDockerIO(cli),
+		dio:        newDockerIO(cli, options),
 		options:    options,
 		containers: make([]types.Container, 0),
 	}, nil
@@ -383,6 +391,11 @@ func (s *Strategy) Deploy(ctx context.Context, round sim.Round) error {
 		return xerrors.Errorf("couldn't configure the containers: %v", err)
 	}
 
+	err = sim.Verify(s.options, s.makeExecutionContext(), s.makeProbe(ctx), s.out)
+	if err != nil {
+		return xerrors.Errorf("couldn't verify the topology: %v", err)
+	}
+
 	err = s.vpn.Deploy()
 	if err != nil {
 		return xerrors.Errorf("couldn't deploy the vpn: %v", err)
@@ -399,6 +412,89 @@ func (s *Strategy) Deploy(ctx context.Context, round sim.Round) error {
 	return nil
 }
 
+// makeProbe returns a probe that runs the ping command in a monitor container
+// sharing the network of the source node, so that the packets go through the
+// emulation of the node.
+func (s *Strategy) makeProbe(ctx context.Context) sim.Probe {
+	return func(src, dst sim.NodeInfo, count int) (sim.PingResult, error) {
+		var source *types.Container
+		for i, c := range s.containers {
+			if containerName(c) == src.Name {
+				source = &s.containers[i]
+			}
+		}
+
+		if source == nil {
+			return sim.PingResult{}, xerrors.Errorf("unknown node '%s'", src.Name)
+		}
+
+		cmd := append(append([]string{}, monitorPingCommand...), strconv.Itoa(count), dst.Address)
+
+		cfg := &container.Config{
+			AttachStdout: true,
+			AttachStderr: true,
+			Image:        fmt.Sprintf("%s:%s", ImageMonitor, daemon.Version),
+			Entrypoint:   cmd,
+		}
+
+		hcfg := &container.HostConfig{
+			AutoRemove:  true,
+			NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", source.ID)),
+		}
+
+		resp, err := s.cli.ContainerCreate(ctx, cfg, hcfg, nil, "")
+		if err != nil {
+			return sim.PingResult{}, xerrors.Errorf("couldn't create ping container: %v", err)
+		}
+
+		conn, err := s.cli.ContainerAttach(ctx, resp.ID, types.ContainerAttachOptions{
+			Stream: true,
+			Stdout: true,
+			Stderr: true,
+		})
+		if err != nil {
+			err = xerrors.Errorf("failed attaching container: %v", err)
+			return sim.PingResult{}, removeContainer(s.cli, resp.ID, err)
+		}
+
+		defer conn.Close()
+
+		err = s.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
+		if err != nil {
+			err = xerrors.Errorf("couldn't start ping container: %v", err)
+			return sim.PingResult{}, removeContainer(s.cli, resp.ID, err)
+		}
+
+		// The stream is closed when the container stops. The exit code is not
+		// checked as ping fails when every request is lost.
+		out := new(bytes.Buffer)
+		_, err = stdcopy.StdCopy(out, ioutil.Discard, conn.Reader)
+		if err != nil {
+			return sim.PingResult{}, xerrors.Errorf("couldn't read the output: %v", err)
+		}
+
+		res, err := sim.ParsePing(out.String())
+		if err != nil {
+			return sim.PingResult{}, xerrors.Errorf("couldn't parse the output: %v", err)
+		}
+
+		return res, nil
+	}
+}
+
+// removeContainer forces the removal of a container that failed before it
+// started, as the automatic removal only happens when it stops. It returns the
+// error of the failure, completed with the one of the removal if any.
+func removeContainer(cli client.APIClient, id string, err error) error {
+	// The context of the failure might be done already.
+	rmErr := cli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+	if rmErr != nil {
+		return xerrors.Errorf("%v: couldn't remove container: %v", err, rmErr)
+	}
+
+	return err
+}
+
 func (s *Strategy) makeExecutionContext() []sim.NodeInfo {
 	nodes := make([]sim.NodeInfo, len(s.containers))
 	for i, container := range s.containers {
@@ -471,6 +567,17 @@ func (s *Strategy) Execute(ctx context.Context, round sim.Round) error {
 
 	defer closer()
 
+	if s.options.LiveAddr != "" {
+		live, err := sim.StartLive(s.options.LiveAddr, s.dio.snapshots)
+		if err != nil {
+			return xerrors.Errorf("couldn't serve live metrics: %v", err)
+		}
+
+		defer live.Close()
+
+		fmt.Fprintf(s.out, "Live metrics available at http://%v\n", live.Addr())
+	}
+
 	// Listen for the container logs and write the output in separate files
 	// for each of them in the output folder.
 	err = s.streamLogs(ctx)
@@ -505,6 +612,13 @@ func (s *Strategy) WriteStats(ctx context.Context, filename string) error {
 		return xerrors.Errorf("failed fetching stats: %v", err)
 	}
 
+	err = sim.WriteTopology(s.options.OutputDir, s.options.Topology)
+	if err != nil {
+		// Custom topologies might not have a representation so the user is
+		// only warned.
+		fmt.Fprintf(s.out, "Couldn't write the topology: %v\n", err)
+	}
+
 	fmt.Fprintln(s.out, "Write statistics... Done.")
 
 	return nil
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,6 +11,9 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,9 +22,11 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/simnet/daemon"
+	"go.dedis.ch/simnet/metrics"
 	snet "go.dedis.ch/simnet/network"
 	"go.dedis.ch/simnet/sim"
 )
@@ -168,6 +174,76 @@ func TestStrategy_DeployVPNError(t *testing.T) {
 	require.EqualError(t, err, "couldn't deploy the vpn: oops")
 }
 
+func TestStrategy_DeployVerify(t *testing.T) {
+	client := &testClient{numContainers: 3}
+	s, clean := newTestStrategyWithClient(t, client)
+	defer clean()
+
+	s.Option(sim.WithVerification(0, 0.1, true))
+
+	client.bufferAttach = makeTestOutput(t, "3 packets transmitted, 3 packets received, 0% packet loss\n"+
+		"round-trip min/avg/max = 0.100/0.200/0.300 ms\n")
+
+	err := s.Deploy(context.Background(), &testRound{})
+	require.NoError(t, err)
+
+	// One ping container for each of the 3 pairs, in any order.
+	require.Len(t, client.callsContainerCreate, 3*3)
+	modes := make(map[string]container.NetworkMode)
+	for _, call := range client.callsContainerCreate[6:] {
+		modes[strings.Join(call.cfg.Entrypoint, " ")] = call.hcfg.NetworkMode
+	}
+	require.Equal(t, container.NetworkMode("container:id:node0"), modes["ping -q -c 5 ip:node1"])
+
+	data, err := ioutil.ReadFile(filepath.Join(s.options.OutputDir, sim.VerificationFile))
+	require.NoError(t, err)
+
+	var report sim.VerificationReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Pairs, 3)
+	require.Equal(t, 200*time.Microsecond, report.Pairs[0].MeasuredRTT)
+
+	client.bufferAttach = makeTestOutput(t, "3 packets transmitted, 0 packets received, 100% packet loss\n")
+	err = s.Deploy(context.Background(), &testRound{})
+	require.EqualError(t, err, "couldn't verify the topology: 3 of 3 pairs beyond the tolerance")
+
+	client.bufferAttach = nil
+	s.options.Verify.Strict = false
+	err = s.Deploy(context.Background(), &testRound{})
+	require.NoError(t, err)
+	require.Empty(t, client.callsContainerRemove)
+}
+
+func TestStrategy_ProbeFailures(t *testing.T) {
+	client := &testClient{numContainers: 3}
+	s, clean := newTestStrategyWithClient(t, client)
+	defer clean()
+
+	err := s.Deploy(context.Background(), &testRound{})
+	require.NoError(t, err)
+
+	probe := s.makeProbe(context.Background())
+	src := sim.NodeInfo{Name: "node0"}
+	dst := sim.NodeInfo{Name: "node1", Address: "ip:node1"}
+
+	_, err = probe(sim.NodeInfo{Name: "unknown"}, dst, 1)
+	require.EqualError(t, err, "unknown node 'unknown'")
+
+	// The container is removed as it has never started.
+	e := errors.New("attach error")
+	client.errContainerAttach = e
+	_, err = probe(src, dst, 1)
+	require.EqualError(t, err, "failed attaching container: attach error")
+	require.Equal(t, []string{"id:"}, client.callsContainerRemove)
+
+	client.resetErrors()
+	client.errContainerStart = errors.New("start error")
+	client.errContainerRemove = errors.New("remove error")
+	_, err = probe(src, dst, 1)
+	require.EqualError(t, err, "couldn't start ping container: start error: couldn't remove container: remove error")
+	require.Len(t, client.callsContainerRemove, 2)
+}
+
 func TestStrategy_PullImageFailures(t *testing.T) {
 	client := &testClient{numContainers: 3}
 	s, clean := newTestStrategyWithClient(t, client)
@@ -357,6 +433,25 @@ func TestStrategy_Execute(t *testing.T) {
 	}
 }
 
+func TestStrategy_ExecuteLive(t *testing.T) {
+	client := &testClient{numContainers: 3}
+	s, clean := newTestStrategyWithClient(t, client)
+	defer clean()
+
+	out := new(bytes.Buffer)
+	s.out = out
+	s.options.LiveAddr = "127.0.0.1:0"
+
+	err := s.Execute(context.Background(), &testRound{})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "Live metrics available at http://127.0.0.1:")
+
+	s.options.LiveAddr = "invalid:address:"
+	err = s.Execute(context.Background(), &testRound{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't serve live metrics: ")
+}
+
 func TestStrategy_ExecuteFailure(t *testing.T) {
 	client := &testClient{numContainers: 3}
 	s, clean := newTestStrategyWithClient(t, client)
@@ -569,6 +664,7 @@ type testCallEvents struct {
 
 type testClient struct {
 	*client.Client
+	sync.Mutex
 	numContainers int
 
 	callsImagePull       []testCallPullImage
@@ -576,10 +672,12 @@ type testClient struct {
 	callsContainerAttach []testCallContainerAttach
 	callsContainerStart  []testCallContainerStart
 	callsContainerStop   []testCallContainerStop
+	callsContainerRemove []string
 	callsContainerList   []testCallContainerList
 	callsEvents          []testCallEvents
 
 	bufferPullImage *bytes.Buffer
+	bufferAttach    []byte
 
 	// Don't forget to update the reset function when adding new errors.
 	errImagePull       error
@@ -587,6 +685,7 @@ type testClient struct {
 	errContainerAttach error
 	errContainerStart  error
 	errContainerStop   error
+	errContainerRemove error
 	errContainerList   error
 	errContainerLogs   error
 	errAttachConn      error
@@ -600,6 +699,7 @@ func (c *testClient) resetErrors() {
 	c.errContainerAttach = nil
 	c.errContainerStart = nil
 	c.errContainerStop = nil
+	c.errContainerRemove = nil
 	c.errContainerList = nil
 	c.errContainerLogs = nil
 	c.errAttachConn = nil
@@ -621,12 +721,19 @@ func (c *testClient) ImagePull(ctx context.Context, ref string, opts types.Image
 }
 
 func (c *testClient) ContainerCreate(ctx context.Context, cfg *container.Config, hcfg *container.HostConfig, ncfg *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+	// The pairs of the verification are probed in parallel.
+	c.Lock()
+	defer c.Unlock()
+
 	c.callsContainerCreate = append(c.callsContainerCreate, testCallContainerCreate{ctx, cfg, hcfg, ncfg, name})
 
 	return container.ContainerCreateCreatedBody{ID: fmt.Sprintf("id:%s", name)}, c.errContainerCreate
 }
 
 func (c *testClient) ContainerAttach(ctx context.Context, id string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	c.Lock()
+	defer c.Unlock()
+
 	buffer := new(bytes.Buffer)
 	c.callsContainerAttach = append(c.callsContainerAttach, testCallContainerAttach{ctx, id, options, buffer})
 
@@ -635,15 +742,43 @@ func (c *testClient) ContainerAttach(ctx context.Context, id string, options typ
 		err:    c.errAttachConn,
 	}
 
-	return types.HijackedResponse{Conn: conn}, c.errContainerAttach
+	resp := types.HijackedResponse{Conn: conn}
+	if !options.Stdin {
+		// Only the output is attached thus it is read from the test data.
+		resp.Reader = bufio.NewReader(bytes.NewReader(c.bufferAttach))
+	}
+
+	return resp, c.errContainerAttach
+}
+
+// makeTestOutput returns the output multiplexed as the one of an attached
+// container.
+func makeTestOutput(t *testing.T, out string) []byte {
+	buffer := new(bytes.Buffer)
+	_, err := stdcopy.NewStdWriter(buffer, stdcopy.Stdout).Write([]byte(out))
+	require.NoError(t, err)
+
+	return buffer.Bytes()
 }
 
 func (c *testClient) ContainerStart(ctx context.Context, id string, options types.ContainerStartOptions) error {
+	c.Lock()
+	defer c.Unlock()
+
 	c.callsContainerStart = append(c.callsContainerStart, testCallContainerStart{ctx, id, options})
 
 	return c.errContainerStart
 }
 
+func (c *testClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.callsContainerRemove = append(c.callsContainerRemove, id)
+
+	return c.errContainerRemove
+}
+
 func makeTestContainer(id string) types.Container {
 	return types.Container{
 		Names: []string{fmt.Sprintf("/%s", id[3:])},
@@ -729,6 +864,10 @@ func (dio testDockerIO) monitorContainers(context.Context, []types.Container) (f
 	return func() {}, nil
 }
 
+func (dio testDockerIO) snapshots() map[string]metrics.Snapshot {
+	return map[string]metrics.Snapshot{"node0": {Timestamp: 1}}
+}
+
 const (
 	testImage         = "path/to/image"
 	testStatBaseValue = uint64(123)
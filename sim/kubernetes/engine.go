@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -100,6 +101,8 @@ var (
 type engine interface {
 	GetTags() map[int64]string
 	Tag(name string)
+	Record(metric, node string, value float64)
+	Observe(metric, node string, value float64)
 	CreateDeployment() (watch.Interface, error)
 	WaitDeployment(watch.Interface) error
 	FetchPods() ([]apiv1.Pod, error)
@@ -114,6 +117,7 @@ type engine interface {
 	Read(pod, path string) (io.ReadCloser, error)
 	Write(node, path string, content io.Reader) error
 	Exec(node string, cmd []string, options sim.ExecOptions) error
+	Ping(src, dst sim.NodeInfo, count int) (sim.PingResult, error)
 	Disconnect(string, ...string) error
 	Reconnect(string) error
 	FetchStats(start, end time.Time, filename string) error
@@ -129,6 +133,7 @@ type kubeEngine struct {
 	kio           IO
 	pods          []apiv1.Pod
 	tags          map[int64]string
+	recorder      *metrics.Recorder
 	streamingLogs bool
 	wgLogs        sync.WaitGroup
 	makeEncoder   func(io.Writer) Encoder
@@ -163,6 +168,7 @@ func newKubeEngine(config *rest.Config, ns string, options *sim.Options) (*kubeE
 			config:     config,
 		},
 		tags:        make(map[int64]string),
+		recorder:    metrics.NewRecorder(),
 		makeEncoder: makeJSONEncoder,
 	}, nil
 }
@@ -180,6 +186,52 @@ func (kd *kubeEngine) Tag(name string) {
 	kd.tags[key] = name
 }
 
+// Record saves a sample of the custom metric for the node, or for the whole
+// simulation when the node is empty.
+func (kd *kubeEngine) Record(metric, node string, value float64) {
+	kd.recorder.Record(metric, node, value)
+}
+
+// Observe adds the value to the histogram of the custom metric for the node,
+// or for the whole simulation when the node is empty.
+func (kd *kubeEngine) Observe(metric, node string, value float64) {
+	kd.recorder.Observe(metric, node, value)
+}
+
+// makeMonitorArgs returns the arguments of the monitor of the node which also
+// scrapes the application when requested. The monitor shares the network of
+// the pod so the application is reachable on the loopback interface.
+func (kd *kubeEngine) makeMonitorArgs(node network.Node) []string {
+	args := []string{
+		"--container",
+		fmt.Sprintf("simnet-%s", node),
+	}
+
+	if kd.options.Monitor != nil {
+		if kd.options.Monitor.Interval > 0 {
+			args = append(args, "--interval", kd.options.Monitor.Interval.String())
+		}
+
+		if len(kd.options.Monitor.Metrics) > 0 {
+			args = append(args, "--metrics", strings.Join(kd.options.Monitor.Metrics, ","))
+		}
+
+		if kd.options.Monitor.Collector != "" {
+			args = append(args, "--collector", kd.options.Monitor.Collector)
+		}
+	}
+
+	if kd.options.Scrape != nil {
+		args = append(args, "--scrape", kd.options.Scrape.URL("127.0.0.1"))
+
+		for _, series := range kd.options.Scrape.Series {
+			args = append(args, "--series", series)
+		}
+	}
+
+	return args
+}
+
 func (kd *kubeEngine) makeContainer() apiv1.Container {
 	pp := make([]apiv1.ContainerPort, len(kd.options.Ports))
 	for i, port := range kd.options.Ports {
@@ -237,7 +289,7 @@ func (kd *kubeEngine) CreateDeployment() (watch.Interface, error) {
 	for _, node := range kd.options.Topology.GetNodes() {
 		deployment := kd.makeDeployment(node, kd.makeContainer())
 
-		if cloud, ok := kd.options.Topology.(network.CloudTopology); ok {
+		if cloud, ok := findCloudTopology(kd.options.Topology); ok {
 			kd.fillNodeSelector(cloud.NodeSelectorKey, node.NodeSelector, deployment)
 		}
 
@@ -703,7 +755,12 @@ func (kd *kubeEngine) ReadStats(pod string, start, end time.Time) (metrics.NodeS
 			xerrors.Errorf("failed reading stats: %v", err)
 	}
 
-	ns := metrics.NewNodeStats(reader, start, end)
+	defer reader.Close()
+
+	ns, err := metrics.NewNodeStats(reader, start, end)
+	if err != nil {
+		return metrics.NodeStats{}, xerrors.Errorf("failed reading stats: %v", err)
+	}
 
 	return ns, nil
 }
@@ -773,6 +830,34 @@ func (kd *kubeEngine) Exec(node string, cmd []string, options sim.ExecOptions) e
 	return nil
 }
 
+// Ping measures the round-trip time and the loss from the source node to the
+// destination by running the ping command in the monitor container of the
+// source, which shares the network of the application.
+func (kd *kubeEngine) Ping(src, dst sim.NodeInfo, count int) (sim.PingResult, error) {
+	pod, ok := kd.findPod(src.Name)
+	if !ok {
+		return sim.PingResult{}, xerrors.Errorf("unknown node '%s'", src.Name)
+	}
+
+	cmd := []string{"ping", "-q", "-c", strconv.Itoa(count), dst.Address}
+	out := new(bytes.Buffer)
+
+	// The error is ignored when the output can be parsed as ping fails when
+	// every request is lost.
+	err := kd.kio.Exec(pod.Name, ContainerMonitorName, cmd, sim.ExecOptions{Stdout: out})
+
+	res, perr := sim.ParsePing(out.String())
+	if perr != nil {
+		if err != nil {
+			return sim.PingResult{}, xerrors.Errorf("couldn't execute command: %v", err)
+		}
+
+		return sim.PingResult{}, xerrors.Errorf("couldn't parse the output: %v", perr)
+	}
+
+	return res, nil
+}
+
 func (kd *kubeEngine) Disconnect(src string, targets ...string) error {
 	cmds := make([]string, 0, len(targets))
 	for _, target := range targets {
@@ -822,10 +907,18 @@ func (kd *kubeEngine) Reconnect(node string) error {
 }
 
 func (kd *kubeEngine) FetchStats(start, end time.Time, filename string) error {
-	stats := metrics.Stats{
-		Timestamp: start.Unix(),
-		Tags:      kd.GetTags(),
-		Nodes:     make(map[string]metrics.NodeStats),
+	stats := metrics.NewStats()
+	stats.Timestamp = start.Unix()
+	stats.Tags = kd.GetTags()
+	stats.Parameters = kd.options.Parameters()
+	stats.Parameters["strategy"] = "kubernetes"
+
+	kd.recorder.Fill(&stats)
+
+	// The monitor only knows the addresses of the peers.
+	names := make(map[string]string)
+	for _, pod := range kd.pods {
+		names[pod.Status.PodIP] = pod.Labels[LabelNode]
 	}
 
 	for _, pod := range kd.pods {
@@ -835,6 +928,8 @@ func (kd *kubeEngine) FetchStats(start, end time.Time, filename string) error {
 				pod.Name, err)
 		}
 
+		ns.ResolvePeers(names)
+
 		name := pod.Labels[LabelNode]
 
 		stats.Nodes[name] = ns
@@ -892,15 +987,29 @@ func (kd *kubeEngine) makeDeployment(node network.Node, container apiv1.Containe
 		LabelNode: node.String(),
 	}
 
-	volumes := []apiv1.Volume{
-		{
+	volumes := []apiv1.Volume{}
+	mounts := []apiv1.VolumeMount{}
+	capabilities := []apiv1.Capability{"NET_ADMIN"}
+
+	// The cgroup collector finds the application in the processes of the pod
+	// and reads its cgroup hierarchy, whereas the default one asks Docker.
+	cgroup := kd.options.Monitor != nil && kd.options.Monitor.Collector == sim.CollectorCgroup
+	if cgroup {
+		capabilities = append(capabilities, "SYS_PTRACE")
+	} else {
+		volumes = append(volumes, apiv1.Volume{
 			Name: "dockersocket",
 			VolumeSource: apiv1.VolumeSource{
 				HostPath: &apiv1.HostPathVolumeSource{
 					Path: "/var/run/docker.sock",
 				},
 			},
-		},
+		})
+
+		mounts = append(mounts, apiv1.VolumeMount{
+			Name:      "dockersocket",
+			MountPath: "/var/run/docker.sock",
+		})
 	}
 
 	for i, tmpfs := range kd.options.TmpFS {
@@ -931,26 +1040,19 @@ func (kd *kubeEngine) makeDeployment(node network.Node, container apiv1.Containe
 					Labels: labels,
 				},
 				Spec: apiv1.PodSpec{
-					Hostname: node.String(),
+					Hostname:              node.String(),
+					ShareProcessNamespace: &cgroup,
 					Containers: []apiv1.Container{
 						container,
 						{
 							Name:  ContainerMonitorName,
 							Image: fmt.Sprintf("dedis/simnet-monitor:%s", daemon.Version),
 							// ImagePullPolicy: "Never",
-							Args: []string{
-								"--container",
-								fmt.Sprintf("simnet-%s", node),
-							},
-							VolumeMounts: []apiv1.VolumeMount{
-								{
-									Name:      "dockersocket",
-									MountPath: "/var/run/docker.sock",
-								},
-							},
+							Args:         kd.makeMonitorArgs(node),
+							VolumeMounts: mounts,
 							SecurityContext: &apiv1.SecurityContext{
 								Capabilities: &apiv1.Capabilities{
-									Add: []apiv1.Capability{"NET_ADMIN"},
+									Add: capabilities,
 								},
 							},
 							Resources: apiv1.ResourceRequirements{
@@ -968,6 +1070,21 @@ func (kd *kubeEngine) makeDeployment(node network.Node, container apiv1.Containe
 	}
 }
 
+// findCloudTopology returns the cloud topology of the simulation if any, even
+// when it is modified by another topology.
+func findCloudTopology(topo network.Topology) (network.CloudTopology, bool) {
+	for {
+		switch t := topo.(type) {
+		case network.CloudTopology:
+			return t, true
+		case network.ModifiedTopology:
+			topo = t.Unwrap()
+		default:
+			return network.CloudTopology{}, false
+		}
+	}
+}
+
 func (kd *kubeEngine) fillNodeSelector(key string, value string, cfg *appsv1.Deployment) {
 	selectors := map[string]string{
 		key: value,
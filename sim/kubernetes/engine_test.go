@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
 	"go.dedis.ch/simnet/network"
 	"go.dedis.ch/simnet/sim"
 	"golang.org/x/xerrors"
@@ -96,6 +97,42 @@ func TestEngine_CreateDeployments(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestEngine_MakeDeploymentCollector(t *testing.T) {
+	engine, _ := makeEngine(1)
+	node := network.Node{Name: "node0"}
+
+	deployment := engine.makeDeployment(node, engine.makeContainer())
+	spec := deployment.Spec.Template.Spec
+	require.False(t, *spec.ShareProcessNamespace)
+	require.Len(t, spec.Volumes, 1)
+	require.Equal(t, "/var/run/docker.sock", spec.Containers[1].VolumeMounts[0].MountPath)
+
+	sim.WithCollector(sim.CollectorCgroup)(engine.options)
+
+	deployment = engine.makeDeployment(node, engine.makeContainer())
+	spec = deployment.Spec.Template.Spec
+	require.True(t, *spec.ShareProcessNamespace)
+	require.Empty(t, spec.Volumes)
+	require.Empty(t, spec.Containers[1].VolumeMounts)
+	require.Contains(t, spec.Containers[1].SecurityContext.Capabilities.Add, apiv1.Capability("SYS_PTRACE"))
+	require.Contains(t, spec.Containers[1].Args, "cgroup")
+}
+
+func TestEngine_FindCloudTopology(t *testing.T) {
+	cloud := network.NewCloudTopology("key", []string{"A"})
+
+	res, ok := findCloudTopology(cloud)
+	require.True(t, ok)
+	require.Equal(t, cloud, res)
+
+	res, ok = findCloudTopology(network.NewModifiedTopology(cloud))
+	require.True(t, ok)
+	require.Equal(t, cloud, res)
+
+	_, ok = findCloudTopology(network.NewSimpleTopology(1, 0))
+	require.False(t, ok)
+}
+
 func TestEngine_CreateDeploymentFailure(t *testing.T) {
 	n := 3
 	engine, client := makeEngine(n)
@@ -693,6 +730,90 @@ func TestEngine_ReadStats(t *testing.T) {
 	require.Equal(t, e, err)
 }
 
+func TestEngine_MakeMonitorArgs(t *testing.T) {
+	engine := &kubeEngine{options: &sim.Options{}}
+
+	node := network.Node{Name: "node0"}
+	require.Equal(t, []string{"--container", "simnet-node0"}, engine.makeMonitorArgs(node))
+
+	sim.WithPrometheus(8080, "/metrics", "a", "b")(engine.options)
+	require.Equal(t, []string{
+		"--container", "simnet-node0",
+		"--scrape", "http://127.0.0.1:8080/metrics",
+		"--series", "a",
+		"--series", "b",
+	}, engine.makeMonitorArgs(node))
+
+	engine.options.Scrape = nil
+	sim.WithMonitor(500*time.Millisecond, "pids", "memory")(engine.options)
+	require.Equal(t, []string{
+		"--container", "simnet-node0",
+		"--interval", "500ms",
+		"--metrics", "pids,memory",
+	}, engine.makeMonitorArgs(node))
+
+	sim.WithMonitor(0)(engine.options)
+	require.Equal(t, []string{"--container", "simnet-node0"}, engine.makeMonitorArgs(node))
+
+	sim.WithCollector(sim.CollectorCgroup)(engine.options)
+	require.Equal(t, []string{
+		"--container", "simnet-node0",
+		"--collector", "cgroup",
+	}, engine.makeMonitorArgs(node))
+}
+
+func TestEngine_FetchStats(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "simnet-engine-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kio := newTestKIO()
+	kio.buffer.WriteString("5,1,2,3,4,5,6,7,8,9,10,11;10.0.0.2,100,200;10.0.0.3,10,20\n")
+
+	engine := &kubeEngine{
+		pods: []apiv1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod0", Labels: map[string]string{LabelNode: "node0"}},
+				Status:     apiv1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{LabelNode: "node1"}},
+				Status:     apiv1.PodStatus{PodIP: "10.0.0.2"},
+			},
+		},
+		kio:      kio,
+		recorder: metrics.NewRecorder(),
+		options:  &sim.Options{Image: "nginx"},
+	}
+
+	engine.Record("height", "node0", 42)
+	engine.Observe("latency", "", 1)
+
+	filename := filepath.Join(dir, "stats.json")
+	err = engine.FetchStats(time.Unix(0, 0), time.Unix(10, 0), filename)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	stats := metrics.Stats{}
+	require.NoError(t, json.Unmarshal(data, &stats))
+	require.Equal(t, metrics.StatsVersion, stats.Version)
+	require.Equal(t, metrics.UnitCentiPercent, stats.Units[metrics.ColumnCPU])
+	require.Equal(t, "kubernetes", stats.Parameters["strategy"])
+	require.Equal(t, "nginx", stats.Parameters["image"])
+	require.Len(t, stats.Nodes, 2)
+	require.Equal(t, []uint64{11}, stats.Nodes["node0"].Retransmissions)
+	require.Equal(t, []uint64{100}, stats.Nodes["node0"].Peers["node1"].RxBytes)
+	require.Equal(t, []uint64{20}, stats.Nodes["node0"].Peers["10.0.0.3"].TxBytes)
+	require.Equal(t, 42.0, stats.Metrics["height"].Nodes["node0"][0].Value)
+	require.Equal(t, uint64(1), stats.Histograms["latency"].Histogram.Count)
+
+	kio.err = errors.New("oops")
+	err = engine.FetchStats(time.Unix(0, 0), time.Unix(10, 0), filename)
+	require.EqualError(t, err, "failed reading stats from pod 'pod0': failed reading stats: oops")
+}
+
 func TestEngine_Read(t *testing.T) {
 	engine := &kubeEngine{
 		pods: []apiv1.Pod{
@@ -785,6 +906,44 @@ func TestEngine_ExecFailures(t *testing.T) {
 	require.True(t, errors.Is(err, e))
 }
 
+func TestEngine_Ping(t *testing.T) {
+	kio := newTestKIO()
+	engine := &kubeEngine{
+		pods: []apiv1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelNode: "node0"}}},
+		},
+		kio: kio,
+	}
+
+	kio.bout = bytes.NewBufferString("2 packets transmitted, 1 received, 50% packet loss, time 1001ms\n" +
+		"rtt min/avg/max/mdev = 10.000/10.500/11.000/0.500 ms\n")
+
+	src := sim.NodeInfo{Name: "node0"}
+	dst := sim.NodeInfo{Name: "node1", Address: "10.0.0.2"}
+
+	res, err := engine.Ping(src, dst, 2)
+	require.NoError(t, err)
+	require.Equal(t, sim.PingResult{RTT: 10500 * time.Microsecond, Loss: 0.5}, res)
+
+	// Every request is lost thus the command fails.
+	kio.bout = bytes.NewBufferString("2 packets transmitted, 0 received, 100% packet loss, time 1001ms\n")
+	kio.err = xerrors.New("exit code 1")
+	res, err = engine.Ping(src, dst, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, res.Loss)
+
+	kio.bout = new(bytes.Buffer)
+	_, err = engine.Ping(src, dst, 2)
+	require.EqualError(t, err, "couldn't execute command: exit code 1")
+
+	kio.err = nil
+	_, err = engine.Ping(src, dst, 2)
+	require.EqualError(t, err, "couldn't parse the output: missing statistics")
+
+	_, err = engine.Ping(dst, src, 2)
+	require.EqualError(t, err, "unknown node 'node1'")
+}
+
 func TestEngine_Disconnect(t *testing.T) {
 	kio := newTestKIO()
 	engine := &kubeEngine{
@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.dedis.ch/simnet/metrics"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// LiveInterval is the amount of time between two reads of the data files
+	// of the monitors when the live metrics are served.
+	LiveInterval = 5 * time.Second
+	// LiveConcurrency is the maximum number of data files read at the same
+	// time.
+	LiveConcurrency = 16
+)
+
+// liveReader periodically reads the data files of the monitors to keep the
+// latest resource usage of the nodes.
+type liveReader struct {
+	sync.Mutex
+	engine    engine
+	pods      []apiv1.Pod
+	start     time.Time
+	snapshots map[string]metrics.Snapshot
+}
+
+func newLiveReader(e engine, pods []apiv1.Pod, start time.Time) *liveReader {
+	return &liveReader{
+		engine:    e,
+		pods:      pods,
+		start:     start,
+		snapshots: make(map[string]metrics.Snapshot),
+	}
+}
+
+// read updates the snapshots of the nodes. The data files are read in
+// parallel as each of them is copied from its pod. A node that cannot be read
+// keeps its previous snapshot as the next read is likely to succeed.
+func (lr *liveReader) read() {
+	sem := make(chan struct{}, LiveConcurrency)
+	wg := sync.WaitGroup{}
+
+	for _, pod := range lr.pods {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(pod apiv1.Pod) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			lr.readPod(pod)
+		}(pod)
+	}
+
+	wg.Wait()
+}
+
+// readPod updates the snapshot of the node of the pod.
+func (lr *liveReader) readPod(pod apiv1.Pod) {
+	ns, err := lr.engine.ReadStats(pod.Name, lr.start, time.Now())
+	if err != nil {
+		return
+	}
+
+	snap, ok := ns.Snapshot()
+	if !ok {
+		return
+	}
+
+	lr.Lock()
+	lr.snapshots[pod.Labels[LabelNode]] = snap
+	lr.Unlock()
+}
+
+// run reads the data files at each interval until the context is done.
+func (lr *liveReader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lr.read()
+		}
+	}
+}
+
+// Snapshots returns a copy of the latest snapshots of the nodes.
+func (lr *liveReader) Snapshots() map[string]metrics.Snapshot {
+	lr.Lock()
+	defer lr.Unlock()
+
+	snapshots := make(map[string]metrics.Snapshot, len(lr.snapshots))
+	for node, snap := range lr.snapshots {
+		snapshots[node] = snap
+	}
+
+	return snapshots
+}
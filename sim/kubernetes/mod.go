@@ -60,6 +60,9 @@ type Strategy struct {
 	executeTime time.Time
 	doneTime    time.Time
 	makeEncoder func(io.Writer) Encoder
+	// out is the writer of the messages for the user, which is the one of
+	// the engine.
+	out io.Writer
 
 	// A step of the simulation will fetch some information like the list of
 	// pods and announce that it is updated for the next steps.
@@ -102,6 +105,7 @@ func NewStrategy(cfg string, opts ...sim.Option) (*Strategy, error) {
 		namespace:   namespace,
 		options:     options,
 		makeEncoder: makeJSONEncoder,
+		out:         engine.writer,
 	}, nil
 }
 
@@ -141,6 +145,11 @@ func (s *Strategy) Deploy(ctx context.Context, round sim.Round) error {
 
 	s.pods = pods
 
+	err = sim.Verify(s.options, s.makeContext(), s.engine.Ping, s.out)
+	if err != nil {
+		return xerrors.Errorf("failed verifying topology: %v", err)
+	}
+
 	w, err = s.engine.DeployRouter(pods)
 	if err != nil {
 		return xerrors.Errorf("failed deploying router: %v", err)
@@ -206,6 +215,15 @@ func (s *Strategy) Execute(ctx context.Context, round sim.Round) error {
 
 	s.executeTime = time.Now()
 
+	if s.options.LiveAddr != "" {
+		closer, err := s.serveLive(ctx)
+		if err != nil {
+			return xerrors.Errorf("couldn't serve live metrics: %v", err)
+		}
+
+		defer closer()
+	}
+
 	nodes := s.makeContext()
 
 	err = round.Execute(s.engine, nodes)
@@ -223,6 +241,37 @@ func (s *Strategy) Execute(ctx context.Context, round sim.Round) error {
 	return nil
 }
 
+// serveLive starts the server of the live metrics which are updated by reading
+// the data files of the monitors periodically. It returns a function to stop
+// both of them.
+func (s *Strategy) serveLive(ctx context.Context) (func(), error) {
+	reader := newLiveReader(s.engine, s.pods, s.executeTime)
+	reader.read()
+
+	live, err := sim.StartLive(s.options.LiveAddr, reader.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(s.out, "Live metrics available at http://%v\n", live.Addr())
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		reader.run(ctx, LiveInterval)
+		close(done)
+	}()
+
+	closer := func() {
+		cancel()
+		<-done
+		live.Close()
+	}
+
+	return closer, nil
+}
+
 // WriteStats fetches the stats of the nodes then write them into a JSON
 // formatted file.
 func (s *Strategy) WriteStats(ctx context.Context, filename string) error {
@@ -243,6 +292,13 @@ func (s *Strategy) WriteStats(ctx context.Context, filename string) error {
 		return xerrors.Errorf("couldn't fetch the stats: %v", err)
 	}
 
+	err = sim.WriteTopology(s.options.OutputDir, s.options.Topology)
+	if err != nil {
+		// Custom topologies might not have a representation so the user is
+		// only warned.
+		fmt.Fprintf(s.out, "Couldn't write the topology: %v\n", err)
+	}
+
 	return nil
 }
 
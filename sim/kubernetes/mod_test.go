@@ -1,16 +1,20 @@
 package kubernetes
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/simnet/metrics"
+	"go.dedis.ch/simnet/network"
 	"go.dedis.ch/simnet/sim"
 	"golang.org/x/xerrors"
 	apiv1 "k8s.io/api/core/v1"
@@ -85,6 +89,45 @@ func TestStrategy_Option(t *testing.T) {
 	require.Equal(t, stry.options.VPNExecutable, "abc")
 }
 
+func TestStrategy_DeployVerify(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "simnet-kubernetes-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	deployer := &testEngine{
+		pods: []apiv1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelNode: "node0"}},
+				Status:     apiv1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelNode: "node1"}},
+				Status:     apiv1.PodStatus{PodIP: "10.0.0.2"},
+			},
+		},
+		ping: sim.PingResult{RTT: time.Millisecond},
+	}
+
+	stry := &Strategy{
+		engine: deployer,
+		options: sim.NewOptions([]sim.Option{
+			sim.WithOutput(dir),
+			sim.WithVerification(0, 0.1, true),
+		}),
+		tun: testTunnel{},
+		out: new(bytes.Buffer),
+	}
+
+	err = stry.Deploy(context.Background(), &testRound{})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, sim.VerificationFile))
+	require.Contains(t, stry.out.(*bytes.Buffer).String(), "Verifying the topology... Done.")
+
+	deployer.errPing = errors.New("oops")
+	err = stry.Deploy(context.Background(), &testRound{})
+	require.EqualError(t, err, "failed verifying topology: 1 of 1 pairs beyond the tolerance")
+}
+
 func TestStrategy_DeployWithFailures(t *testing.T) {
 	deployer := &testEngine{}
 	stry := &Strategy{
@@ -179,6 +222,81 @@ func TestStrategy_Execute(t *testing.T) {
 	require.Equal(t, "b", round.nodes[1].Name)
 }
 
+func TestStrategy_ExecuteLive(t *testing.T) {
+	stry := &Strategy{
+		pods: []apiv1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelNode: "a"}}},
+		},
+		engine: &testEngine{
+			stats: metrics.NodeStats{Timestamps: []int64{1}, CPU: []uint64{42}},
+		},
+		options: sim.NewOptions([]sim.Option{sim.WithLiveMetrics("127.0.0.1:0")}),
+		updated: true,
+		out:     new(bytes.Buffer),
+	}
+
+	closer, err := stry.serveLive(context.Background())
+	require.NoError(t, err)
+	closer()
+
+	require.Contains(t, stry.out.(*bytes.Buffer).String(), "Live metrics available at http://127.0.0.1:")
+
+	err = stry.Execute(context.Background(), &testRound{})
+	require.NoError(t, err)
+
+	stry.options.LiveAddr = "invalid:address:"
+	err = stry.Execute(context.Background(), &testRound{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't serve live metrics: ")
+}
+
+func TestLiveReader_Read(t *testing.T) {
+	e := &testEngine{
+		stats: metrics.NodeStats{Timestamps: []int64{1, 2}, RxBytes: []uint64{10, 30}},
+	}
+
+	pods := []apiv1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelNode: "a"}}},
+	}
+
+	reader := newLiveReader(e, pods, time.Now())
+	require.Empty(t, reader.Snapshots())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reader.run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return len(reader.Snapshots()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	require.Equal(t, metrics.Snapshot{Timestamp: 2, RxBytes: 30, RxRate: 20}, reader.Snapshots()["a"])
+
+	// A failing read keeps the previous snapshot.
+	e.stats = metrics.NodeStats{}
+	reader.read()
+	require.Len(t, reader.Snapshots(), 1)
+
+	e.errRead = errors.New("oops")
+	reader.read()
+	require.Equal(t, int64(2), reader.Snapshots()["a"].Timestamp)
+
+	// More pods than the number of parallel reads.
+	e.errRead = nil
+	e.stats = metrics.NodeStats{Timestamps: []int64{3}}
+	pods = make([]apiv1.Pod, LiveConcurrency*2)
+	for i := range pods {
+		pods[i].Labels = map[string]string{LabelNode: fmt.Sprintf("node%d", i)}
+	}
+
+	reader = newLiveReader(e, pods, time.Now())
+	reader.read()
+	require.Len(t, reader.Snapshots(), LiveConcurrency*2)
+}
+
 func TestStrategy_ExecuteFailure(t *testing.T) {
 	stry := &Strategy{
 		pods:    []apiv1.Pod{{}},
@@ -219,7 +337,9 @@ func TestStrategy_WriteStats(t *testing.T) {
 		makeEncoder: makeJSONEncoder,
 		options: &sim.Options{
 			OutputDir: dir,
+			Topology:  network.NewSimpleTopology(3, 0),
 		},
+		out: new(bytes.Buffer),
 	}
 
 	writer.Close()
@@ -227,6 +347,13 @@ func TestStrategy_WriteStats(t *testing.T) {
 	file := "data.json"
 	err = stry.WriteStats(context.Background(), file)
 	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, sim.TopologyFileName))
+
+	// The user is only warned when the topology cannot be written.
+	stry.options.OutputDir = filepath.Join(dir, "unknown")
+	err = stry.WriteStats(context.Background(), file)
+	require.NoError(t, err)
+	require.Contains(t, stry.out.(*bytes.Buffer).String(), "Couldn't write the topology: ")
 }
 
 func TestStrategy_Clean(t *testing.T) {
@@ -278,6 +405,10 @@ func TestWithResources(t *testing.T) {
 type testEngine struct {
 	engine
 	reader            io.ReadCloser
+	stats             metrics.NodeStats
+	pods              []apiv1.Pod
+	ping              sim.PingResult
+	errPing           error
 	errDeployment     error
 	errWaitDeployment error
 	errFetchPods      error
@@ -306,7 +437,7 @@ func (te *testEngine) WaitDeployment(watch.Interface) error {
 }
 
 func (te *testEngine) FetchPods() ([]apiv1.Pod, error) {
-	return nil, te.errFetchPods
+	return te.pods, te.errFetchPods
 }
 
 func (te *testEngine) UploadConfig() error {
@@ -338,7 +469,7 @@ func (te *testEngine) StreamLogs(context.Context) error {
 }
 
 func (te *testEngine) ReadStats(string, time.Time, time.Time) (metrics.NodeStats, error) {
-	return metrics.NodeStats{}, te.errRead
+	return te.stats, te.errRead
 }
 
 func (te *testEngine) Read(pod, path string) (io.ReadCloser, error) {
@@ -353,6 +484,10 @@ func (te *testEngine) Exec(node string, cmd []string, options sim.ExecOptions) e
 	return nil
 }
 
+func (te *testEngine) Ping(src, dst sim.NodeInfo, count int) (sim.PingResult, error) {
+	return te.ping, te.errPing
+}
+
 func (te *testEngine) FetchStats(start, end time.Time, filename string) error {
 	return nil
 }
@@ -0,0 +1,56 @@
+package sim
+
+import (
+	"net"
+	"net/http"
+
+	"go.dedis.ch/simnet/metrics"
+	"golang.org/x/xerrors"
+)
+
+// LiveServer is an HTTP server that serves the resource usage of the nodes
+// while the rounds are executed.
+type LiveServer struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+}
+
+// StartLive starts a server listening on the address that serves the
+// snapshots returned by the source.
+func StartLive(addr string, source func() map[string]metrics.Snapshot) (*LiveServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't listen: %v", err)
+	}
+
+	ls := &LiveServer{
+		listener: listener,
+		server:   &http.Server{Handler: metrics.NewLiveHandler(source)},
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		ls.server.Serve(listener)
+		close(ls.done)
+	}()
+
+	return ls, nil
+}
+
+// Addr returns the address the server is listening on.
+func (ls *LiveServer) Addr() net.Addr {
+	return ls.listener.Addr()
+}
+
+// Close stops the server and waits for it to be done.
+func (ls *LiveServer) Close() error {
+	err := ls.server.Close()
+	<-ls.done
+
+	if err != nil {
+		return xerrors.Errorf("couldn't close: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/metrics"
+)
+
+func TestLiveServer_Start(t *testing.T) {
+	source := func() map[string]metrics.Snapshot {
+		return map[string]metrics.Snapshot{"node0": {Timestamp: 1, CPU: 10}}
+	}
+
+	ls, err := StartLive("127.0.0.1:0", source)
+	require.NoError(t, err)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/nodes", ls.Addr()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	snapshots := make(map[string]metrics.Snapshot)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&snapshots))
+	require.Equal(t, source(), snapshots)
+
+	require.NoError(t, ls.Close())
+
+	_, err = http.Get(fmt.Sprintf("http://%s/api/nodes", ls.Addr()))
+	require.Error(t, err)
+
+	_, err = StartLive("invalid:address:", source)
+	require.Error(t, err)
+}
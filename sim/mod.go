@@ -19,6 +19,16 @@ type IO interface {
 	// function is called will be saved and it can be reported to the plot.
 	Tag(name string)
 
+	// Record saves a sample of a custom metric for the node, or for the whole
+	// simulation when the node is empty. The moment the function is called is
+	// saved with the value and it is written with the statistics.
+	Record(metric, node string, value float64)
+
+	// Observe adds the value to the histogram of a custom metric for the
+	// node, or for the whole simulation when the node is empty. It is written
+	// with the statistics.
+	Observe(metric, node string, value float64)
+
 	// Read reads a file on a simulation node at the given path. It returns a
 	// stream through a reader, or an error if something bad happened.
 	Read(node, path string) (io.ReadCloser, error)
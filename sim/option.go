@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.dedis.ch/simnet/network"
 )
@@ -42,7 +45,19 @@ type Options struct {
 	Ports         []Port
 	TmpFS         []TmpVolume
 	VPNExecutable string
-	Data          map[string]interface{}
+	// Verify defines the verification of the topology after the deployment,
+	// which is skipped when nil.
+	Verify *Verification
+	// Scrape defines the metrics endpoint of the application, which is not
+	// scraped when nil.
+	Scrape *Scrape
+	// Monitor defines the sampling of the monitor, which uses its defaults
+	// when nil.
+	Monitor *Monitor
+	// LiveAddr is the address of the HTTP endpoint serving the resource
+	// usage of the nodes during the execution, which is disabled when empty.
+	LiveAddr string
+	Data     map[string]interface{}
 }
 
 // NewOptions creates empty options.
@@ -183,6 +198,84 @@ func WithImage(image string, cmd, args []string, ports ...Port) Option {
 	}
 }
 
+// Scrape defines the Prometheus endpoint exposed by the application.
+type Scrape struct {
+	Port   int32
+	Path   string
+	Series []string
+}
+
+// URL returns the address of the endpoint for the given host.
+func (s Scrape) URL(host string) string {
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(host, strconv.Itoa(int(s.Port))), s.Path)
+}
+
+// WithPrometheus is an option to scrape the Prometheus endpoint exposed by
+// the application on the port and the path each time the monitor takes a
+// sample. Only the given series are stored in the statistics, which can be
+// either the name of a metric or the name followed by the labels, thus at
+// least one must be given.
+func WithPrometheus(port int32, path string, series ...string) Option {
+	return func(opts *Options) {
+		opts.Scrape = &Scrape{
+			Port:   port,
+			Path:   path,
+			Series: series,
+		}
+	}
+}
+
+const (
+	// CollectorDocker is the collector of the monitor that gets the
+	// statistics from the Docker socket of the host.
+	CollectorDocker = "docker"
+	// CollectorCgroup is the collector of the monitor that reads the cgroup
+	// of the application, which works with any container runtime.
+	CollectorCgroup = "cgroup"
+)
+
+// Monitor defines the sampling of the resource usage of the nodes.
+type Monitor struct {
+	Interval  time.Duration
+	Metrics   []string
+	Collector string
+}
+
+// WithMonitor is an option to take a sample of the resource usage at each
+// interval, and to add the extra metric sets among blkio, pids and memory.
+// It applies to the monitor daemon of the Kubernetes strategy.
+func WithMonitor(interval time.Duration, metrics ...string) Option {
+	return func(opts *Options) {
+		if opts.Monitor == nil {
+			opts.Monitor = &Monitor{}
+		}
+
+		opts.Monitor.Interval = interval
+		opts.Monitor.Metrics = metrics
+	}
+}
+
+// WithCollector is an option to change how the monitor daemon of the
+// Kubernetes strategy collects the statistics of the application.
+func WithCollector(collector string) Option {
+	return func(opts *Options) {
+		if opts.Monitor == nil {
+			opts.Monitor = &Monitor{}
+		}
+
+		opts.Monitor.Collector = collector
+	}
+}
+
+// WithLiveMetrics is an option to serve the current resource usage of the
+// nodes on the address during the execution of the rounds. The Prometheus
+// format is available at /metrics and the JSON API at /api/nodes.
+func WithLiveMetrics(addr string) Option {
+	return func(opts *Options) {
+		opts.LiveAddr = addr
+	}
+}
+
 // WithTmpFS is an option for simulation engines to mount a tmpfs at the given
 // destination.
 func WithTmpFS(destination string, size int64) Option {
@@ -193,3 +286,45 @@ func WithTmpFS(destination string, size int64) Option {
 		})
 	}
 }
+
+// Parameters returns a description of the options indexed by name, which is
+// stored with the statistics so that a run can be documented.
+func (o *Options) Parameters() map[string]string {
+	params := map[string]string{
+		"image": o.Image,
+		"cmd":   strings.Join(o.Cmd, " "),
+		"args":  strings.Join(o.Args, " "),
+	}
+
+	if o.Topology != nil {
+		params["nodes"] = strconv.Itoa(o.Topology.Len())
+	}
+
+	ports := make([]string, len(o.Ports))
+	for i, port := range o.Ports {
+		ports[i] = fmt.Sprintf("%s/%d", port.Protocol(), port.Value())
+	}
+
+	params["ports"] = strings.Join(ports, ",")
+
+	for _, vol := range o.TmpFS {
+		params["tmpfs."+vol.Destination] = strconv.FormatInt(vol.Size, 10)
+	}
+
+	if o.Monitor != nil {
+		params["monitor.interval"] = o.Monitor.Interval.String()
+		params["monitor.metrics"] = strings.Join(o.Monitor.Metrics, ",")
+		params["monitor.collector"] = o.Monitor.Collector
+	}
+
+	if o.Scrape != nil {
+		params["prometheus"] = fmt.Sprintf(":%d%s %s", o.Scrape.Port, o.Scrape.Path,
+			strings.Join(o.Scrape.Series, ","))
+	}
+
+	for key, value := range o.Data {
+		params["data."+key] = fmt.Sprintf("%v", value)
+	}
+
+	return params
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/simnet/network"
@@ -85,3 +86,67 @@ func TestOption_TmpFS(t *testing.T) {
 	require.Len(t, options.TmpFS, 1)
 	require.Equal(t, options.TmpFS[0].Destination, "/abc")
 }
+
+func TestOption_Prometheus(t *testing.T) {
+	options := &Options{}
+	WithPrometheus(8080, "/metrics", "a", "b")(options)
+
+	require.Equal(t, &Scrape{Port: 8080, Path: "/metrics", Series: []string{"a", "b"}}, options.Scrape)
+	require.Equal(t, "http://10.0.0.1:8080/metrics", options.Scrape.URL("10.0.0.1"))
+	require.Equal(t, "http://[fd00::1]:8080/metrics", options.Scrape.URL("fd00::1"))
+}
+
+func TestOption_LiveMetrics(t *testing.T) {
+	options := &Options{}
+	WithLiveMetrics(":9100")(options)
+
+	require.Equal(t, ":9100", options.LiveAddr)
+}
+
+func TestOption_Monitor(t *testing.T) {
+	options := &Options{}
+	WithMonitor(500*time.Millisecond, "blkio", "pids")(options)
+
+	require.Equal(t, &Monitor{Interval: 500 * time.Millisecond, Metrics: []string{"blkio", "pids"}}, options.Monitor)
+
+	WithCollector(CollectorCgroup)(options)
+	require.Equal(t, CollectorCgroup, options.Monitor.Collector)
+	require.Equal(t, 500*time.Millisecond, options.Monitor.Interval)
+
+	options = &Options{}
+	WithCollector(CollectorCgroup)(options)
+	WithMonitor(time.Second)(options)
+	require.Equal(t, &Monitor{Interval: time.Second, Collector: CollectorCgroup}, options.Monitor)
+}
+
+func TestOption_Parameters(t *testing.T) {
+	options := &Options{
+		Image: "nginx",
+		Cmd:   []string{"run"},
+		Args:  []string{"-a", "1"},
+		Ports: []Port{NewTCP(80), NewUDP(2000)},
+		Data:  map[string]interface{}{"memory": 10},
+	}
+
+	WithTopology(network.NewSimpleTopology(3, 0))(options)
+	WithTmpFS("/storage", 256)(options)
+	WithMonitor(time.Second, "pids")(options)
+	WithPrometheus(8080, "/metrics", "a")(options)
+
+	require.Equal(t, map[string]string{
+		"image":             "nginx",
+		"cmd":               "run",
+		"args":              "-a 1",
+		"nodes":             "3",
+		"ports":             "tcp/80,udp/2000",
+		"tmpfs./storage":    "256",
+		"monitor.interval":  "1s",
+		"monitor.metrics":   "pids",
+		"monitor.collector": "",
+		"prometheus":        ":8080/metrics a",
+		"data.memory":       "10",
+	}, options.Parameters())
+
+	require.Equal(t, map[string]string{"image": "", "cmd": "", "args": "", "ports": ""},
+		(&Options{}).Parameters())
+}
@@ -0,0 +1,45 @@
+package sim
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/xerrors"
+)
+
+// TopologyFileName is the name of the file written next to the statistics
+// that contains the topology of the simulation.
+const TopologyFileName = "topology.json"
+
+// WriteTopology writes the canonical representation of the topology in the
+// directory so that the simulation can be replayed later on.
+func WriteTopology(dir string, topo network.Topology) error {
+	data, err := network.Marshal(topo)
+	if err != nil {
+		return xerrors.Errorf("couldn't marshal topology: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, TopologyFileName), data, 0644)
+	if err != nil {
+		return xerrors.Errorf("couldn't write topology: %v", err)
+	}
+
+	return nil
+}
+
+// ReadTopology reads a topology previously written in the file. Both JSON and
+// YAML representations are supported.
+func ReadTopology(filename string) (network.Topology, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't read topology: %v", err)
+	}
+
+	topo, err := network.Unmarshal(data)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal topology: %v", err)
+	}
+
+	return topo, nil
+}
@@ -0,0 +1,57 @@
+package sim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
+)
+
+func TestTopology_WriteAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "simnet-topology")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	topo := network.NewSimpleTopology(3, 20*time.Millisecond)
+
+	err = WriteTopology(dir, topo)
+	require.NoError(t, err)
+
+	res, err := ReadTopology(filepath.Join(dir, TopologyFileName))
+	require.NoError(t, err)
+	require.Equal(t, topo, res)
+}
+
+func TestTopology_WriteFailures(t *testing.T) {
+	err := WriteTopology("", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't marshal topology: ")
+
+	err = WriteTopology("/deadbeef", network.NewSimpleTopology(1, 0))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't write topology: ")
+}
+
+func TestTopology_ReadFailures(t *testing.T) {
+	_, err := ReadTopology("/deadbeef")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't read topology: ")
+
+	f, err := ioutil.TempFile(os.TempDir(), "simnet-topology")
+	require.NoError(t, err)
+
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("{}")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = ReadTopology(f.Name())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't unmarshal topology: ")
+}
@@ -0,0 +1,396 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.dedis.ch/simnet/network"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// VerificationFile is the name of the file written in the output
+	// directory with the result of the verification.
+	VerificationFile = "verification.json"
+
+	// DefaultVerifyCount is the default number of echo requests sent for
+	// each pair of nodes.
+	DefaultVerifyCount = 5
+	// DefaultVerifyMargin is the default difference always allowed on the
+	// round-trip time, which absorbs the latency of the network itself.
+	DefaultVerifyMargin = 5 * time.Millisecond
+	// DefaultVerifyLossMargin is the default difference allowed on the loss
+	// as it is measured over a few packets only.
+	DefaultVerifyLossMargin = 0.2
+	// VerifyConcurrency is the maximum number of pairs of nodes measured at
+	// the same time.
+	VerifyConcurrency = 16
+)
+
+// Verification defines how the emulated network is checked after the
+// deployment.
+type Verification struct {
+	// Pairs is the maximum number of pairs of nodes to measure.
+	Pairs int
+	// Count is the number of echo requests sent for each pair.
+	Count int
+	// Tolerance is the relative difference allowed between the measured and
+	// the expected round-trip times.
+	Tolerance float64
+	// Margin is the absolute difference always allowed on the round-trip
+	// time.
+	Margin time.Duration
+	// LossMargin is the absolute difference allowed on the loss.
+	LossMargin float64
+	// Strict makes the deployment fail when a pair is beyond the tolerance
+	// instead of only warning.
+	Strict bool
+}
+
+// WithVerification is an option to measure the round-trip time and the loss
+// between sampled pairs of nodes after the deployment, and to compare them
+// to the topology. The tolerance is relative to the expected round-trip time
+// and a strict verification makes the deployment fail. Only the latency
+// emulated by the rules is expected, thus the pairs of nodes in different
+// regions of a cloud topology are not measured as the latency between the
+// regions is real.
+func WithVerification(pairs int, tolerance float64, strict bool) Option {
+	return func(opts *Options) {
+		opts.Verify = &Verification{
+			Pairs:      pairs,
+			Count:      DefaultVerifyCount,
+			Tolerance:  tolerance,
+			Margin:     DefaultVerifyMargin,
+			LossMargin: DefaultVerifyLossMargin,
+			Strict:     strict,
+		}
+	}
+}
+
+// PingResult is the round-trip time and the loss measured between two nodes.
+type PingResult struct {
+	RTT  time.Duration
+	Loss float64
+}
+
+// Probe measures the round-trip time and the loss from the source node to the
+// destination node by sending a number of echo requests.
+type Probe func(src, dst NodeInfo, count int) (PingResult, error)
+
+// VerifiedPair is the result of the verification of a pair of nodes.
+type VerifiedPair struct {
+	Source       string
+	Destination  string
+	ExpectedRTT  time.Duration
+	MeasuredRTT  time.Duration
+	ExpectedLoss float64
+	MeasuredLoss float64
+	Error        string
+	OK           bool
+}
+
+// VerificationReport is the content of the verification file.
+type VerificationReport struct {
+	Timestamp int64
+	Tolerance float64
+	Margin    time.Duration
+	Pairs     []VerifiedPair
+}
+
+// Failures returns the pairs that are beyond the tolerance.
+func (r VerificationReport) Failures() []VerifiedPair {
+	failures := make([]VerifiedPair, 0)
+	for _, pair := range r.Pairs {
+		if !pair.OK {
+			failures = append(failures, pair)
+		}
+	}
+
+	return failures
+}
+
+// Verify measures the pairs of nodes sampled according to the options with
+// the probe and compares the results to the rules of the topology. The pairs
+// are measured in parallel as a probe lasts as long as its echo requests,
+// which keeps the verification of every pair short. The report is written in
+// the output directory. A warning is printed for each pair
+// beyond the tolerance, and an error is returned for a strict verification.
+func Verify(opts *Options, nodes []NodeInfo, probe Probe, out io.Writer) error {
+	cfg := opts.Verify
+	if cfg == nil {
+		return nil
+	}
+
+	fmt.Fprintln(out, "Verifying the topology...")
+
+	mapping := make(map[network.NodeID]string)
+	for _, node := range nodes {
+		mapping[network.NodeID(node.Name)] = node.Address
+	}
+
+	report := VerificationReport{
+		Timestamp: time.Now().Unix(),
+		Tolerance: cfg.Tolerance,
+		Margin:    cfg.Margin,
+	}
+
+	pairs := samplePairs(opts.Topology, nodes, cfg.Pairs)
+	report.Pairs = make([]VerifiedPair, len(pairs))
+
+	sem := make(chan struct{}, VerifyConcurrency)
+	wg := sync.WaitGroup{}
+
+	for i, pair := range pairs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, src, dst NodeInfo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			expected := expectRTT(opts.Topology, mapping, src, dst)
+			expected.Source = src.Name
+			expected.Destination = dst.Name
+
+			res, err := probe(src, dst, cfg.Count)
+			if err != nil {
+				expected.Error = err.Error()
+			} else {
+				expected.MeasuredRTT = res.RTT
+				expected.MeasuredLoss = res.Loss
+				expected.OK = cfg.accept(expected)
+			}
+
+			// The pairs keep the order of the sampling.
+			report.Pairs[i] = expected
+		}(i, pair[0], pair[1])
+	}
+
+	wg.Wait()
+
+	err := writeReport(filepath.Join(opts.OutputDir, VerificationFile), report)
+	if err != nil {
+		return xerrors.Errorf("couldn't write the report: %v", err)
+	}
+
+	failures := report.Failures()
+	for _, pair := range failures {
+		if pair.Error != "" {
+			fmt.Fprintf(out, "Warning: %s -> %s: %s\n", pair.Source, pair.Destination, pair.Error)
+			continue
+		}
+
+		fmt.Fprintf(out, "Warning: %s -> %s: rtt %v (expected %v), loss %.2f (expected %.2f)\n",
+			pair.Source, pair.Destination, pair.MeasuredRTT, pair.ExpectedRTT,
+			pair.MeasuredLoss, pair.ExpectedLoss)
+	}
+
+	if len(failures) > 0 && cfg.Strict {
+		fmt.Fprintln(out, "Verifying the topology... Failed.")
+		return xerrors.Errorf("%d of %d pairs beyond the tolerance", len(failures), len(report.Pairs))
+	}
+
+	fmt.Fprintln(out, "Verifying the topology... Done.")
+
+	return nil
+}
+
+func (v Verification) accept(pair VerifiedPair) bool {
+	allowed := time.Duration(v.Tolerance * float64(pair.ExpectedRTT))
+	if allowed < v.Margin {
+		allowed = v.Margin
+	}
+
+	diff := pair.MeasuredRTT - pair.ExpectedRTT
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return diff <= allowed && math.Abs(pair.MeasuredLoss-pair.ExpectedLoss) <= v.LossMargin
+}
+
+// samplePairs returns at most n distinct pairs of nodes, or every pair when
+// n is zero. The round-trip time is the same in both directions thus a pair
+// is only measured once. The pairs with a latency that is not only emulated
+// are left out.
+func samplePairs(topo network.Topology, nodes []NodeInfo, n int) [][2]NodeInfo {
+	regions := findRegions(topo)
+
+	pairs := make([][2]NodeInfo, 0)
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if regions[nodes[i].Name] == regions[nodes[j].Name] {
+				pairs = append(pairs, [2]NodeInfo{nodes[i], nodes[j]})
+			}
+		}
+	}
+
+	if n <= 0 || n >= len(pairs) {
+		return pairs
+	}
+
+	rand.Shuffle(len(pairs), func(i, j int) {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	})
+
+	pairs = pairs[:n]
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0].Name == pairs[j][0].Name {
+			return pairs[i][1].Name < pairs[j][1].Name
+		}
+
+		return pairs[i][0].Name < pairs[j][0].Name
+	})
+
+	return pairs
+}
+
+// findRegions returns the region of each node when the topology is a cloud
+// topology, even when it is modified by another topology, as the latency
+// between two regions is real and not emulated. It returns an empty map for
+// the other topologies.
+func findRegions(topo network.Topology) map[string]string {
+	regions := make(map[string]string)
+
+	for {
+		switch t := topo.(type) {
+		case network.CloudTopology:
+			for _, node := range t.GetNodes() {
+				regions[string(node.Name)] = node.NodeSelector
+			}
+
+			return regions
+		case network.ModifiedTopology:
+			topo = t.Unwrap()
+		default:
+			return regions
+		}
+	}
+}
+
+// expectRTT returns the round-trip time and the loss of the echo requests
+// between the nodes according to the rules of both directions.
+func expectRTT(topo network.Topology, mapping map[network.NodeID]string, src, dst NodeInfo) VerifiedPair {
+	out := findRule(topo.Rules(network.NodeID(src.Name), mapping), dst.Address)
+	in := findRule(topo.Rules(network.NodeID(dst.Name), mapping), src.Address)
+
+	return VerifiedPair{
+		ExpectedRTT:  out.Delay.Value + in.Delay.Value,
+		ExpectedLoss: 1 - (1-out.Loss.Value)*(1-in.Loss.Value),
+	}
+}
+
+// findRule returns the rule that applies to the echo requests sent to the
+// address. The rules restricted to a protocol or a port are ignored, and a
+// rule for the address itself takes precedence over a subnet.
+func findRule(rules []network.Rule, addr string) network.Rule {
+	ip := net.ParseIP(addr)
+
+	var match *network.Rule
+	for i, rule := range rules {
+		if rule.IsSpecific() {
+			continue
+		}
+
+		if rule.IP == addr {
+			return rule
+		}
+
+		_, subnet, err := net.ParseCIDR(rule.MatchAddr())
+		if err == nil && ip != nil && subnet.Contains(ip) && match == nil {
+			match = &rules[i]
+		}
+	}
+
+	if match != nil {
+		return *match
+	}
+
+	return network.Rule{}
+}
+
+func writeReport(path string, report VerificationReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return xerrors.Errorf("couldn't create the file: %v", err)
+	}
+
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(report)
+	if err != nil {
+		return xerrors.Errorf("couldn't encode: %v", err)
+	}
+
+	return nil
+}
+
+// ParsePing parses the summary of the ping command run in quiet mode. Both the
+// busybox and the iputils implementations are supported. The round-trip time
+// is the average of the replies, and is zero when every request is lost.
+func ParsePing(output string) (PingResult, error) {
+	res := PingResult{}
+	found := false
+
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.Contains(line, "packet loss"):
+			for _, field := range strings.Fields(line) {
+				if strings.HasSuffix(field, "%") {
+					loss, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+					if err != nil {
+						return res, xerrors.Errorf("invalid loss '%s'", field)
+					}
+
+					res.Loss = loss / 100
+					found = true
+					break
+				}
+			}
+		case strings.Contains(line, "min/avg/max"):
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			fields := strings.Fields(parts[1])
+			if len(fields) == 0 {
+				continue
+			}
+
+			values := strings.Split(fields[0], "/")
+			if len(values) < 2 {
+				return res, xerrors.Errorf("invalid round-trip times '%s'", parts[1])
+			}
+
+			avg, err := strconv.ParseFloat(values[1], 64)
+			if err != nil {
+				return res, xerrors.Errorf("invalid round-trip time '%s'", values[1])
+			}
+
+			res.RTT = time.Duration(avg * float64(time.Millisecond))
+		}
+	}
+
+	if !found {
+		return res, xerrors.New("missing statistics")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,220 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/simnet/network"
+)
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "simnet-verify-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := NewOptions([]Option{
+		WithOutput(dir),
+		WithTopology(network.NewSimpleTopology(3, 100*time.Millisecond)),
+		WithVerification(0, 0.1, true),
+	})
+
+	nodes := []NodeInfo{
+		{Name: "node0", Address: "10.0.0.1"},
+		{Name: "node1", Address: "10.0.0.2"},
+		{Name: "node2", Address: "10.0.0.3"},
+	}
+
+	probe := func(src, dst NodeInfo, count int) (PingResult, error) {
+		require.Equal(t, DefaultVerifyCount, count)
+
+		if src.Name == "node0" {
+			return PingResult{RTT: 105 * time.Millisecond}, nil
+		}
+
+		return PingResult{RTT: time.Millisecond}, nil
+	}
+
+	out := new(bytes.Buffer)
+	err = Verify(opts, nodes, probe, out)
+	require.NoError(t, err)
+	require.NotContains(t, out.String(), "Warning")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, VerificationFile))
+	require.NoError(t, err)
+
+	var report VerificationReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Pairs, 3)
+	require.Equal(t, "node1", report.Pairs[0].Destination)
+	require.Equal(t, 100*time.Millisecond, report.Pairs[0].ExpectedRTT)
+	require.Equal(t, time.Duration(0), report.Pairs[2].ExpectedRTT)
+
+	// The emulation is missing for the first node.
+	probe = func(src, dst NodeInfo, count int) (PingResult, error) {
+		return PingResult{RTT: time.Millisecond}, nil
+	}
+
+	out.Reset()
+	err = Verify(opts, nodes, probe, out)
+	require.EqualError(t, err, "2 of 3 pairs beyond the tolerance")
+	require.Contains(t, out.String(), "Warning: node0 -> node1: rtt 1ms (expected 100ms)")
+
+	opts.Verify.Strict = false
+	err = Verify(opts, nodes, probe, ioutil.Discard)
+	require.NoError(t, err)
+
+	probe = func(src, dst NodeInfo, count int) (PingResult, error) {
+		return PingResult{}, errors.New("oops")
+	}
+
+	out.Reset()
+	err = Verify(opts, nodes, probe, out)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "Warning: node0 -> node1: oops")
+
+	opts.OutputDir = "\000"
+	err = Verify(opts, nodes, probe, ioutil.Discard)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't write the report: ")
+
+	// The pairs are measured in parallel.
+	nodes = make([]NodeInfo, 8)
+	for i := range nodes {
+		nodes[i] = NodeInfo{Name: fmt.Sprintf("node%d", i)}
+	}
+
+	opts.OutputDir = dir
+	opts.Topology = network.NewSimpleTopology(len(nodes), 0)
+
+	running := int32(0)
+	probe = func(src, dst NodeInfo, count int) (PingResult, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		require.LessOrEqual(t, n, int32(VerifyConcurrency))
+		time.Sleep(10 * time.Millisecond)
+
+		return PingResult{}, nil
+	}
+
+	start := time.Now()
+	err = Verify(opts, nodes, probe, ioutil.Discard)
+	require.NoError(t, err)
+	// The 28 pairs would take at least 280ms one after another.
+	require.Less(t, int64(time.Since(start)), int64(280*time.Millisecond))
+
+	// Nothing to do without the option.
+	opts.Verify = nil
+	err = Verify(opts, nodes, nil, ioutil.Discard)
+	require.NoError(t, err)
+}
+
+func TestVerify_SamplePairs(t *testing.T) {
+	nodes := []NodeInfo{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+
+	require.Len(t, samplePairs(nil, nodes, 0), 6)
+	require.Len(t, samplePairs(nil, nodes, 10), 6)
+
+	pairs := samplePairs(nil, nodes, 3)
+	require.Len(t, pairs, 3)
+	for _, pair := range pairs {
+		require.True(t, pair[0].Name < pair[1].Name)
+	}
+}
+
+func TestVerify_Cloud(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "simnet-verify-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cloud := network.NewCloudTopologyFromRegions("region",
+		[]network.Region{{Value: "europe", N: 2}, {Value: "asia", N: 1}},
+		network.CloudLink{From: "europe", To: "europe", Delay: network.Delay{Value: 20 * time.Millisecond}},
+	)
+
+	opts := NewOptions([]Option{
+		WithOutput(dir),
+		WithTopology(network.NewModifiedTopology(cloud)),
+		WithVerification(0, 0.1, true),
+	})
+
+	nodes := []NodeInfo{
+		{Name: "node0", Address: "10.0.0.1"},
+		{Name: "node1", Address: "10.0.0.2"},
+		{Name: "node2", Address: "10.0.0.3"},
+	}
+
+	// The latency between the regions is real, thus only the pair inside
+	// the same region is compared to the topology.
+	probe := func(src, dst NodeInfo, count int) (PingResult, error) {
+		if dst.Name == "node2" {
+			return PingResult{RTT: 200 * time.Millisecond}, nil
+		}
+
+		return PingResult{RTT: 41 * time.Millisecond}, nil
+	}
+
+	err = Verify(opts, nodes, probe, ioutil.Discard)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, VerificationFile))
+	require.NoError(t, err)
+
+	var report VerificationReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Pairs, 1)
+	require.Equal(t, "node0", report.Pairs[0].Source)
+	require.Equal(t, "node1", report.Pairs[0].Destination)
+	require.Equal(t, 40*time.Millisecond, report.Pairs[0].ExpectedRTT)
+	require.True(t, report.Pairs[0].OK)
+}
+
+func TestVerify_FindRule(t *testing.T) {
+	rules := []network.Rule{
+		{IP: "10.0.0.2", Protocol: network.TCP, Delay: network.Delay{Value: time.Second}},
+		{IP: "10.0.0.0/8", Delay: network.Delay{Value: 2 * time.Second}},
+		{IP: "10.0.0.2", Delay: network.Delay{Value: 3 * time.Second}},
+	}
+
+	require.Equal(t, 3*time.Second, findRule(rules, "10.0.0.2").Delay.Value)
+	require.Equal(t, 2*time.Second, findRule(rules, "10.0.0.3").Delay.Value)
+	require.Equal(t, network.Rule{}, findRule(rules, "192.168.0.1"))
+}
+
+func TestVerify_ParsePing(t *testing.T) {
+	res, err := ParsePing(`PING 10.0.0.2 (10.0.0.2): 56 data bytes
+
+--- 10.0.0.2 ping statistics ---
+5 packets transmitted, 4 packets received, 20% packet loss
+round-trip min/avg/max = 100.101/100.250/100.402 ms
+`)
+	require.NoError(t, err)
+	require.Equal(t, PingResult{RTT: 100250 * time.Microsecond, Loss: 0.2}, res)
+
+	res, err = ParsePing(`--- 10.0.0.2 ping statistics ---
+5 packets transmitted, 0 received, 100% packet loss, time 4099ms
+`)
+	require.NoError(t, err)
+	require.Equal(t, PingResult{Loss: 1}, res)
+
+	_, err = ParsePing("ping: bad address 'abc'")
+	require.EqualError(t, err, "missing statistics")
+
+	_, err = ParsePing("5 packets transmitted, 0 received, abc% packet loss")
+	require.EqualError(t, err, "invalid loss 'abc%'")
+
+	_, err = ParsePing("round-trip min/avg/max = 100.101")
+	require.EqualError(t, err, "invalid round-trip times ' 100.101'")
+
+	_, err = ParsePing("round-trip min/avg/max = 1/abc/3 ms")
+	require.EqualError(t, err, "invalid round-trip time 'abc'")
+}